@@ -0,0 +1,873 @@
+package main
+
+import (
+	"errors"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/validator"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiDefaultPerPage = 20
+	apiMaxPerPage     = 100
+)
+
+type apiPostInput struct {
+	Title      *string   `json:"title"`
+	Content    *string   `json:"content"`
+	Categories *[]string `json:"categories"` // id или названия категорий
+	Category   *string   `json:"category"`   // одна категория, для совместимости
+}
+
+type apiCommentInput struct {
+	Content  string `json:"content"`
+	ParentID int    `json:"parent_id"`
+}
+
+type apiCategoryInput struct {
+	Name string `json:"name"`
+}
+
+// paginationMetadata: с ?page= — номера страниц, без него — курсоры для ?after= и ?before=
+type paginationMetadata struct {
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      int    `json:"total"`
+	TotalPages int    `json:"total_pages,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// apiPathSegments разбирает "/api/v1/posts/12/comments" в ["12", "comments"]
+func apiPathSegments(r *http.Request, prefix string) []string {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if rest == "" {
+		return nil
+	}
+	return strings.Split(rest, "/")
+}
+
+func readPagination(r *http.Request) (page, perPage int, v validator.Validator) {
+	page, perPage = 1, apiDefaultPerPage
+	qs := r.URL.Query()
+
+	if s := qs.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		v.CheckField(err == nil && n >= 1, "page", "must be a positive integer")
+		page = n
+	}
+	if s := qs.Get("per_page"); s != "" {
+		n, err := strconv.Atoi(s)
+		v.CheckField(err == nil && n >= 1 && n <= apiMaxPerPage, "per_page", "must be between 1 and 100")
+		perPage = n
+	}
+	return page, perPage, v
+}
+
+func newPaginationMetadata(page, perPage, total int) paginationMetadata {
+	return paginationMetadata{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: (total + perPage - 1) / perPage,
+	}
+}
+
+// apiCurrentUser — аналог requireAuthentication для API: 401 в JSON вместо редиректа
+func (app *application) apiCurrentUser(w http.ResponseWriter, r *http.Request) (*models2.User, bool) {
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.apiClientError(w, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.apiClientError(w, http.StatusUnauthorized)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// apiVerifiedUser — apiCurrentUser, но только с подтверждённым адресом: без него нельзя писать посты и комментарии
+func (app *application) apiVerifiedUser(w http.ResponseWriter, r *http.Request) (*models2.User, bool) {
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !user.EmailVerified {
+		app.apiError(w, http.StatusForbidden, "confirm your email address before posting")
+		return nil, false
+	}
+	return user, true
+}
+
+// apiRequireRole — аналог requireRole для API
+func (app *application) apiRequireRole(w http.ResponseWriter, r *http.Request, role string) (*models2.User, bool) {
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if user.Role != role || !app.hasScope(r, models2.ScopeModeration) {
+		app.apiClientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	if missing, err := app.twoFactorMissing(user); err != nil {
+		app.apiServerError(w, err)
+		return nil, false
+	} else if missing {
+		app.apiError(w, http.StatusForbidden, "your role requires two-factor authentication")
+		return nil, false
+	}
+	return user, true
+}
+
+func canModerate(user *models2.User) bool {
+	return user != nil && (user.Role == "moderator" || user.Role == "admin")
+}
+
+// mayModerate — роль модератора или админа и, если запрос пришёл с токеном, область moderation
+func (app *application) mayModerate(r *http.Request, user *models2.User) bool {
+	return canModerate(user) && app.hasScope(r, models2.ScopeModeration)
+}
+
+// apiGetPost возвращает пост; неодобренные посты видны только автору и модераторам
+func (app *application) apiGetPost(w http.ResponseWriter, r *http.Request, id int) (*models2.Post, bool) {
+	post, err := app.posts.Get(id)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.apiClientError(w, http.StatusNotFound)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	if post.Status != "approved" {
+		var user *models2.User
+		if userID, err := app.getCurrentUser(r); err == nil {
+			user, _ = app.users.Get(userID)
+		}
+		if user == nil || (user.ID != post.AuthorID && !canModerate(user)) {
+			app.apiClientError(w, http.StatusNotFound)
+			return nil, false
+		}
+	}
+	return post, true
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiClientError(w, http.StatusNotFound)
+}
+
+// apiPosts обслуживает /api/v1/posts: GET — список, POST — создание
+func (app *application) apiPosts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		app.apiListPosts(w, r)
+	case http.MethodPost:
+		app.apiCreatePost(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *application) apiListPosts(w http.ResponseWriter, r *http.Request) {
+	page, perPage, v := readPagination(r)
+	query, err := readPostQuery(r.URL.Query())
+	if err != nil {
+		v.AddFieldError("sort", "must be one of "+strings.Join(models2.PostSorts, ", "))
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+	filter, err := app.readCategoryFilter(r.URL.Query())
+	if err != nil {
+		if errors.Is(err, errUnknownCategory) {
+			v.AddFieldError("category", "must be an existing category")
+			app.apiValidationError(w, v)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	query.Categories = filter
+	query.Limit = perPage
+	offsetMode := r.URL.Query().Has("page")
+	if offsetMode {
+		query.Offset = (page - 1) * perPage
+	}
+
+	total, err := app.posts.Count(filter)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	result, err := app.posts.Page(query)
+	if err != nil {
+		if errors.Is(err, models2.ErrInvalidCursor) {
+			v.AddFieldError("cursor", "must be a cursor from a previous response with the same sort")
+			app.apiValidationError(w, v)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+	posts := result.Posts
+	if posts == nil {
+		posts = []*models2.Post{}
+	}
+
+	metadata := paginationMetadata{PerPage: perPage, Total: total, NextCursor: result.Next, PrevCursor: result.Prev}
+	if offsetMode {
+		metadata = newPaginationMetadata(page, perPage, total)
+	}
+	app.writeJSON(w, http.StatusOK, envelope{
+		"posts":    posts,
+		"metadata": metadata,
+	})
+}
+
+// validatePostInput проверяет поля поста и возвращает id выбранных категорий
+// (nil, если при частичном обновлении категории не переданы)
+func (app *application) validatePostInput(input apiPostInput, partial bool) (validator.Validator, []int, error) {
+	var v validator.Validator
+
+	if input.Title != nil || !partial {
+		title := ""
+		if input.Title != nil {
+			title = *input.Title
+		}
+		v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+		v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be longer than 100 characters")
+	}
+	if input.Content != nil || !partial {
+		content := ""
+		if input.Content != nil {
+			content = *input.Content
+		}
+		v.CheckField(validator.NotBlank(content), "content", "This field cannot be blank")
+	}
+	var categoryIDs []int
+	if input.Categories != nil || input.Category != nil || !partial {
+		var values []string
+		if input.Categories != nil {
+			values = append(values, *input.Categories...)
+		}
+		if input.Category != nil {
+			values = append(values, *input.Category)
+		}
+		ids, err := app.resolveCategories(values)
+		switch {
+		case errors.Is(err, errUnknownCategory):
+			v.AddFieldError("categories", "This field must contain existing categories")
+		case err != nil:
+			return v, nil, err
+		case len(ids) == 0:
+			v.AddFieldError("categories", "Choose at least one category")
+		}
+		categoryIDs = ids
+	}
+	return v, categoryIDs, nil
+}
+
+func (app *application) apiCreatePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.apiVerifiedUser(w, r)
+	if !ok {
+		return
+	}
+
+	var input apiPostInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v, categoryIDs, err := app.validatePostInput(input, false)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	status := "pending"
+	if app.mayModerate(r, user) {
+		status = "approved"
+	}
+
+	id, err := app.posts.Insert(*input.Title, *input.Content, "", user.Name, status, user.ID, categoryIDs)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	post, err := app.posts.Get(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/posts/"+strconv.Itoa(id))
+	app.writeJSON(w, http.StatusCreated, envelope{"post": post})
+}
+
+// apiPost обслуживает /api/v1/posts/{id}[/comments|/like|/dislike]
+func (app *application) apiPost(w http.ResponseWriter, r *http.Request) {
+	segments := apiPathSegments(r, "/api/v1/posts/")
+	if len(segments) == 0 || len(segments) > 3 || (len(segments) == 3 && segments[1] != "reactions") {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(segments[0])
+	if err != nil || id < 1 {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+
+	if len(segments) == 3 {
+		app.apiReactToPost(w, r, id, segments[2])
+		return
+	}
+	if len(segments) == 2 {
+		switch segments[1] {
+		case "comments":
+			app.apiPostComments(w, r, id)
+		case "like", "dislike":
+			app.apiReactToPost(w, r, id, segments[1])
+		case "reactions":
+			app.apiReactions(w, r, models2.TargetPost, id)
+		default:
+			app.apiClientError(w, http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		post, ok := app.apiGetPost(w, r, id)
+		if !ok {
+			return
+		}
+		app.writeJSON(w, http.StatusOK, envelope{"post": post})
+	case http.MethodPut, http.MethodPatch:
+		app.apiUpdatePost(w, r, id)
+	case http.MethodDelete:
+		app.apiDeletePost(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *application) apiUpdatePost(w http.ResponseWriter, r *http.Request, id int) {
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	post, ok := app.apiGetPost(w, r, id)
+	if !ok {
+		return
+	}
+	if post.AuthorID != user.ID && !app.mayModerate(r, user) {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	var input apiPostInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v, categoryIDs, err := app.validatePostInput(input, r.Method == http.MethodPatch)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return
+	}
+
+	if input.Title != nil {
+		post.Title = *input.Title
+	}
+	if input.Content != nil {
+		post.Content = *input.Content
+	}
+	if categoryIDs == nil {
+		categoryIDs = post.CategoryIDs()
+	}
+
+	err = app.posts.UpdatePost(post.Title, post.Content, post.ImagePath, post.Author, post.AuthorID, post.ID, user.ID, categoryIDs)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	post, err = app.posts.Get(post.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, envelope{"post": post})
+}
+
+func (app *application) apiDeletePost(w http.ResponseWriter, r *http.Request, id int) {
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	post, ok := app.apiGetPost(w, r, id)
+	if !ok {
+		return
+	}
+	// Те же правила, что и в DeletePost: автор, модератор или админ
+	if post.AuthorID != user.ID && !app.mayModerate(r, user) {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	path, err := app.posts.DeletePost(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if path != "" {
+		if err := os.Remove(filepath.Join(app.uploadDir, path)); err != nil {
+			app.errorLog.Println("Failed to delete image:", err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiPostComments(w http.ResponseWriter, r *http.Request, postID int) {
+	post, ok := app.apiGetPost(w, r, postID)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		// ?thread=true возвращает дерево ответов вместо плоского списка
+		getComments := app.comments.GetByPostID
+		if r.URL.Query().Get("thread") == "true" {
+			getComments = app.comments.GetThreadByPostID
+		}
+		comments, err := getComments(post.ID)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if comments == nil {
+			comments = []*models2.Comment{}
+		}
+		app.writeJSON(w, http.StatusOK, envelope{"comments": comments})
+	case http.MethodPost:
+		user, ok := app.apiVerifiedUser(w, r)
+		if !ok {
+			return
+		}
+		var input apiCommentInput
+		if err := app.readJSON(w, r, &input); err != nil {
+			app.apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var v validator.Validator
+		v.CheckField(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+		if !v.Valid() {
+			app.apiValidationError(w, v)
+			return
+		}
+
+		var parent *models2.Comment
+		if input.ParentID != 0 {
+			p, err := app.comments.GetByID(input.ParentID)
+			v.CheckField(err == nil && p.PostID == post.ID, "parent_id", "This field must be a comment on the same post")
+			parent = p
+		}
+		if !v.Valid() {
+			app.apiValidationError(w, v)
+			return
+		}
+
+		comment := &models2.Comment{PostID: post.ID, ParentID: input.ParentID, UserID: user.ID, Author: user.Name, Content: input.Content}
+		if err := app.comments.Insert(comment); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		app.notifyComment(post, parent, comment)
+		comment, err := app.comments.GetByID(comment.ID)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/api/v1/comments/"+strconv.Itoa(comment.ID))
+		app.writeJSON(w, http.StatusCreated, envelope{"comment": comment})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// apiReactToPost: POST ставит/снимает реакцию как кнопки на странице, DELETE снимает её.
+// Обслуживает /reactions/{kind} и старые /like и /dislike
+func (app *application) apiReactToPost(w http.ResponseWriter, r *http.Request, postID int, kind string) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := app.reactions.Kind(kind); !ok {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	post, ok := app.apiGetPost(w, r, postID)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		result, err := app.reactions.Toggle(models2.TargetPost, post.ID, user.ID, kind)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if result != "" {
+			app.notifyReaction(models2.TargetPost, result, post.AuthorID, user.ID, post.ID, 0)
+		}
+	} else if err := app.reactions.Remove(models2.TargetPost, post.ID, user.ID, kind); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	post, err := app.posts.Get(post.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, envelope{"post": post})
+}
+
+// apiComment обслуживает /api/v1/comments/{id}[/like|/dislike|/revisions]
+func (app *application) apiComment(w http.ResponseWriter, r *http.Request) {
+	segments := apiPathSegments(r, "/api/v1/comments/")
+	if len(segments) == 0 || len(segments) > 3 || (len(segments) == 3 && segments[1] != "reactions") {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(segments[0])
+	if err != nil || id < 1 {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+
+	comment, err := app.comments.GetByID(id)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.apiClientError(w, http.StatusNotFound)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+	// Комментарии к неодобренному посту видны тем же, кому виден сам пост
+	if _, ok := app.apiGetPost(w, r, comment.PostID); !ok {
+		return
+	}
+
+	if len(segments) == 3 {
+		app.apiReactToComment(w, r, comment, segments[2])
+		return
+	}
+	if len(segments) == 2 {
+		switch segments[1] {
+		case "like", "dislike":
+			app.apiReactToComment(w, r, comment, segments[1])
+		case "reactions":
+			app.apiReactions(w, r, models2.TargetComment, comment.ID)
+		case "revisions":
+			app.apiCommentRevisions(w, r, comment)
+		default:
+			app.apiClientError(w, http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app.writeJSON(w, http.StatusOK, envelope{"comment": comment})
+	case http.MethodPatch:
+		user, ok := app.apiCurrentUser(w, r)
+		if !ok {
+			return
+		}
+		if comment.UserID != user.ID && !app.mayModerate(r, user) {
+			app.apiClientError(w, http.StatusForbidden)
+			return
+		}
+		if comment.Deleted != nil {
+			app.apiClientError(w, http.StatusNotFound)
+			return
+		}
+		var input apiCommentInput
+		if err := app.readJSON(w, r, &input); err != nil {
+			app.apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var v validator.Validator
+		v.CheckField(validator.NotBlank(input.Content), "content", "This field cannot be blank")
+		if !v.Valid() {
+			app.apiValidationError(w, v)
+			return
+		}
+		if err := app.comments.Update(comment.ID, user.ID, input.Content); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		comment, err = app.comments.GetByID(comment.ID)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		app.writeJSON(w, http.StatusOK, envelope{"comment": comment})
+	case http.MethodDelete:
+		user, ok := app.apiCurrentUser(w, r)
+		if !ok {
+			return
+		}
+		if comment.UserID != user.ID && !app.mayModerate(r, user) {
+			app.apiClientError(w, http.StatusForbidden)
+			return
+		}
+		if err := app.comments.Delete(comment.ID); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PATCH, DELETE")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// apiCommentRevisions отдаёт историю правок комментария; доступно только модераторам
+func (app *application) apiCommentRevisions(w http.ResponseWriter, r *http.Request, comment *models2.Comment) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return
+	}
+	if !app.mayModerate(r, user) {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	revisions, err := app.comments.Revisions(comment.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []*models2.CommentRevision{}
+	}
+	app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions})
+}
+
+func (app *application) apiReactToComment(w http.ResponseWriter, r *http.Request, comment *models2.Comment, kind string) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := app.reactions.Kind(kind); !ok || comment.Deleted != nil {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+	user, ok := app.apiCurrentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		result, err := app.reactions.Toggle(models2.TargetComment, comment.ID, user.ID, kind)
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if result != "" {
+			app.notifyReaction(models2.TargetComment, result, comment.UserID, user.ID, comment.PostID, comment.ID)
+		}
+	} else if err := app.reactions.Remove(models2.TargetComment, comment.ID, user.ID, kind); err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	comment, err := app.comments.GetByID(comment.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.writeJSON(w, http.StatusOK, envelope{"comment": comment})
+}
+
+// apiReactions: GET /api/v1/{posts|comments}/{id}/reactions — счётчики по видам и кто отреагировал
+func (app *application) apiReactions(w http.ResponseWriter, r *http.Request, targetType string, targetID int) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if targetType == models2.TargetPost {
+		if _, ok := app.apiGetPost(w, r, targetID); !ok {
+			return
+		}
+	}
+
+	userID, _ := app.getCurrentUser(r)
+	summaries, err := app.reactions.Summaries(targetType, []int{targetID}, userID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	reactors, err := app.reactions.Reactors(targetType, targetID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if reactors == nil {
+		reactors = []*models2.Reactor{}
+	}
+	app.writeJSON(w, http.StatusOK, envelope{"reactions": summaries[targetID], "reactors": reactors})
+}
+
+// apiCategories обслуживает /api/v1/categories: GET — список, POST — создание (admin)
+func (app *application) apiCategories(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		categories, err := app.categories.GetAll()
+		if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		if categories == nil {
+			categories = []*models2.Category{}
+		}
+		app.writeJSON(w, http.StatusOK, envelope{"categories": categories})
+	case http.MethodPost:
+		if _, ok := app.apiRequireRole(w, r, "admin"); !ok {
+			return
+		}
+		input, ok := app.readCategoryInput(w, r)
+		if !ok {
+			return
+		}
+		id, err := app.categories.Insert(input.Name)
+		if err != nil {
+			if errors.Is(err, models2.ErrDuplicateCategory) {
+				app.apiError(w, http.StatusConflict, "Category already exists")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+		w.Header().Set("Location", "/api/v1/categories/"+strconv.Itoa(id))
+		app.writeJSON(w, http.StatusCreated, envelope{"category": &models2.Category{ID: id, Name: input.Name}})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// apiCategory обслуживает /api/v1/categories/{id}: GET, PUT и DELETE (admin)
+func (app *application) apiCategory(w http.ResponseWriter, r *http.Request) {
+	segments := apiPathSegments(r, "/api/v1/categories/")
+	if len(segments) != 1 {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(segments[0])
+	if err != nil || id < 1 {
+		app.apiClientError(w, http.StatusNotFound)
+		return
+	}
+
+	category, err := app.categories.Get(id)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.apiClientError(w, http.StatusNotFound)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app.writeJSON(w, http.StatusOK, envelope{"category": category})
+	case http.MethodPut:
+		if _, ok := app.apiRequireRole(w, r, "admin"); !ok {
+			return
+		}
+		input, ok := app.readCategoryInput(w, r)
+		if !ok {
+			return
+		}
+		if err := app.categories.Update(category.ID, input.Name); err != nil {
+			if errors.Is(err, models2.ErrDuplicateCategory) {
+				app.apiError(w, http.StatusConflict, "Category already exists")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+		category.Name = input.Name
+		app.writeJSON(w, http.StatusOK, envelope{"category": category})
+	case http.MethodDelete:
+		if _, ok := app.apiRequireRole(w, r, "admin"); !ok {
+			return
+		}
+		if err := app.categories.Delete(category.ID); err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (app *application) readCategoryInput(w http.ResponseWriter, r *http.Request) (*apiCategoryInput, bool) {
+	var input apiCategoryInput
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	var v validator.Validator
+	v.CheckField(validator.NotBlank(input.Name), "name", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(input.Name, 50), "name", "This field cannot be longer than 50 characters")
+	if !v.Valid() {
+		app.apiValidationError(w, v)
+		return nil, false
+	}
+	return &input, true
+}
@@ -0,0 +1,302 @@
+// api_test.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loginAs создаёт пользователя с заданной ролью и возвращает cookie его сессии
+func loginAs(t *testing.T, app *application, name, role string) *http.Cookie {
+	t.Helper()
+
+	email := name + "@example.com"
+	if err := app.users.Insert(name, email, "ValidPass123!"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := app.users.Authenticate(email, "ValidPass123!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.users.DB.Exec(`UPDATE users SET role = ?, email_verified = 1 WHERE id = ?`, role, id); err != nil {
+		t.Fatal(err)
+	}
+
+	token := "session-" + name
+	app.sessions.Create(&models2.Session{Token: token, UserID: id, Expiry: time.Now().Add(time.Hour), LastSeen: time.Now()})
+	return &http.Cookie{Name: "session_id", Value: token}
+}
+
+// addCSRFToken подписывает req так же, как форма со страницы: по cookie сессии, а без неё
+// выдаёт cookie csrf_id. Вызывать после добавления cookie.
+func addCSRFToken(app *application, req *http.Request) {
+	binding := ""
+	if cookie, err := req.Cookie(sessionCookieName); err == nil {
+		binding = "session:" + cookie.Value
+	} else {
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "test-csrf-id"})
+		binding = "anon:test-csrf-id"
+	}
+	req.Header.Set(csrfHeaderName, app.signer.MAC(csrfTokenPurpose, binding))
+}
+
+func TestAPIErrorsAreJSON(t *testing.T) {
+	app := newTestApplication(t)
+
+	req := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(`{"title":"t","content":"c","category":"News"}`))
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+	}
+	var body struct {
+		Error struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Status != http.StatusUnauthorized || body.Error.Message != "Unauthorized" {
+		t.Errorf("Unexpected error body: %+v", body.Error)
+	}
+}
+
+func TestAPICreateAndListPosts(t *testing.T) {
+	app := newTestApplication(t)
+	cookie := loginAs(t, app, "mod", "moderator")
+
+	for _, title := range []string{"first", "second", "third"} {
+		req := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(`{"title":"`+title+`","content":"body","category":"News"}`))
+		req.AddCookie(cookie)
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/posts?per_page=2&page=2", nil)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body struct {
+		Posts    []*models2.Post    `json:"posts"`
+		Metadata paginationMetadata `json:"metadata"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Posts) != 1 || body.Posts[0].Title != "first" {
+		t.Errorf("Expected only the oldest post on page 2, got %+v", body.Posts)
+	}
+	want := paginationMetadata{Page: 2, PerPage: 2, Total: 3, TotalPages: 2}
+	if body.Metadata != want {
+		t.Errorf("Expected metadata %+v, got %+v", want, body.Metadata)
+	}
+}
+
+func TestAPICategoryWritesRequireAdmin(t *testing.T) {
+	app := newTestApplication(t)
+	cookie := loginAs(t, app, "bob", "user")
+
+	req := httptest.NewRequest("POST", "/api/v1/categories", strings.NewReader(`{"name":"Music"}`))
+	req.AddCookie(cookie)
+	addCSRFToken(app, req)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestAPIBearerTokenScopes(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "carol", "user")
+	user, err := app.users.GetByEmail("carol@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readToken, err := app.apiTokens.Insert(user.ID, "reader", models2.ScopeRead, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeToken, err := app.apiTokens.Insert(user.ID, "writer", models2.ScopeWrite, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		want   int
+	}{
+		{"invalid token", "frm_nope", "GET", http.StatusUnauthorized},
+		{"read token can read", readToken, "GET", http.StatusOK},
+		{"read token cannot write", readToken, "POST", http.StatusForbidden},
+		{"write token can write", writeToken, "POST", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/posts", strings.NewReader(`{"title":"t","content":"c","category":"News"}`))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	tokens, err := app.apiTokens.ListByUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.LastUsed.IsZero() {
+			t.Errorf("Expected last used to be recorded for token %q", token.Name)
+		}
+	}
+}
+
+func TestBearerTokenOnlyForAPI(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "mod", "moderator")
+	mod, err := app.users.GetByEmail("mod@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeToken, err := app.apiTokens.Insert(mod.ID, "writer", models2.ScopeWrite, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Токен с правом записи не может выпустить себе токен модерации через страницу профиля
+	form := "name=escalate&scope=moderation&expires_in=0"
+	req := httptest.NewRequest("POST", "/user/profile/tokens/create", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+writeToken)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code == http.StatusCreated {
+		t.Errorf("Expected the token to be refused outside /api/, got %d", rr.Code)
+	}
+
+	tokens, err := app.apiTokens.ListByUser(mod.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 {
+		t.Errorf("Expected no new token to be created, got %d tokens", len(tokens))
+	}
+}
+
+func TestAPIDeleteCommentKeepsReplies(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	bob := loginAs(t, app, "bob", "user")
+
+	postID, err := app.posts.Insert("Thread", "Content", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := &models2.Comment{PostID: postID, Content: "Parent", UserID: 1, Author: "alice"}
+	if err := app.comments.Insert(parent); err != nil {
+		t.Fatal(err)
+	}
+	reply := &models2.Comment{PostID: postID, ParentID: parent.ID, Content: "Reply", UserID: 2, Author: "bob"}
+	if err := app.comments.Insert(reply); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.reactions.Toggle(models2.TargetComment, reply.ID, 1, "like"); err != nil {
+		t.Fatal(err)
+	}
+
+	remove := func(id int, cookie *http.Cookie) {
+		t.Helper()
+		req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/comments/%d", id), nil)
+		req.AddCookie(cookie)
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+	}
+
+	// Удаление комментария с ответом стирает только его текст
+	remove(parent.ID, alice)
+	got, err := app.comments.GetByID(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Deleted == nil || got.Content != "" {
+		t.Errorf("Expected the parent to be blanked, got %+v", got)
+	}
+	if got, err := app.comments.GetByID(reply.ID); err != nil || got.Likes != 1 {
+		t.Errorf("Expected bob's reply and its reaction to stay, got %+v (%v)", got, err)
+	}
+
+	// Когда уходит последний ответ, пустой родитель удаляется совсем
+	remove(reply.ID, bob)
+	if _, err := app.comments.GetByID(parent.ID); !errors.Is(err, models2.ErrNoRecord) {
+		t.Errorf("Expected the blanked parent to be removed, got %v", err)
+	}
+}
+
+func TestAPICommentOnPendingPost(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	bob := loginAs(t, app, "bob", "user")
+	mod := loginAs(t, app, "mod", "moderator")
+
+	postID, err := app.posts.Insert("Draft", "Not approved yet", "", "alice", "pending", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := &models2.Comment{PostID: postID, Content: "Early note", UserID: 1, Author: "alice"}
+	if err := app.comments.Insert(comment); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"guest", nil, http.StatusNotFound},
+		{"other user", bob, http.StatusNotFound},
+		{"post author", alice, http.StatusOK},
+		{"moderator", mod, http.StatusOK},
+	} {
+		for _, path := range []string{"/api/v1/comments/%d", "/api/v1/comments/%d/reactions"} {
+			req := httptest.NewRequest("GET", fmt.Sprintf(path, comment.ID), nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("%s, %s: expected status %d, got %d", tt.name, path, tt.want, rr.Code)
+			}
+		}
+	}
+}
@@ -2,20 +2,27 @@
 package main
 
 import (
+	models2 "forum-app/internal/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestIsAuthenticated(t *testing.T) {
 	app := &application{
-		sessions: make(map[string]int),
+		sessions: models2.NewMemorySessionStore(),
 	}
 
 	// Test authenticated
 	req := httptest.NewRequest("GET", "/", nil)
 	sessionID := "test_session"
-	app.sessions[sessionID] = 1
+	app.sessions.Create(&models2.Session{
+		Token:    sessionID,
+		UserID:   1,
+		Expiry:   time.Now().Add(time.Hour),
+		LastSeen: time.Now(),
+	})
 	req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
 
 	if !app.isAuthenticated(req) {
@@ -28,3 +35,81 @@ func TestIsAuthenticated(t *testing.T) {
 		t.Error("Expected false for unauthenticated user, got true")
 	}
 }
+
+func TestSessionPersistsInDatabase(t *testing.T) {
+	app := newTestApplication(t)
+	db := app.users.DB
+	app.sessions = &models2.SessionModel{DB: db}
+
+	if err := app.users.Insert("alice", "alice@example.com", "ValidPass123!"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := app.users.Authenticate("alice@example.com", "ValidPass123!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := httptest.NewRequest("POST", "/user/login", nil)
+	login.Header.Set("User-Agent", "test-agent")
+	rr := httptest.NewRecorder()
+	token := app.setSession(rr, login, userID)
+
+	// Новое хранилище поверх той же базы, как после перезапуска процесса
+	app.sessions = &models2.SessionModel{DB: db}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: token})
+	got, err := app.getCurrentUser(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != userID {
+		t.Errorf("Expected user %d, got %d", userID, got)
+	}
+
+	session, err := app.sessions.Get(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserAgent != "test-agent" {
+		t.Errorf("Expected user agent %q, got %q", "test-agent", session.UserAgent)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := app.deleteSession(rr, req); err != nil {
+		t.Fatal(err)
+	}
+	if app.isAuthenticated(req) {
+		t.Error("Expected session to be gone after deleteSession")
+	}
+}
+
+func TestMultipleDeviceSessions(t *testing.T) {
+	app := newTestApplication(t)
+
+	laptop := app.setSession(httptest.NewRecorder(), httptest.NewRequest("POST", "/user/login", nil), 1)
+	phone := app.setSession(httptest.NewRecorder(), httptest.NewRequest("POST", "/user/login", nil), 1)
+
+	sessions, err := app.sessions.ListByUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions after logging in twice, got %d", len(sessions))
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: laptop})
+	if !app.isAuthenticated(req) {
+		t.Error("Expected first device to stay logged in after second login")
+	}
+
+	if err := app.deleteAllSessions(httptest.NewRecorder(), 1); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: phone})
+	if app.isAuthenticated(req) {
+		t.Error("Expected no device to be logged in after logging out everywhere")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostCategoriesFilterAndRename(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+
+	// Категории из миграции: 1 News, 2 Technology, 3 Funny, 4 Sport, 5 Other
+	both, err := app.posts.Insert("Tech news", "body", "", "alice", "approved", 1, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.posts.Insert("Only news", "body", "", "alice", "approved", 1, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.posts.Insert("Only tech", "body", "", "alice", "approved", 1, []int{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter models2.CategoryFilter
+		want   int
+	}{
+		{"any", models2.CategoryFilter{IDs: []int{1, 2}}, 3},
+		{"all", models2.CategoryFilter{IDs: []int{1, 2}, MatchAll: true}, 1},
+		{"all with duplicates", models2.CategoryFilter{IDs: []int{1, 2, 2}, MatchAll: true}, 1},
+		{"single", models2.CategoryFilter{IDs: []int{2}}, 2},
+		{"empty category", models2.CategoryFilter{IDs: []int{4}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := app.posts.Page(models2.PostQuery{Categories: tt.filter, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Posts) != tt.want {
+				t.Errorf("Expected %d posts, got %d", tt.want, len(page.Posts))
+			}
+		})
+	}
+
+	if err := app.categories.Update(2, "Tech"); err != nil {
+		t.Fatal(err)
+	}
+	post, err := app.posts.Get(both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range post.Categories {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "News,Tech" {
+		t.Errorf("Expected renamed category on the post, got %v", names)
+	}
+
+	req := httptest.NewRequest("GET", "/?category=1&category=Tech&match=all", nil)
+	rr := httptest.NewRecorder()
+	app.home(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Tech news") || strings.Contains(body, "Only news") {
+		t.Error("Expected home to show only posts in all selected categories")
+	}
+
+	req = httptest.NewRequest("GET", "/?category=Nope", nil)
+	rr = httptest.NewRecorder()
+	app.home(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown category, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
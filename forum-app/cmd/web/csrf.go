@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"strings"
+)
+
+const (
+	csrfContextKey   = contextKey("csrfToken")
+	csrfCookieName   = "csrf_id"      // привязка токена для тех, кто ещё не вошёл
+	csrfFieldName    = "csrf_token"   // скрытое поле форм
+	csrfHeaderName   = "X-CSRF-Token" // для запросов из JS и к API по cookie
+	csrfTokenPurpose = "csrf"
+)
+
+// csrf выдаёт каждому запросу CSRF-токен и проверяет его у запросов, меняющих данные.
+// Токен — подпись сессии (или cookie csrf_id до входа), поэтому на сервере ничего не хранится,
+// а после входа или выхода старые токены перестают подходить.
+// Запросы с Bearer-токеном не проверяются: браузер не подставляет его сам.
+func (app *application) csrf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(apiTokenContextKey).(*models2.APIToken); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Статике и метрикам формы не нужны — не ищем сессию зря
+		if csrfSafeMethod(r.Method) && csrfExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		binding, fromSession, err := app.csrfBinding(w, r)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		token := app.signer.MAC(csrfTokenPurpose, binding)
+
+		if !csrfSafeMethod(r.Method) {
+			// API без cookie-сессии нечего подделывать — там ответит 401
+			api := strings.HasPrefix(r.URL.Path, "/api/")
+			if !api || fromSession {
+				sent := r.Header.Get(csrfHeaderName)
+				if sent == "" && !api {
+					// Тело разбирается здесь впервые, поэтому и предел размера ставится здесь
+					if !app.parseForm(w, r) {
+						return
+					}
+					sent = r.PostForm.Get(csrfFieldName)
+				}
+				if !hmac.Equal([]byte(sent), []byte(token)) {
+					app.infoLog.Printf("CSRF token mismatch: %s %s from %s", r.Method, r.URL.Path, app.clientIP(r))
+					if api {
+						app.apiClientError(w, http.StatusForbidden)
+					} else {
+						app.clientError(w, http.StatusForbidden)
+					}
+					return
+				}
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), csrfContextKey, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// csrfBinding возвращает, к чему привязан токен: к сессии, а без неё — к cookie csrf_id,
+// которую при необходимости выдаёт
+func (app *application) csrfBinding(w http.ResponseWriter, r *http.Request) (string, bool, error) {
+	if session, err := app.currentSession(r); err == nil {
+		return "session:" + session.Token, true, nil
+	}
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return "anon:" + cookie.Value, false, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return "anon:" + id, false, nil
+}
+
+// csrfExempt — пути без форм, которым токен не выдаётся
+func csrfExempt(path string) bool {
+	return strings.HasPrefix(path, "/static/") || path == "/metrics"
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// csrfToken возвращает токен текущего запроса для форм; пустой — если csrf не подключён
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey).(string)
+	return token
+}
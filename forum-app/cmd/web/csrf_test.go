@@ -0,0 +1,159 @@
+package main
+
+import (
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var csrfFieldRX = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func TestCSRF(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+
+	get := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	post := func(path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Каждая форма страницы несёт токен сессии, и с ним запрос проходит
+	rr := get("/user/profile/notifications", alice)
+	tokens := csrfFieldRX.FindAllStringSubmatch(rr.Body.String(), -1)
+	if len(tokens) < 2 {
+		t.Fatalf("Expected the page and logout forms to carry a token, got %d", len(tokens))
+	}
+	token := tokens[0][1]
+	if rr := post("/user/logout", url.Values{}, alice); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "Forbidden") {
+		t.Errorf("Expected a POST without a token to get the 403 page, got %d", rr.Code)
+	}
+	if rr := post("/user/logout", url.Values{"csrf_token": {token + "x"}}, alice); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a wrong token to be rejected, got %d", rr.Code)
+	}
+	if _, err := app.sessions.Get(alice.Value); err != nil {
+		t.Fatal("Expected rejected requests to leave the session alone")
+	}
+
+	// Токен одной сессии не подходит другой
+	bob := loginAs(t, app, "bob", "user")
+	if rr := post("/user/logout", url.Values{"csrf_token": {token}}, bob); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected another session's token to be rejected, got %d", rr.Code)
+	}
+	if rr := post("/user/logout", url.Values{"csrf_token": {token}}, alice); rr.Code != http.StatusSeeOther {
+		t.Errorf("Expected logout with the token to work, got %d", rr.Code)
+	}
+
+	// До входа токен привязан к cookie csrf_id, которую выдаёт первая же страница
+	rr = get("/user/login")
+	var anon *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			anon = c
+		}
+	}
+	match := csrfFieldRX.FindStringSubmatch(rr.Body.String())
+	if anon == nil || match == nil {
+		t.Fatal("Expected the login page to issue a csrf_id cookie and a token")
+	}
+	form := url.Values{"email": {"alice@example.com"}, "password": {"ValidPass123!"}, "csrf_token": {match[1]}}
+	if rr := post("/user/login", form); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a login without the csrf_id cookie to be rejected, got %d", rr.Code)
+	}
+	if rr := post("/user/login", form, anon); rr.Code != http.StatusSeeOther {
+		t.Errorf("Expected the login to work, got %d", rr.Code)
+	}
+
+	// Статика и метрики не получают ни токена, ни cookie csrf_id
+	for _, path := range []string{"/static/css/main.css", "/metrics"} {
+		for _, c := range get(path).Result().Cookies() {
+			if c.Name == csrfCookieName {
+				t.Errorf("Expected no csrf_id cookie for %s", path)
+			}
+		}
+	}
+}
+
+func TestCSRFAPI(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	writeToken, err := app.apiTokens.Insert(1, "script", models2.ScopeWrite, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(auth string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(`{"title":"t","content":"c","category":"News"}`))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// По cookie API требует заголовок X-CSRF-Token, по Bearer-токену — нет
+	if rr := send("", alice); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), `"status":403`) {
+		t.Errorf("Expected a JSON 403 for a cookie request without a token, got %d %s", rr.Code, rr.Body)
+	}
+	if rr := send(writeToken, nil); rr.Code != http.StatusCreated {
+		t.Errorf("Expected the Bearer request to need no CSRF token, got %d %s", rr.Code, rr.Body)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	app := newTestApplication(t)
+	origins, err := parseCORSOrigins("https://app.example.com, http://localhost:3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.corsOrigins = origins
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/v1/posts", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := preflight("https://app.example.com")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected the listed origin to be allowed, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Expected no credentials to be allowed cross-origin")
+	}
+	if rr := preflight("https://evil.example.com"); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected other origins to get no CORS headers, got %q", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	for _, bad := range []string{"*", "app.example.com", "https://app.example.com/path", "ftp://app.example.com"} {
+		if _, err := parseCORSOrigins(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// sessionsPage показывает пользователю все устройства, на которых он залогинен
+func (app *application) sessionsPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	current, err := app.currentSession(r)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+
+	sessions, err := app.sessions.ListByUser(current.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Sessions = sessions
+	data.CurrentSessionID = current.ID
+	app.render(w, http.StatusOK, "sessions.html", data)
+}
+
+// revokeSession завершает одну сессию пользователя по её ID
+func (app *application) revokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	current, err := app.currentSession(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(r.FormValue("session_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Завершение текущей сессии равносильно выходу
+	if sessionID == current.ID {
+		if err := app.deleteSession(w, r); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.flash(w, r, "You've been logged out successfully!")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	err = app.sessions.DeleteByID(current.UserID, sessionID)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(w, r, "Device logged out successfully!")
+	http.Redirect(w, r, "/user/profile/sessions", http.StatusSeeOther)
+}
+
+// revokeAllSessions — «выйти везде»: завершает все сессии пользователя, включая текущую
+func (app *application) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := app.deleteAllSessions(w, userID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(w, r, "You've been logged out on all devices!")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"forum-app/internal/config"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 	"golang.org/x/oauth2/google"
@@ -9,35 +10,49 @@ import (
 	"strconv"
 )
 
-var oauth2Config = &oauth2.Config{
-	ClientID:     "274551296930-b4mtjr1b9260o3pnsr1jc6mp2c9mvscf.apps.googleusercontent.com", // Замените на ваш Google Client ID
-	ClientSecret: "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",                                      // Замените на ваш Google Client Secret
-	RedirectURL:  "http://localhost:4000/user/googlecallback",
-	Scopes:       []string{"https://www.googleapis.com/auth/userinfo.profile", "https://www.googleapis.com/auth/userinfo.email"},
-	Endpoint:     google.Endpoint,
+// googleOAuthConfig возвращает настройки входа через Google; nil, если провайдер не настроен
+func googleOAuthConfig(c config.OAuth) *oauth2.Config {
+	if !c.Enabled() {
+		return nil
+	}
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: string(c.ClientSecret),
+		RedirectURL:  c.RedirectURL,
+		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.profile", "https://www.googleapis.com/auth/userinfo.email"},
+		Endpoint:     google.Endpoint,
+	}
 }
 
 func (app *application) googleLogin(w http.ResponseWriter, r *http.Request) {
+	if app.googleOAuth == nil {
+		app.notFound(w)
+		return
+	}
 	// Генерация URL для авторизации через Google
-	authURL := oauth2Config.AuthCodeURL("", oauth2.AccessTypeOffline)
+	authURL := app.googleOAuth.AuthCodeURL("", oauth2.AccessTypeOffline)
 	// Редирект на страницу авторизации Google
 	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
 func (app *application) googleCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.googleOAuth == nil {
+		app.notFound(w)
+		return
+	}
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	token, err := oauth2Config.Exchange(r.Context(), code)
+	token, err := app.googleOAuth.Exchange(r.Context(), code)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	client := oauth2Config.Client(r.Context(), token)
+	client := app.googleOAuth.Client(r.Context(), token)
 	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
 	if err != nil {
 		app.serverError(w, err)
@@ -62,42 +77,55 @@ func (app *application) googleCallbackHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	app.setSession(w, userID)
-	app.flash(w, r, "Logged in with Google account!")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	// Как и при входе по паролю: с включённой 2FA сессия появится только после второго шага
+	app.startLogin(w, r, userID)
 }
 
-var githubOauth2Config = &oauth2.Config{
-	ClientID:     "Ov23li5UZErJbCnv7tYX",                      // Замените на ваш GitHub Client ID
-	ClientSecret: "e5aa8791ae6e3cb1989f3ccc4ab12223730acd48",  // Замените на ваш GitHub Client Secret
-	RedirectURL:  "http://localhost:4000/user/githubcallback", // URL для обработки callback от GitHub
-	Scopes:       []string{"read:user", "user:email"},         // Разрешения, запрашиваемые у пользователя
-	Endpoint:     github.Endpoint,
+// githubOAuthConfig возвращает настройки входа через GitHub; nil, если провайдер не настроен
+func githubOAuthConfig(c config.OAuth) *oauth2.Config {
+	if !c.Enabled() {
+		return nil
+	}
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: string(c.ClientSecret),
+		RedirectURL:  c.RedirectURL,
+		Scopes:       []string{"read:user", "user:email"}, // Разрешения, запрашиваемые у пользователя
+		Endpoint:     github.Endpoint,
+	}
 }
 
 // Обработчик для перенаправления на страницу авторизации GitHub
 func (app *application) githubLogin(w http.ResponseWriter, r *http.Request) {
+	if app.githubOAuth == nil {
+		app.notFound(w)
+		return
+	}
 	// Генерация URL для авторизации через GitHub
-	authURL := githubOauth2Config.AuthCodeURL("", oauth2.AccessTypeOffline)
+	authURL := app.githubOAuth.AuthCodeURL("", oauth2.AccessTypeOffline)
 	// Редирект на страницу авторизации GitHub
 	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
 // Обработчик для обработки callback от GitHub
 func (app *application) githubCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.githubOAuth == nil {
+		app.notFound(w)
+		return
+	}
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	token, err := githubOauth2Config.Exchange(r.Context(), code)
+	token, err := app.githubOAuth.Exchange(r.Context(), code)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	client := githubOauth2Config.Client(r.Context(), token)
+	client := app.githubOAuth.Client(r.Context(), token)
 	resp, err := client.Get("https://api.github.com/user")
 	if err != nil {
 		app.serverError(w, err)
@@ -149,7 +177,5 @@ func (app *application) githubCallbackHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	app.setSession(w, userID)
-	app.flash(w, r, "Logged in with GitHub account!")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	app.startLogin(w, r, userID)
 }
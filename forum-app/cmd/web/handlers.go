@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -15,24 +16,36 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// categorySelection — id категорий, отмеченных в форме поста
+type categorySelection []int
+
+func (c categorySelection) Has(id int) bool {
+	for _, selected := range c {
+		if selected == id {
+			return true
+		}
+	}
+	return false
+}
+
 type postCreateForm struct {
-	Title     string
-	Content   string
-	ImagePath string
-	Category  string
-	Author    string
-	AuthorID  int
+	Title      string
+	Content    string
+	ImagePath  string
+	Categories categorySelection
+	Author     string
+	AuthorID   int
 	validator.Validator
 	Status string
 }
 type editPost struct {
-	ID        int
-	Title     string
-	Content   string
-	ImagePath string
-	Category  string
-	Author    string
-	AuthorID  int
+	ID         int
+	Title      string
+	Content    string
+	ImagePath  string
+	Categories categorySelection
+	Author     string
+	AuthorID   int
 	validator.Validator
 }
 type userSignupForm struct {
@@ -91,47 +104,65 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		app.clientError(w, http.StatusNotFound)
 		return
 	}
-	selectedCategory := r.URL.Query().Get("Category")
-	data := app.newTemplateData(w, r)
-	var posts []*models2.Post
-	var err error
-	if selectedCategory == "" {
-		posts, err = app.posts.Latest()
-	} else {
-		posts, err = app.posts.SortByCategory(selectedCategory)
-		data.SelectedCategory = selectedCategory
-	}
+	filter, err := app.readCategoryFilter(r.URL.Query())
 	if err != nil {
-		app.serverError(w, err)
+		if errors.Is(err, errUnknownCategory) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
-	data.Posts = posts
-	categories, err := app.categories.GetAll()
+	query, err := readPostQuery(r.URL.Query())
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
-	userID, err := app.getCurrentUser(r)
-	if err != nil && userID == 0 {
-		data.Categories = categories
-		data.IsAuthenticated = app.isAuthenticated(r)
-		app.render(w, http.StatusOK, "home.html", data)
-		return
-	} else if err != nil {
-		app.serverError(w, err)
+
+	// Гостю доступна общая лента; личные фильтры требуют входа
+	var user *models2.User
+	if userID, err := app.getCurrentUser(r); err == nil {
+		user, err = app.users.Get(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	personal := readPersonalFilter(r.URL.Query())
+	if personal.Active() {
+		if user == nil {
+			app.flash(w, r, "You should login before to do that")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		personal.UserID = user.ID
+	}
+
+	query.Categories = filter
+	query.Personal = personal
+	page, err := app.posts.Page(query)
+	if err != nil {
+		if isPageError(err) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
-	user, err := app.users.Get(userID)
+	categories, err := app.categories.GetAll()
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	data := app.newTemplateData(w, r)
+	data.CategoryFilter = filter
+	data.PersonalFilter = personal
+	data.setPage(r, page)
 	data.Categories = categories
 	data.User = user
-	data.IsAuthenticated = app.isAuthenticated(r)
+	data.IsAuthenticated = user != nil
 	app.render(w, http.StatusOK, "home.html", data)
-	return
 }
 
 func (app *application) postView(w http.ResponseWriter, r *http.Request) {
@@ -161,7 +192,7 @@ func (app *application) postView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := app.comments.GetByPostID(id)
+	comments, err := app.comments.GetThreadByPostID(id)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -181,6 +212,14 @@ func (app *application) postView(w http.ResponseWriter, r *http.Request) {
 		data.User = user
 	}
 	data.IsAuthenticated = app.isAuthenticated(r)
+	viewerID := 0
+	if data.User != nil {
+		viewerID = data.User.ID
+	}
+	if err := app.attachReactions(post, comments, viewerID); err != nil {
+		app.serverError(w, err)
+		return
+	}
 	app.render(w, http.StatusOK, "view.html", data)
 }
 
@@ -207,8 +246,10 @@ func (app *application) postCreateForm(w http.ResponseWriter, r *http.Request) {
 
 	// Если метод POST, обрабатываем данные формы
 	if r.Method == http.MethodPost {
-		err := r.ParseMultipartForm(20 << 20)
-		if err != nil {
+		if !app.parseForm(w, r) {
+			return
+		}
+		if r.MultipartForm == nil {
 			app.clientError(w, http.StatusBadRequest)
 			return
 		}
@@ -220,16 +261,16 @@ func (app *application) postCreateForm(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		var filePath string
+		var fileName string
 		if err == nil {
 			// Файл был прикреплен, обрабатываем его
 			defer file.Close()
 			app.infoLog.Printf("Uploaded File: %+v\n", handler.Filename)
 			app.infoLog.Printf("File Size: %+v\n", handler.Size)
 			app.infoLog.Printf("MIME Header: %+v\n", handler.Header)
-			fileName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), handler.Filename)
-			filePath = fmt.Sprintf("ui/static/upload/%s", fileName)
-			if err := os.MkdirAll("ui/static/upload", os.ModePerm); err != nil {
+			fileName = fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(handler.Filename))
+			filePath := filepath.Join(app.uploadDir, fileName)
+			if err := os.MkdirAll(app.uploadDir, os.ModePerm); err != nil {
 				app.serverError(w, err)
 				return
 			}
@@ -244,9 +285,6 @@ func (app *application) postCreateForm(w http.ResponseWriter, r *http.Request) {
 				app.serverError(w, err)
 				return
 			}
-		} else {
-			// Если файл не был прикреплен, оставляем filePath пустым
-			filePath = ""
 		}
 
 		id, err := app.getCurrentUser(r)
@@ -270,22 +308,25 @@ func (app *application) postCreateForm(w http.ResponseWriter, r *http.Request) {
 		form := postCreateForm{
 			Title:     r.PostForm.Get("title"),
 			Content:   r.PostForm.Get("content"),
-			ImagePath: filePath,
-			Category:  r.PostForm.Get("Category"),
+			ImagePath: fileName,
 			Author:    author.Name,
 			AuthorID:  id,
 			Status:    statusString,
 		}
-		form.ImagePath = strings.TrimPrefix(form.ImagePath, "ui/static/upload/")
 
 		// Валидация полей
 		form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 		form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be longer than 100 characters")
 		form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+		form.Categories, err = app.checkPostCategories(&form.Validator, r.PostForm["category"])
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
 		if !form.Valid() {
-			data := app.newTemplateData(w, r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "create.html", data)
+			if err := app.renderPostForm(w, r, http.StatusUnprocessableEntity, "create.html", form); err != nil {
+				app.serverError(w, err)
+			}
 			return
 		}
 
@@ -295,10 +336,10 @@ func (app *application) postCreateForm(w http.ResponseWriter, r *http.Request) {
 			form.Title,
 			form.Content,
 			form.ImagePath,
-			form.Category,
 			form.Author,
 			form.Status,
 			form.AuthorID,
+			form.Categories,
 		)
 
 		if err != nil {
@@ -367,8 +408,19 @@ func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Письмо со ссылкой подтверждения; до подтверждения нельзя писать посты и комментарии
+		user, err := app.users.GetByEmail(form.Email)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if _, err := app.sendEmailVerification(user, user.Email); err != nil {
+			app.serverError(w, err)
+			return
+		}
+
 		// Если регистрация прошла успешно, отображаем сообщение и редиректим
-		app.flash(w, r, "Account created successfully!")
+		app.flash(w, r, "Account created successfully! Check your email for a link to confirm your address.")
 		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		return
 	}
@@ -406,9 +458,25 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Неудачные попытки по этому адресу или с этого IP замедляют следующие
+		wait, err := app.loginWait(form.Email, app.clientIP(r), app.now())
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if wait > 0 {
+			app.tooManyLogins(w, r, "login.html", &form, wait)
+			return
+		}
+
 		id, err := app.users.Authenticate(form.Email, form.Password)
 		if err != nil {
 			if errors.Is(err, models2.ErrInvalidCredentials) {
+				// Время неудачи — после проверки пароля: bcrypt небыстрый, и пауза отсчитывается от ответа
+				if err := app.recordLoginFailure(form.Email, app.clientIP(r), app.now()); err != nil {
+					app.serverError(w, err)
+					return
+				}
 				form.AddNonFieldError("Email or password is incorrect")
 				data := app.newTemplateData(w, r)
 				data.Form = form
@@ -420,9 +488,8 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		app.flash(w, r, "Account logged in successfully!")
-		app.setSession(w, id)
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		// С включённой 2FA сессия создаётся только после ввода кода
+		app.startLogin(w, r, id)
 	}
 }
 
@@ -432,34 +499,13 @@ func (app *application) userLogout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Renew the session token (invalidate the old session and create a new one).
-	err := app.renewSessionToken(w, r)
+	// Remove the session from the session store and expire the cookie.
+	err := app.deleteSession(w, r)
 	if err != nil {
 		app.clientError(w, http.StatusUnauthorized)
 		return
 	}
 
-	// Remove the authenticated user ID from the session data.
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	// Remove the session from the session store.
-	delete(app.sessions, cookie.Value)
-
-	// Expire the session cookie on the client side.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
-
 	// Add a flash message for the user.
 	app.flash(w, r, "You've been logged out successfully!")
 
@@ -471,7 +517,7 @@ func (app *application) profile(w http.ResponseWriter, r *http.Request) {
 	dbQueryDuration.Observe(time.Since(start).Seconds())
 	id, err := app.getCurrentUser(r)
 	if err != nil {
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.Redirect(w, r, "/user/login", http.StatusFound)
 		return
 	}
 	user, err := app.users.Get(id)
@@ -480,9 +526,21 @@ func (app *application) profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userPosts, err := app.posts.UserPosts(id)
+	// Автор видит в профиле и свои посты на модерации
+	query, err := readPostQuery(r.URL.Query())
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	query.AuthorID = id
+	query.IncludePending = true
+	userPosts, err := app.posts.Page(query)
+	if err != nil {
+		if isPageError(err) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
 		return
 	}
 
@@ -492,12 +550,13 @@ func (app *application) profile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	data := app.newTemplateData(w, r)
-	data.Posts = userPosts
+	data.setPage(r, userPosts)
 	data.Comments = userComments
 	data.User = &models2.User{
-		Name:  user.Name,
-		Email: user.Email,
-		Role:  user.Role,
+		Name:          user.Name,
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
 	}
 
 	app.render(w, http.StatusOK, "profile.html", data)
@@ -557,6 +616,11 @@ func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	// Меняем токен текущей сессии после смены пароля
+	if err := app.renewSessionToken(w, r); err != nil {
+		app.serverError(w, err)
+		return
+	}
 	app.flash(w, r, "Password changed successfully!")
 	// Перенаправление на страницу профиля
 	http.Redirect(w, r, "/user/profile/", http.StatusSeeOther)
@@ -594,30 +658,53 @@ func (app *application) EditPost(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
-
-		data := app.newTemplateData(w, r)
-		data.Form = editPost{
-			ID:        post.ID,
-			Title:     post.Title,
-			Content:   post.Content,
-			ImagePath: post.ImagePath,
-			Category:  post.Category,
-			Author:    post.Author,
-			AuthorID:  post.AuthorID,
+		if !app.canEditPost(w, r, post) {
+			return
 		}
 
-		app.render(w, http.StatusOK, "edit_post.html", data)
+		form := editPost{
+			ID:         post.ID,
+			Title:      post.Title,
+			Content:    post.Content,
+			ImagePath:  post.ImagePath,
+			Categories: post.CategoryIDs(),
+			Author:     post.Author,
+			AuthorID:   post.AuthorID,
+		}
+		if err := app.renderPostForm(w, r, http.StatusOK, "edit_post.html", form); err != nil {
+			app.serverError(w, err)
+		}
 	}
 
 	// Если метод POST, обрабатываем данные формы
 	if r.Method == http.MethodPost {
-		err := r.ParseMultipartForm(20 << 20)
+		if !app.parseForm(w, r) {
+			return
+		}
+		if r.MultipartForm == nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		// Получаем текущий пост и проверяем права до сохранения файла
+		intID, err := strconv.Atoi(r.PostForm.Get("id"))
 		if err != nil {
 			app.clientError(w, http.StatusBadRequest)
 			return
 		}
+		post, err := app.posts.Get(intID)
+		if err != nil {
+			if errors.Is(err, models2.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+		if !app.canEditPost(w, r, post) {
+			return
+		}
 
-		var filePath string
 		var fileName string
 		file, handler, err := r.FormFile("image")
 
@@ -632,9 +719,9 @@ func (app *application) EditPost(w http.ResponseWriter, r *http.Request) {
 			app.infoLog.Printf("Uploaded File: %+v\n", handler.Filename)
 			app.infoLog.Printf("File Size: %+v\n", handler.Size)
 			app.infoLog.Printf("MIME Header: %+v\n", handler.Header)
-			fileName = fmt.Sprintf("%d-%s", time.Now().UnixNano(), handler.Filename)
-			filePath = fmt.Sprintf("ui/static/upload/%s", fileName)
-			if err := os.MkdirAll("ui/static/upload", os.ModePerm); err != nil {
+			fileName = fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(handler.Filename))
+			filePath := filepath.Join(app.uploadDir, fileName)
+			if err := os.MkdirAll(app.uploadDir, os.ModePerm); err != nil {
 				app.serverError(w, err)
 				return
 			}
@@ -651,58 +738,45 @@ func (app *application) EditPost(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		id, err := app.getCurrentUser(r)
+		editorID, err := app.getCurrentUser(r)
 		if err != nil {
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
 		}
-		author, err := app.users.Get(id)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
-		strID := r.PostForm.Get("id")
-		intID, err := strconv.Atoi(strID)
-		if err != nil {
-			app.serverError(w, err)
-			return
-		}
+		// Автор остаётся прежним, даже если пост правит модератор; редактор попадёт в историю правок
 		form := editPost{
 			ID:        intID,
 			Title:     r.PostForm.Get("title"),
 			Content:   r.PostForm.Get("content"),
-			ImagePath: filePath, // Путь к изображению только если файл был загружен
-			Category:  r.PostForm.Get("category"),
-			Author:    author.Name,
-			AuthorID:  author.ID,
+			ImagePath: fileName, // Имя изображения только если файл был загружен
+			Author:    post.Author,
+			AuthorID:  post.AuthorID,
 		}
 
-		form.ImagePath = strings.TrimPrefix(form.ImagePath, "ui/static/upload/")
 		// Валидация полей
 		form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
 		form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be longer than 100 characters")
 		form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-		if !form.Valid() {
-			data := app.newTemplateData(w, r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "edit_post.html", data)
-			return
-		}
-
-		// Получаем текущий пост
-
-		post, err := app.posts.Get(form.ID)
+		form.Categories, err = app.checkPostCategories(&form.Validator, r.PostForm["category"])
 		if err != nil {
 			app.serverError(w, err)
 			return
 		}
+		if !form.Valid() {
+			if err := app.renderPostForm(w, r, http.StatusUnprocessableEntity, "edit_post.html", form); err != nil {
+				app.serverError(w, err)
+			}
+			return
+		}
+
 		if form.ImagePath == "" {
 			form.ImagePath = post.ImagePath
 		}
-		app.infoLog.Printf("Updating post: title=%s, content=%s, imagePath=%s, category=%s, author=%s", form.Title, form.Content, form.ImagePath, form.Category, form.Author)
-		err = app.posts.UpdatePost(form.Title, form.Content, form.ImagePath, form.Category, form.Author, form.AuthorID, form.ID)
+		app.infoLog.Printf("Updating post: title=%s, content=%s, imagePath=%s, categories=%v, author=%s", form.Title, form.Content, form.ImagePath, form.Categories, form.Author)
+		err = app.posts.UpdatePost(form.Title, form.Content, form.ImagePath, form.Author, form.AuthorID, form.ID, editorID, form.Categories)
 		if err != nil {
 			app.serverError(w, err)
+			return
 		}
 		app.flash(w, r, "Post edited successfully!")
 		// Перенаправляем на страницу профиля
@@ -756,7 +830,7 @@ func (app *application) DeletePost(w http.ResponseWriter, r *http.Request) {
 
 	// Удаление файла если есть
 	if path != "" {
-		if err := os.Remove("./ui/static/upload/" + path); err != nil {
+		if err := os.Remove(filepath.Join(app.uploadDir, path)); err != nil {
 			app.errorLog.Println("Failed to delete image:", err)
 		}
 	}
@@ -827,25 +901,30 @@ func (app *application) addComment(w http.ResponseWriter, r *http.Request) {
 		Created: time.Now(),
 	}
 
+	// Ответ на другой комментарий того же поста
+	var parent *models2.Comment
+	if parentParam := r.FormValue("parent_id"); parentParam != "" {
+		parentID, err := strconv.Atoi(parentParam)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		parent, err = app.comments.GetByID(parentID)
+		if err != nil || parent.PostID != id {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		comment.ParentID = parent.ID
+	}
+
 	// Сохраняем комментарий в базе данных
 	err = app.comments.Insert(comment)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	post, err := app.posts.Get(comment.PostID)
-	if err == nil && post.AuthorID != comment.UserID {
-		// Создаем уведомление для автора поста
-		err = app.notificationsModel.Insert(
-			post.AuthorID,
-			comment.UserID,
-			"comment",
-			post.ID,
-			comment.ID,
-		)
-		if err != nil {
-			app.errorLog.Println("Failed to create notification:", err)
-		}
+	if post, err := app.posts.Get(comment.PostID); err == nil {
+		app.notifyComment(post, parent, comment)
 	}
 	// Перенаправляем на страницу поста с комментариями
 	app.flash(w, r, "Comment added successfully!")
@@ -898,376 +977,6 @@ func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
 	app.flash(w, r, "Comment deleted successfully!")
 	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
 }
-func (app *application) likePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	postIDStr := r.FormValue("post_id")
-	if postIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.LikePost(postID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	post, err := app.posts.Get(postID)
-	if err == nil && post.AuthorID != userID {
-		err = app.notificationsModel.Insert(
-			post.AuthorID,
-			userID,
-			"post_like",
-			postID,
-			0,
-		)
-		if err != nil {
-			app.errorLog.Println("Failed to create notification:", err)
-		}
-	}
-
-	app.flash(w, r, "Post liked successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
-}
-
-func (app *application) dislikePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	postIDStr := r.FormValue("post_id")
-	if postIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.DislikePost(postID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	post, err := app.posts.Get(postID)
-	if err == nil && post.AuthorID != userID {
-		err = app.notificationsModel.Insert(
-			post.AuthorID,
-			userID,
-			"post_dislike",
-			postID,
-			0,
-		)
-		if err != nil {
-			app.errorLog.Println("Failed to create notification:", err)
-		}
-	}
-	app.flash(w, r, "Post disliked successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
-}
-
-func (app *application) removeLikePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	postIDStr := r.FormValue("post_id")
-	if postIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.RemoveLikePost(postID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-
-	app.flash(w, r, "Like removed successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
-}
-
-func (app *application) removeDislikePost(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	postIDStr := r.FormValue("post_id")
-	if postIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.RemoveDislikePost(postID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-
-	app.flash(w, r, "Dislike removed successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
-}
-func (app *application) likeComment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	commentIDStr := r.FormValue("comment_id")
-	if commentIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	commentID, err := strconv.Atoi(commentIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.LikeComment(commentID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	comment, err := app.comments.GetByID(commentID)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-	post, err := app.posts.Get(comment.PostID)
-	if err == nil && post.AuthorID != comment.UserID {
-		// Создаем уведомление для автора поста
-		err = app.notificationsModel.Insert(
-			post.AuthorID,
-			comment.UserID,
-			"comment_like",
-			post.ID,
-			comment.ID,
-		)
-		if err != nil {
-			app.errorLog.Println("Failed to create notification:", err)
-		}
-	}
-	app.flash(w, r, "Comment liked successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", comment.PostID), http.StatusSeeOther)
-}
-
-func (app *application) dislikeComment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	commentIDStr := r.FormValue("comment_id")
-	if commentIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	commentID, err := strconv.Atoi(commentIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.DislikeComment(commentID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	comment, err := app.comments.GetByID(commentID)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-	post, err := app.posts.Get(comment.PostID)
-	if err == nil && post.AuthorID != comment.UserID {
-		// Создаем уведомление для автора поста
-		err = app.notificationsModel.Insert(
-			post.AuthorID,
-			comment.UserID,
-			"comment_dislike",
-			post.ID,
-			comment.ID,
-		)
-		if err != nil {
-			app.errorLog.Println("Failed to create notification:", err)
-		}
-	}
-	app.flash(w, r, "Comment disliked successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", comment.PostID), http.StatusSeeOther)
-}
-
-func (app *application) removeLikeComment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	commentIDStr := r.FormValue("comment_id")
-	if commentIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	commentID, err := strconv.Atoi(commentIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.RemoveLikeComment(commentID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	comment, err := app.comments.GetByID(commentID)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-	app.flash(w, r, "Like removed successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", comment.PostID), http.StatusSeeOther)
-}
-
-func (app *application) removeDislikeComment(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		app.methodNotAllowed(w)
-		return
-	}
-
-	commentIDStr := r.FormValue("comment_id")
-	if commentIDStr == "" {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	commentID, err := strconv.Atoi(commentIDStr)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.clientError(w, http.StatusUnauthorized)
-		return
-	}
-
-	err = app.reactions.RemoveDislikeComment(commentID, userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-	comment, err := app.comments.GetByID(commentID)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
-		return
-	}
-	app.flash(w, r, "Dislike removed successfully!")
-	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", comment.PostID), http.StatusSeeOther)
-}
-func (app *application) notifications(w http.ResponseWriter, r *http.Request) {
-
-	if !app.isAuthenticated(r) {
-		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-		return
-	}
-
-	userID, err := app.getCurrentUser(r)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-
-	// Получаем уведомления
-	notifications, err := app.notificationsModel.GetAll(userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-
-	// Помечаем как прочитанные
-	err = app.notificationsModel.MarkAllAsRead(userID)
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
-
-	data := app.newTemplateData(w, r)
-	data.Notifications = notifications
-	app.render(w, http.StatusOK, "notifications.html", data)
-}
 func (app *application) manageCategories(w http.ResponseWriter, r *http.Request) {
 	// Проверка прав администратора
 	userID, err := app.getCurrentUser(r)
@@ -1294,7 +1003,7 @@ func (app *application) addCategory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	name := r.FormValue("name")
-	if err := app.categories.Insert(name); err != nil {
+	if _, err := app.categories.Insert(name); err != nil {
 		if errors.Is(err, models2.ErrDuplicateCategory) {
 			app.flash(w, r, "Category already exists!")
 		} else {
@@ -1313,7 +1022,16 @@ func (app *application) updateCategory(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(r.FormValue("id"))
 	newName := r.FormValue("name")
 	if err := app.categories.Update(id, newName); err != nil {
-		app.serverError(w, err)
+		switch {
+		case errors.Is(err, models2.ErrDuplicateCategory):
+			app.flash(w, r, "Category already exists!")
+		case errors.Is(err, models2.ErrNoRecord):
+			app.notFound(w)
+			return
+		default:
+			app.serverError(w, err)
+			return
+		}
 	}
 	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
 }
@@ -2,12 +2,25 @@
 package main
 
 import (
+	"bytes"
+	"database/sql"
 	"fmt"
+	"forum-app/internal/config"
+	"forum-app/internal/events"
+	"forum-app/internal/mailer"
+	"forum-app/internal/migrations"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/signer"
 	"html/template"
+	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestHomeHandler(t *testing.T) {
@@ -31,9 +44,59 @@ func newTestApplication(t *testing.T) *application {
 		t.Fatal(err)
 	}
 
+	db := newTestDB(t)
+	hub := events.NewHub()
+	emailTemplates, err := newEmailTemplates("./ui/html/emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := config.Default()
 	return &application{
-		templateCache: templateCache,
+		errorLog:           log.New(io.Discard, "", 0),
+		infoLog:            log.New(io.Discard, "", 0),
+		posts:              &models2.PostModel{DB: db},
+		users:              &models2.UserModel{DB: db},
+		comments:           &models2.CommentModel{DB: db},
+		categories:         &models2.CategoryModel{DB: db},
+		notificationsModel: &models2.NotificationModel{DB: db, Events: hub},
+		reactions:          &models2.ReactionModel{DB: db},
+		reports:            &models2.ReportModel{DB: db},
+		templateCache:      templateCache,
+		sessions:           models2.NewMemorySessionStore(),
+		apiTokens:          &models2.APITokenModel{DB: db},
+		searchModel:        &models2.SearchModel{DB: db},
+		notificationHub:    hub,
+		mailer:             &mailer.FileMailer{},
+		outbox:             &models2.OutboxModel{DB: db},
+		passwordResets:     &models2.PasswordResetModel{DB: db},
+		twoFactor:          &models2.TwoFactorModel{DB: db},
+		loginFailures:      &models2.LoginFailureModel{DB: db},
+		signer:             signer.New([]byte("test-signing-key")),
+		emailTemplates:     emailTemplates,
+		baseURL:            "http://forum.test",
+		uploadDir:          t.TempDir(),
+		maxUploadSize:      defaults.MaxUploadSize,
+		maxBodySize:        defaults.MaxBodySize,
+		sessionTTL:         defaults.SessionTTL,
+		now:                time.Now,
+	}
+}
+
+// newTestDB создаёт пустую базу во временной директории и прогоняет миграции
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := connectDB(filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := (&migrations.Migrator{DB: db}).Up(); err != nil {
+		t.Fatal(err)
 	}
+	return db
 }
 
 func TestLoginRedirectWhenUnauthenticated(t *testing.T) {
@@ -115,3 +178,43 @@ func newTemplateCache1() (map[string]*template.Template, error) {
 
 	return cache, nil
 }
+
+func TestUploadSizeLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.maxUploadSize = 4 << 10
+	alice := loginAs(t, app, "alice", "user")
+	token := app.signer.MAC(csrfTokenPurpose, "session:"+alice.Value)
+
+	// Токен и в поле формы (тело читает csrf), и в заголовке (тело читает обработчик)
+	for _, inHeader := range []bool{false, true} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if !inHeader {
+			mw.WriteField(csrfFieldName, token)
+		}
+		mw.WriteField("title", "Big picture")
+		mw.WriteField("content", "Too large")
+		fw, err := mw.CreateFormFile("image", "big.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(bytes.Repeat([]byte{0}, 8<<10))
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/post/create", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		if inHeader {
+			req.Header.Set(csrfHeaderName, token)
+		}
+		req.AddCookie(alice)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d (token in header: %v), got %d", http.StatusRequestEntityTooLarge, inHeader, rr.Code)
+		}
+	}
+
+	if files, _ := os.ReadDir(app.uploadDir); len(files) != 0 {
+		t.Errorf("Expected nothing to be saved, got %d files", len(files))
+	}
+}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/validator"
+	"io"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -123,6 +131,9 @@ func (app *application) newTemplateData(w http.ResponseWriter, r *http.Request)
 
 		}
 	}
+	data.CSRFToken = csrfToken(r)
+	data.GoogleLogin = app.googleOAuth != nil
+	data.GitHubLogin = app.githubOAuth != nil
 
 	return data
 }
@@ -145,3 +156,220 @@ func (app *application) isAdmin(userID int) bool {
 	user, err := app.users.Get(userID)
 	return err == nil && user.Role == "admin"
 }
+
+// envelope оборачивает JSON-ответы API: {"post": ...}, {"error": ...}
+type envelope map[string]any
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.errorLog.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
+
+// readJSON декодирует тело запроса в dst; лишние поля и несколько JSON-значений считаются ошибкой
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, app.maxBodySize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+	return nil
+}
+
+// Сколько multipart-формы держится в памяти; остальное уходит во временные файлы
+const multipartMemory = 1 << 20
+
+// parseForm разбирает тело формы (обычной или multipart), читая не больше maxUploadSize.
+// Слишком большое тело получает 413, битое — 400; false значит, что ответ уже отправлен.
+func (app *application) parseForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, app.maxUploadSize)
+
+	err := r.ParseForm()
+	if err == nil {
+		if err = r.ParseMultipartForm(multipartMemory); errors.Is(err, http.ErrNotMultipart) {
+			err = nil
+		}
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return false
+	case err != nil:
+		app.clientError(w, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// apiServerError — JSON-аналог serverError
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Printf("%s\n%s", err.Error(), debug.Stack())
+	app.apiError(w, http.StatusInternalServerError, "Internal Server Error")
+}
+
+// apiClientError — JSON-аналог clientError
+func (app *application) apiClientError(w http.ResponseWriter, status int) {
+	app.apiError(w, status, http.StatusText(status))
+}
+
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, envelope{"error": envelope{"status": status, "message": message}})
+}
+
+// apiValidationError возвращает ошибки полей формы так же, как их видит HTML-шаблон
+func (app *application) apiValidationError(w http.ResponseWriter, v validator.Validator) {
+	status := http.StatusUnprocessableEntity
+	app.writeJSON(w, status, envelope{"error": envelope{
+		"status":  status,
+		"message": http.StatusText(status),
+		"fields":  v.FieldErrors,
+	}})
+}
+
+var errUnknownCategory = errors.New("unknown category")
+
+// resolveCategories переводит значения из формы или запроса (id или названия) в id существующих категорий
+func (app *application) resolveCategories(values []string) ([]int, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	categories, err := app.categories.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, _ := strconv.Atoi(value)
+		found := false
+		for _, c := range categories {
+			if c.ID == id || strings.EqualFold(c.Name, value) {
+				ids = append(ids, c.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errUnknownCategory
+		}
+	}
+	return ids, nil
+}
+
+// readCategoryFilter разбирает ?category=1&category=4&match=all (по умолчанию — любая из категорий)
+func (app *application) readCategoryFilter(query url.Values) (models2.CategoryFilter, error) {
+	ids, err := app.resolveCategories(query["category"])
+	if err != nil {
+		return models2.CategoryFilter{}, err
+	}
+	return models2.CategoryFilter{IDs: ids, MatchAll: query.Get("match") == "all"}, nil
+}
+
+// readPersonalFilter разбирает ?mine=1&liked=1&disliked=1&commented=1; пользователя подставляет обработчик
+func readPersonalFilter(query url.Values) models2.PersonalFilter {
+	flag := func(name string) bool {
+		switch query.Get(name) {
+		case "1", "true", "on":
+			return true
+		}
+		return false
+	}
+	return models2.PersonalFilter{
+		Mine:      flag("mine"),
+		Liked:     flag("liked"),
+		Disliked:  flag("disliked"),
+		Commented: flag("commented"),
+	}
+}
+
+// feedPageSize — сколько постов на одной странице ленты
+const feedPageSize = 20
+
+// readPostQuery читает параметры, общие для всех лент: ?sort=hot&after=<курсор> или &before=<курсор>.
+// Курсоры проверяет PostModel.Page, здесь — только режим сортировки.
+func readPostQuery(query url.Values) (models2.PostQuery, error) {
+	q := models2.PostQuery{
+		Sort:   query.Get("sort"),
+		After:  query.Get("after"),
+		Before: query.Get("before"),
+		Limit:  feedPageSize,
+	}
+	if q.Sort == "" {
+		q.Sort = models2.SortNewest
+	}
+	if !models2.ValidPostSort(q.Sort) {
+		return q, models2.ErrInvalidSort
+	}
+	return q, nil
+}
+
+// isPageError сообщает, что параметры пагинации пришли от клиента некорректными
+func isPageError(err error) bool {
+	return errors.Is(err, models2.ErrInvalidSort) || errors.Is(err, models2.ErrInvalidCursor)
+}
+
+// pageURL строит ссылку на соседнюю страницу ленты с теми же фильтрами; param — after или before
+func pageURL(r *http.Request, param, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	query := r.URL.Query()
+	query.Del("after")
+	query.Del("before")
+	query.Set(param, cursor)
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// setPage кладёт страницу ленты в данные шаблона вместе со ссылками на соседние
+func (data *templateData) setPage(r *http.Request, page *models2.PostPage) {
+	data.Posts = page.Posts
+	data.Sort = page.Sort
+	data.Sorts = models2.PostSorts
+	data.PrevPage = pageURL(r, "before", page.Prev)
+	data.NextPage = pageURL(r, "after", page.Next)
+}
+
+// checkPostCategories проверяет категории, выбранные в форме поста: хотя бы одна и все существуют
+func (app *application) checkPostCategories(v *validator.Validator, values []string) ([]int, error) {
+	ids, err := app.resolveCategories(values)
+	if errors.Is(err, errUnknownCategory) {
+		v.AddFieldError("category", "Choose categories from the list")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	v.CheckField(len(ids) > 0, "category", "Choose at least one category")
+	return ids, nil
+}
+
+// renderPostForm показывает форму создания или редактирования поста со списком всех категорий
+func (app *application) renderPostForm(w http.ResponseWriter, r *http.Request, status int, page string, form any) error {
+	categories, err := app.categories.GetAll()
+	if err != nil {
+		return err
+	}
+	data := app.newTemplateData(w, r)
+	data.Form = form
+	data.Categories = categories
+	app.render(w, status, page, data)
+	return nil
+}
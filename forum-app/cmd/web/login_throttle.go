@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	models2 "forum-app/internal/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// throttlePolicy — как неудачные входы замедляют следующие попытки
+type throttlePolicy struct {
+	free    int           // столько неудач не вызывают задержки
+	lockout int           // начиная с этого числа — блокировка на lockFor
+	lockFor time.Duration // она же — предел для задержек
+}
+
+var (
+	// Аккаунт: с 3-й неудачи пауза 1, 2, 4... секунды, с 10-й — блокировка на 15 минут
+	accountThrottle = throttlePolicy{free: 3, lockout: 10, lockFor: 15 * time.Minute}
+	// IP: перебор по многим адресам с одного IP, пороги выше — за одним IP бывает много людей
+	ipThrottle = throttlePolicy{free: 20, lockout: 100, lockFor: 15 * time.Minute}
+)
+
+// За какой срок считаются неудачные входы
+const loginFailureWindow = time.Hour
+
+// delay — сколько ждать после последней из failures неудач
+func (p throttlePolicy) delay(failures int) time.Duration {
+	switch {
+	case failures >= p.lockout:
+		return p.lockFor
+	case failures < p.free:
+		return 0
+	}
+	return min(time.Second<<(failures-p.free), p.lockFor)
+}
+
+// wait — сколько осталось ждать до следующей попытки; 0 — можно сейчас
+func (p throttlePolicy) wait(failures int, last, now time.Time) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+	return max(last.Add(p.delay(failures)).Sub(now), 0)
+}
+
+// loginWait возвращает, сколько ждать до следующей попытки входа в аккаунт email с адреса ip
+func (app *application) loginWait(email, ip string, now time.Time) (time.Duration, error) {
+	f, err := app.loginFailures.Count(email, ip, now.Add(-loginFailureWindow))
+	if err != nil {
+		return 0, err
+	}
+	return max(accountThrottle.wait(f.Account, f.AccountLast, now), ipThrottle.wait(f.IP, f.IPLast, now)), nil
+}
+
+// recordLoginFailure записывает неудачный вход; при блокировке аккаунта владелец получает письмо
+func (app *application) recordLoginFailure(email, ip string, now time.Time) error {
+	if err := app.loginFailures.Record(email, ip, now); err != nil {
+		return err
+	}
+	f, err := app.loginFailures.Count(email, ip, now.Add(-loginFailureWindow))
+	if err != nil {
+		return err
+	}
+	if f.Account != accountThrottle.lockout {
+		return nil
+	}
+
+	user, err := app.users.GetByEmail(email)
+	if errors.Is(err, models2.ErrNoRecord) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	app.infoLog.Printf("Account %d locked after %d failed logins, last from %s", user.ID, f.Account, ip)
+	return app.queueEmail(user.Email, "account_locked", &emailData{
+		Name: user.Name,
+		Link: app.baseURL + "/user/password/forgot",
+	})
+}
+
+// tooManyLogins отвечает 429 со страницей page и Retry-After
+func (app *application) tooManyLogins(w http.ResponseWriter, r *http.Request, page string, form interface{ AddNonFieldError(string) }, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts. Please try again in %s.", humanWait(wait)))
+	data := app.newTemplateData(w, r)
+	data.Form = form
+	app.render(w, http.StatusTooManyRequests, page, data)
+}
+
+// humanWait округляет паузу до понятного человеку вида: "5 seconds", "15 minutes"
+func humanWait(d time.Duration) string {
+	if d < time.Minute {
+		s := int(math.Ceil(d.Seconds()))
+		if s == 1 {
+			return "1 second"
+		}
+		return strconv.Itoa(s) + " seconds"
+	}
+	m := int(math.Ceil(d.Minutes()))
+	if m == 1 {
+		return "1 minute"
+	}
+	return strconv.Itoa(m) + " minutes"
+}
+
+// pruneLoginFailures каждые interval удаляет попытки, вышедшие из окна, пока не закрыт done
+func (app *application) pruneLoginFailures(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := app.loginFailures.Prune(app.now().Add(-loginFailureWindow)); err != nil {
+				app.errorLog.Println("Failed to prune login failures:", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// lockedAccounts — /admin/locked-accounts: аккаунты, заблокированные после неудачных входов
+func (app *application) lockedAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	now := app.now()
+	accounts, err := app.loginFailures.Locked(accountThrottle.lockout, now.Add(-loginFailureWindow), now.Add(-accountThrottle.lockFor))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data := app.newTemplateData(w, r)
+	data.LockedAccounts = accounts
+	app.render(w, http.StatusOK, "locked_accounts.html", data)
+}
+
+// unlockAccount — /admin/locked-accounts/unlock: снимает блокировку, забывая неудачные входы
+func (app *application) unlockAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if err := app.loginFailures.Clear(user.Email); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.flash(w, r, "Account "+user.Email+" unlocked.")
+	http.Redirect(w, r, "/admin/locked-accounts", http.StatusSeeOther)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Second,
+		4:  2 * time.Second,
+		9:  64 * time.Second,
+		10: 15 * time.Minute,
+		25: 15 * time.Minute,
+	} {
+		if got := accountThrottle.delay(failures); got != want {
+			t.Errorf("After %d failures: expected %s, got %s", failures, want, got)
+		}
+	}
+}
+
+func TestLoginWaitPrecision(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Пауза отсчитывается от момента неудачи, а не от начала её секунды
+	last := time.Date(2026, 3, 1, 12, 0, 0, 900*int(time.Millisecond), time.UTC)
+	for range 3 {
+		if err := app.recordLoginFailure("alice@example.com", "198.51.100.7", last); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := app.loginWait("alice@example.com", "198.51.100.7", last.Add(500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("Expected 500ms left to wait, got %s", wait)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+	admin := loginAs(t, app, "root", "admin")
+	// Часы стоят: паузы не должны истекать, пока тест ждёт bcrypt
+	now := time.Now()
+	app.now = func() time.Time { return now }
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		form := url.Values{"email": {email}, "password": {password}}
+		req := httptest.NewRequest("POST", "/user/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Первые неудачи без задержки, после третьей нужно подождать
+	for i := 1; i <= 3; i++ {
+		if rr := login("alice@example.com", "WrongPass123!"); rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Attempt %d: expected status %d, got %d", i, http.StatusUnprocessableEntity, rr.Code)
+		}
+	}
+	rr := login("alice@example.com", "ValidPass123!")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Expected a delay after 3 failures, got %d (Retry-After %q)", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	// Неизвестный адрес замедляется так же — по ответу не понять, есть ли аккаунт
+	for range 3 {
+		login("nobody@example.com", "WrongPass123!")
+	}
+	if rr := login("nobody@example.com", "WrongPass123!"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected unknown emails to be throttled too, got %d", rr.Code)
+	}
+
+	// Блокировка: десятая неудача за час блокирует аккаунт на 15 минут и отправляет письмо
+	base := now.Add(-5 * time.Minute)
+	for i := range 7 {
+		if err := app.recordLoginFailure("alice@example.com", "198.51.100.7", base.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var to, subject string
+	err := app.outbox.DB.QueryRow(`SELECT recipient, subject FROM email_outbox ORDER BY id DESC LIMIT 1`).Scan(&to, &subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to != "alice@example.com" || !strings.Contains(subject, "locked") {
+		t.Errorf("Expected a lockout email to alice, got %q to %s", subject, to)
+	}
+	if rr := login("alice@example.com", "ValidPass123!"); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected the locked account to refuse the right password, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/locked-accounts", nil)
+	req.AddCookie(admin)
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "alice@example.com") || strings.Contains(rr.Body.String(), "nobody@example.com") {
+		t.Errorf("Expected the admin page to list alice only")
+	}
+
+	req = httptest.NewRequest("POST", "/admin/locked-accounts/unlock", strings.NewReader("user_id=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(admin)
+	addCSRFToken(app, req)
+	app.routes().ServeHTTP(httptest.NewRecorder(), req)
+	if rr := login("alice@example.com", "ValidPass123!"); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" {
+		t.Fatalf("Expected login to work after the unlock, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	// Перебор по многим адресам с одного IP упирается в лимит IP
+	for i := range ipThrottle.free {
+		if err := app.loginFailures.Record(fmt.Sprintf("user%d@example.com", i), "192.0.2.1", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rr := login("alice@example.com", "ValidPass123!"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the IP to be throttled, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"forum-app/internal/mailer"
+	models2 "forum-app/internal/models"
+	"html/template"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// emailTemplate — письмо из ui/html/emails/<name>.tmpl с блоками subject, text и html.
+// Тема и текстовая версия рендерятся text/template, чтобы в них не появлялись HTML-сущности.
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *template.Template
+}
+
+// emailData — данные для шаблонов писем
+type emailData struct {
+	BaseURL       string // адрес сайта для ссылок, без слэша в конце
+	Name          string // имя получателя
+	Link          string // ссылка действия письма: сброс пароля, подтверждение адреса
+	Notifications []*models2.Notification
+}
+
+// newEmailTemplates разбирает все шаблоны писем из dir
+func newEmailTemplates(dir string) (map[string]*emailTemplate, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	cache := map[string]*emailTemplate{}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		text, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(functions)).ParseFiles(file)
+		if err != nil {
+			return nil, err
+		}
+		html, err := template.New(name).Funcs(functions).ParseFiles(file)
+		if err != nil {
+			return nil, err
+		}
+		cache[name] = &emailTemplate{text: text, html: html}
+	}
+	return cache, nil
+}
+
+// renderEmail собирает письмо по шаблону name
+func (app *application) renderEmail(to, name string, data *emailData) (*mailer.Message, error) {
+	ts, ok := app.emailTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("the email template %s does not exist", name)
+	}
+	data.BaseURL = strings.TrimSuffix(app.baseURL, "/")
+
+	var subject, text, html bytes.Buffer
+	if err := ts.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := ts.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, err
+	}
+	if err := ts.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, err
+	}
+	return &mailer.Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()),
+	}, nil
+}
+
+// queueEmail рендерит письмо и кладёт его в outbox; отправит его deliverEmails
+func (app *application) queueEmail(to, name string, data *emailData) error {
+	msg, err := app.renderEmail(to, name, data)
+	if err != nil {
+		return err
+	}
+	_, err = app.outbox.Enqueue(msg.To, msg.Subject, msg.Text, msg.HTML)
+	return err
+}
+
+// Сколько писем отправляется за один проход
+const outboxBatch = 50
+
+// deliverDueEmails отправляет письма, время которых пришло; неудачные откладываются с нарастающей паузой
+func (app *application) deliverDueEmails(now time.Time) (sent, failed int, err error) {
+	emails, err := app.outbox.Due(now, outboxBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, e := range emails {
+		sendErr := app.mailer.Send(&mailer.Message{To: e.To, Subject: e.Subject, Text: e.Text, HTML: e.HTML})
+		if sendErr != nil {
+			failed++
+			app.errorLog.Printf("Failed to send email %d to %s (attempt %d): %v", e.ID, e.To, e.Attempts+1, sendErr)
+			if err := app.outbox.MarkFailed(e.ID, sendErr, now); err != nil {
+				return sent, failed, err
+			}
+			continue
+		}
+		sent++
+		if err := app.outbox.MarkSent(e.ID, now); err != nil {
+			return sent, failed, err
+		}
+	}
+	return sent, failed, nil
+}
+
+// deliverEmails каждые interval отправляет письма из outbox, пока не закрыт done
+func (app *application) deliverEmails(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sent, failed, err := app.deliverDueEmails(time.Now())
+			if err != nil {
+				app.errorLog.Println("Failed to deliver emails:", err)
+				continue
+			}
+			if sent > 0 || failed > 0 {
+				app.infoLog.Printf("Sent %d email(s), %d failed", sent, failed)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// Сколько отправленные письма (уже без текста) хранятся в outbox
+const outboxRetention = 7 * 24 * time.Hour
+
+// pruneOutbox каждые interval удаляет давно отправленные письма, пока не закрыт done
+func (app *application) pruneOutbox(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := app.outbox.PruneSent(time.Now().Add(-outboxRetention)); err != nil {
+				app.errorLog.Println("Failed to prune sent emails:", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// queueDigests собирает уведомления с каналом email в одно письмо на получателя
+func (app *application) queueDigests() (int, error) {
+	queue, err := app.notificationsModel.DigestQueue()
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for userID, notifications := range queue {
+		user, err := app.users.Get(userID)
+		if err != nil {
+			return queued, err
+		}
+		if err := app.queueEmail(user.Email, "digest", &emailData{Name: user.Name, Notifications: notifications}); err != nil {
+			return queued, err
+		}
+		ids := make([]int, len(notifications))
+		for i, n := range notifications {
+			ids[i] = n.ID
+		}
+		if err := app.notificationsModel.MarkDigested(ids); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// sendDigests каждые interval ставит в очередь дайджесты, пока не закрыт done
+func (app *application) sendDigests(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := app.queueDigests()
+			if err != nil {
+				app.errorLog.Println("Failed to queue digests:", err)
+				continue
+			}
+			if n > 0 {
+				app.infoLog.Printf("Queued %d notification digest(s)", n)
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"forum-app/internal/mailer"
+	"forum-app/internal/mailer/smtptest"
+	models2 "forum-app/internal/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailDigestAndOutbox(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+	loginAs(t, app, "bob", "user")
+	srv := smtptest.NewServer(t)
+	app.mailer = &mailer.SMTP{Host: srv.Host, Port: srv.Port, From: "Forum <noreply@forum.test>"}
+
+	postID, err := app.posts.Insert("post", "body", "", "bob", "approved", 2, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.notificationsModel.SetPreferences(1, map[string]string{models2.CategoryMention: models2.ChannelEmail}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.notificationsModel.Insert(1, 2, models2.NotificationMention, postID, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	queued, err := app.queueDigests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 1 {
+		t.Fatalf("Expected 1 digest, got %d", queued)
+	}
+	if again, _ := app.queueDigests(); again != 0 {
+		t.Errorf("Expected digested notifications not to be sent twice, got %d", again)
+	}
+
+	var subject, text, html string
+	err = app.outbox.DB.QueryRow(`SELECT subject, text_body, html_body FROM email_outbox`).Scan(&subject, &text, &html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := "http://forum.test/post/view/" + strconv.Itoa(postID) + "#comment-5"
+	if subject != "Your forum digest: 1 new notification" || !strings.Contains(text, "bob mentioned you in a comment") ||
+		!strings.Contains(text, link) || !strings.Contains(html, `href="`+link+`"`) {
+		t.Errorf("Unexpected digest %q\n%s\n%s", subject, text, html)
+	}
+
+	// Сервер временно отказывает: письмо откладывается, а не теряется
+	now := time.Now()
+	srv.Fail("451 try again later")
+	if sent, failed, err := app.deliverDueEmails(now); err != nil || sent != 0 || failed != 1 {
+		t.Fatalf("Expected 1 failed delivery, got %d sent, %d failed (%v)", sent, failed, err)
+	}
+	if due, _ := app.outbox.Due(now, 10); len(due) != 0 {
+		t.Errorf("Expected the failed email to wait for its backoff")
+	}
+
+	srv.Fail("")
+	later := now.Add(models2.OutboxBackoff(1) + time.Second)
+	if sent, failed, err := app.deliverDueEmails(later); err != nil || sent != 1 || failed != 0 {
+		t.Fatalf("Expected 1 sent email, got %d sent, %d failed (%v)", sent, failed, err)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].To[0] != "alice@example.com" {
+		t.Fatalf("Expected the digest to reach alice, got %+v", msgs)
+	}
+	if due, _ := app.outbox.Due(later.Add(time.Hour), 10); len(due) != 0 {
+		t.Errorf("Expected sent emails to leave the queue")
+	}
+
+	// Текст отправленного письма не хранится, а сама запись со временем удаляется
+	if err := app.outbox.DB.QueryRow(`SELECT text_body || html_body FROM email_outbox`).Scan(&text); err != nil || text != "" {
+		t.Errorf("Expected the sent email's body to be erased, got %q (%v)", text, err)
+	}
+	if n, err := app.outbox.PruneSent(later); err != nil || n != 0 {
+		t.Errorf("Expected a fresh email to be kept, got %d pruned (%v)", n, err)
+	}
+	if n, err := app.outbox.PruneSent(later.Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("Expected the sent email to be pruned, got %d (%v)", n, err)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		20: 6 * time.Hour,
+	} {
+		if got := models2.OutboxBackoff(attempts); got != want {
+			t.Errorf("OutboxBackoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"flag"
 	"fmt"
+	"forum-app/internal/config"
+	"forum-app/internal/events"
+	"forum-app/internal/mailer"
+	"forum-app/internal/migrations"
 	models2 "forum-app/internal/models"
+	"forum-app/internal/signer"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/oauth2"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -26,9 +33,30 @@ type application struct {
 	reactions          *models2.ReactionModel
 	notificationsModel *models2.NotificationModel
 	templateCache      map[string]*template.Template
-	sessions           map[string]int
-	mu                 sync.Mutex
+	sessions           models2.SessionStore
+	apiTokens          *models2.APITokenModel
 	reports            *models2.ReportModel
+	searchModel        *models2.SearchModel
+	notificationHub    *events.Hub
+	mailer             mailer.Mailer
+	outbox             *models2.OutboxModel
+	passwordResets     *models2.PasswordResetModel
+	signer             *signer.Signer // подписывает ссылки подтверждения адреса
+	twoFactor          *models2.TwoFactorModel
+	twoFactorRoles     map[string]bool // роли, которым 2FA обязательна
+	loginFailures      *models2.LoginFailureModel
+	rateLimiter        *rateLimiter    // nil — без ограничений
+	trustedProxies     []*net.IPNet    // прокси, чьему X-Forwarded-For можно верить
+	corsOrigins        map[string]bool // сайты, которым разрешены кросс-доменные запросы
+	emailTemplates     map[string]*emailTemplate
+	baseURL            string // адрес сайта для ссылок в письмах
+	uploadDir          string // картинки постов, отдаются по /static/upload/
+	maxUploadSize      int64  // предел формы с картинкой
+	maxBodySize        int64  // предел JSON-тела запроса к API
+	sessionTTL         time.Duration
+	googleOAuth        *oauth2.Config // nil — вход через провайдера выключен
+	githubOAuth        *oauth2.Config
+	now                func() time.Time // часы для учёта неудачных входов; тесты подставляют свои
 }
 
 var (
@@ -54,24 +82,80 @@ var (
 			Buckets: prometheus.DefBuckets,
 		},
 	)
+	rateLimitRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_rate_limited_total",
+			Help: "Requests rejected by the rate limiter",
+		},
+		[]string{"policy", "key"}, // key — "user" или "ip"
+	)
 )
 
 func init() {
-	prometheus.MustRegister(httpRequestsTotal, httpDuration, dbQueryDuration)
+	prometheus.MustRegister(httpRequestsTotal, httpDuration, dbQueryDuration, rateLimitRejections)
 }
 
 func main() {
-	// Адрес порта
-	addr := flag.String("addr", ":4000", "http service address")
-	dsn := "./data/forum.db"
-	flag.Parse()
+	// Команды обслуживания; остальные флаги — настройки из internal/config
+	migrate := flag.String("migrate", "", "run migrations and exit: up, down or status")
+	steps := flag.Int("steps", 1, "number of migrations to roll back with -migrate down")
+	repairReactions := flag.Bool("repair-reactions", false, "recompute like/dislike counters from the reactions table and exit")
 
 	// Логгеры для ошибок и информации
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	// Настройки: значения по умолчанию < файл -config < переменные FORUM_* < флаги
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.Getenv)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	infoLog.Printf("Configuration: %s", cfg)
+
+	// Режим миграций без запуска HTTP-сервера
+	if *migrate != "" {
+		if err := runMigrations(cfg.DSN, *migrate, *steps, infoLog); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	if *repairReactions {
+		if err := runRepairReactions(cfg.DSN, infoLog); err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	twoFactorRoles, err := parseTwoFactorRoles(cfg.Require2FA)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	policies, err := parseRatePolicies(cfg.RateLimits)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	origins, err := parseCORSOrigins(cfg.CORSOrigins)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	kinds := models2.DefaultReactionKinds
+	if cfg.Reactions != "" {
+		var err error
+		if kinds, err = models2.ParseReactionKinds(cfg.Reactions); err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
 	// Открытие базы данных
-	db, err := openDB(dsn)
+	db, err := openDB(cfg.DSN)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
@@ -84,6 +168,30 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	emailTemplates, err := newEmailTemplates("./ui/html/emails")
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Без SMTP письма складываются в файлы: удобно при разработке
+	var mail mailer.Mailer = &mailer.FileMailer{Dir: cfg.MailDir, From: cfg.MailFrom, Logger: infoLog}
+	if cfg.SMTPHost != "" {
+		mail = &mailer.SMTP{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUser, Password: string(cfg.SMTPPassword), From: cfg.MailFrom}
+	}
+
+	// Ключ подписи ссылок из писем. Без signing-key берётся случайный: ссылки перестанут работать после перезапуска
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Println("signing-key is not set, using a random key: email confirmation links will not survive a restart")
+	}
+
+	// Шина событий для живых уведомлений (/notifications/stream)
+	hub := events.NewHub()
+
 	// Инициализация структуры приложения
 	app := application{
 		errorLog:           errorLog,
@@ -92,33 +200,80 @@ func main() {
 		users:              &models2.UserModel{DB: db},
 		comments:           &models2.CommentModel{DB: db},
 		categories:         &models2.CategoryModel{DB: db},
-		notificationsModel: &models2.NotificationModel{DB: db},
-		reactions:          &models2.ReactionModel{DB: db},
+		notificationsModel: &models2.NotificationModel{DB: db, Events: hub},
+		reactions:          &models2.ReactionModel{DB: db, Kinds: kinds},
 		templateCache:      templateCache,
-		sessions:           make(map[string]int),
+		sessions:           &models2.SessionModel{DB: db},
+		apiTokens:          &models2.APITokenModel{DB: db},
 		reports:            &models2.ReportModel{DB: db}, // Добавляем поле reports корректно
+		searchModel:        &models2.SearchModel{DB: db},
+		notificationHub:    hub,
+		mailer:             mail,
+		outbox:             &models2.OutboxModel{DB: db},
+		passwordResets:     &models2.PasswordResetModel{DB: db},
+		twoFactor:          &models2.TwoFactorModel{DB: db},
+		loginFailures:      &models2.LoginFailureModel{DB: db},
+		twoFactorRoles:     twoFactorRoles,
+		rateLimiter:        newRateLimiter(policies),
+		trustedProxies:     proxies,
+		corsOrigins:        origins,
+		signer:             signer.New(signingKey),
+		emailTemplates:     emailTemplates,
+		baseURL:            cfg.BaseURL,
+		uploadDir:          cfg.UploadDir,
+		maxUploadSize:      cfg.MaxUploadSize,
+		maxBodySize:        cfg.MaxBodySize,
+		sessionTTL:         cfg.SessionTTL,
+		googleOAuth:        googleOAuthConfig(cfg.Google),
+		githubOAuth:        githubOAuthConfig(cfg.GitHub),
+		now:                time.Now,
+	}
+
+	// Фоновая очистка просроченных сессий и старых прочитанных уведомлений
+	go app.sweepSessions(10*time.Minute, nil)
+	go app.pruneLoginFailures(10*time.Minute, nil)
+	go app.sweepRateLimits(time.Minute, nil)
+	if cfg.NotificationRetention > 0 {
+		go app.pruneNotifications(cfg.NotificationRetention, time.Hour, nil)
 	}
 
-	rateLimiter := NewRateLimiter(&app, 3, 5)
-	limitedRouter := rateLimiter.Limit(app.routes())
+	// Отправка писем из outbox и дайджесты уведомлений
+	go app.deliverEmails(30*time.Second, nil)
+	go app.pruneOutbox(time.Hour, nil)
+	go app.sendDigests(cfg.DigestInterval, nil)
 
 	// Инициализация структуры сервера для использования errorLog и роутера
+
 	srv := &http.Server{
-		Addr:         *addr,
+		Addr:         cfg.Addr,
 		ErrorLog:     errorLog,
-		Handler:      limitedRouter,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		Handler:      app.routes(),
+		IdleTimeout:  cfg.IdleTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	}
 
 	// Запуск сервера с поддержкой HTTPS
-	infoLog.Printf("Starting server on http://localhost%s", *addr)
+	infoLog.Printf("Starting server on %s (%s)", cfg.Addr, cfg.BaseURL)
 	err = srv.ListenAndServe()
 	errorLog.Fatal(err)
 }
 
+// openDB открывает базу и применяет все недостающие миграции перед запуском сервера
 func openDB(dsn string) (*sql.DB, error) {
+	db, err := connectDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	migrator := &migrations.Migrator{DB: db}
+	if _, err = migrator.Up(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func connectDB(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dsn+"?_foreign_keys=on&_busy_timeout=5000")
 	if err != nil {
 		return nil, err
@@ -128,3 +283,60 @@ func openDB(dsn string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// runRepairReactions обрабатывает флаг -repair-reactions: счётчики постов и комментариев
+// пересчитываются по строкам reactions
+func runRepairReactions(dsn string, infoLog *log.Logger) error {
+	db, err := openDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fixed, err := (&models2.ReactionModel{DB: db}).RecomputeCounters()
+	if err != nil {
+		return err
+	}
+	infoLog.Printf("Fixed reaction counters on %d post(s) and comment(s)", fixed)
+	return nil
+}
+
+// runMigrations обрабатывает флаг -migrate: up, down (с -steps) или status
+func runMigrations(dsn, command string, steps int, infoLog *log.Logger) error {
+	db, err := connectDB(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator := &migrations.Migrator{DB: db}
+	switch command {
+	case "up":
+		n, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		infoLog.Printf("Applied %d migration(s)", n)
+	case "down":
+		n, err := migrator.Down(steps)
+		if err != nil {
+			return err
+		}
+		infoLog.Printf("Rolled back %d migration(s)", n)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Applied {
+				infoLog.Printf("%04d_%s\tapplied %s", s.Version, s.Name, s.AppliedAt.Format(time.RFC3339))
+			} else {
+				infoLog.Printf("%04d_%s\tpending", s.Version, s.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown -migrate command %q (want up, down or status)", command)
+	}
+	return nil
+}
@@ -1,4 +1,4 @@
-// test_main.go
+// main_test.go
 package main
 
 import (
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"golang.org/x/time/rate"
+	models2 "forum-app/internal/models"
 	"net/http"
+	"net/url"
 	"regexp"
-	"sync"
+	"strings"
 	"time"
 )
 
@@ -67,17 +70,23 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) requireRole(role string, next http.Handler) http.Handler {
+// requireVerifiedEmail пропускает только пользователей с подтверждённым адресом;
+// ставится после requireAuthentication
+func (app *application) requireVerifiedEmail(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, err := app.getCurrentUser(r)
 		if err != nil {
 			app.clientError(w, http.StatusUnauthorized)
 			return
 		}
-
 		user, err := app.users.Get(userID)
-		if err != nil || user.Role != role {
-			app.clientError(w, http.StatusForbidden)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !user.EmailVerified {
+			app.flash(w, r, "Please confirm your email address before posting. Check your inbox or resend the link from your profile.")
+			http.Redirect(w, r, "/user/profile/", http.StatusSeeOther)
 			return
 		}
 
@@ -85,62 +94,100 @@ func (app *application) requireRole(role string, next http.Handler) http.Handler
 	})
 }
 
-// Структура для хранения лимитеров по IP
-type RateLimiter struct {
-	app      *application
-	visitors map[string]*rate.Limiter
-	mu       sync.Mutex
-	limit    rate.Limit
-	burst    int
-}
+func (app *application) requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := app.getCurrentUser(r)
+		if err != nil {
+			app.clientError(w, http.StatusUnauthorized)
+			return
+		}
 
-// Создаем новый RateLimiter
-func NewRateLimiter(app *application, limit rate.Limit, burst int) *RateLimiter {
-	return &RateLimiter{
-		app:      app, // Передаем приложение
-		visitors: make(map[string]*rate.Limiter),
-		limit:    limit,
-		burst:    burst,
-	}
+		user, err := app.users.Get(userID)
+		if err != nil || user.Role != role || !app.hasScope(r, models2.ScopeModeration) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+		// Роль требует 2FA, а она не включена: сначала настройка
+		if missing, err := app.twoFactorMissing(user); err != nil {
+			app.serverError(w, err)
+			return
+		} else if missing {
+			app.flash(w, r, "Your role requires two-factor authentication. Please set it up to continue.")
+			http.Redirect(w, r, "/user/profile/2fa", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
 
-// Получение (или создание) лимитера для IP
-func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+type contextKey string
 
-	if limiter, exists := rl.visitors[ip]; exists {
-		return limiter
-	}
+const apiTokenContextKey = contextKey("apiToken")
 
-	limiter := rate.NewLimiter(rl.limit, rl.burst)
-	rl.visitors[ip] = limiter
+// authenticateToken принимает персональные токены из заголовка Authorization: Bearer
+// и кладёт их в контекст запроса; запросы без заголовка идут дальше как обычно (через cookie).
+// Токены действуют только в /api/: страницы профиля (создание токенов, 2FA, email)
+// доступны лишь по cookie-сессии, иначе токен с правом записи мог бы расширить свои права.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-	// Удаляем IP из списка через 10 минут бездействия
-	go func() {
-		time.Sleep(10 * time.Minute)
-		rl.mu.Lock()
-		delete(rl.visitors, ip)
-		rl.mu.Unlock()
-	}()
+		w.Header().Add("Vary", "Authorization")
 
-	return limiter
-}
+		scheme, value, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || value == "" {
+			app.tokenError(w, http.StatusUnauthorized)
+			return
+		}
 
-func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		limiter := rl.getLimiter(ip)
+		token, err := app.apiTokens.Authenticate(strings.TrimSpace(value))
+		if err != nil {
+			if errors.Is(err, models2.ErrInvalidCredentials) {
+				app.tokenError(w, http.StatusUnauthorized)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
 
-		if !limiter.Allow() {
-			rl.app.clientError(w, http.StatusTooManyRequests) // Используем app из структуры
+		// Токен только для чтения не может менять данные
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !models2.ScopeAllows(token.Scope, models2.ScopeWrite) {
+			app.tokenError(w, http.StatusForbidden)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		if time.Since(token.LastUsed) > sessionTouchInterval {
+			if err := app.apiTokens.TouchLastUsed(token.ID, time.Now()); err != nil {
+				app.errorLog.Println("Failed to update token last used:", err)
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), apiTokenContextKey, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// hasScope всегда true для cookie-сессий; для токенов проверяет область действия
+func (app *application) hasScope(r *http.Request, scope string) bool {
+	token, ok := r.Context().Value(apiTokenContextKey).(*models2.APIToken)
+	if !ok {
+		return true
+	}
+	return models2.ScopeAllows(token.Scope, scope)
+}
+
+func (app *application) tokenError(w http.ResponseWriter, status int) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="forum"`)
+	}
+	app.apiClientError(w, status)
+}
+
 func metricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/metrics" {
@@ -176,17 +223,48 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-func enableCORS(next http.Handler) http.Handler {
+// Unwrap нужен http.ResponseController: через обёртку до Flush и SetWriteDeadline не достать
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// enableCORS разрешает кросс-доменные запросы только с адресов из -cors-origins.
+// Cookie с ними не передаются (нет Allow-Credentials): чужим сайтам доступен только API по токену.
+func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !app.corsOrigins[origin] {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// parseCORSOrigins разбирает -cors-origins: адреса вида https://example.com через запятую
+func parseCORSOrigins(s string) (map[string]bool, error) {
+	origins := map[string]bool{}
+	for _, origin := range strings.Split(s, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("-cors-origins: %q is not an origin like https://example.com", origin)
+		}
+		origins[origin] = true
+	}
+	return origins, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	models2 "forum-app/internal/models"
 	"forum-app/internal/validator"
 	"net/http"
 	"strconv"
@@ -67,6 +68,14 @@ func (app *application) approvePost(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, err)
 		return
 	}
+	if moderatorID, err := app.getCurrentUser(r); err == nil {
+		if post, err := app.posts.Get(postID); err == nil && post.AuthorID != moderatorID {
+			err = app.notificationsModel.Insert(post.AuthorID, moderatorID, models2.NotificationPostApproved, post.ID, 0)
+			if err != nil {
+				app.errorLog.Println("Failed to create notification:", err)
+			}
+		}
+	}
 	app.flash(w, r, "Post approved successfully!")
 	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
 }
@@ -247,6 +256,12 @@ func (app *application) Answer(w http.ResponseWriter, r *http.Request) {
 			app.clientError(w, http.StatusBadRequest)
 			return
 		}
+		if report, err := app.reports.Get(reportId); err == nil && report.ReporterID != userID {
+			err = app.notificationsModel.Insert(report.ReporterID, userID, models2.NotificationReportAnswered, report.PostID, 0)
+			if err != nil {
+				app.errorLog.Println("Failed to create notification:", err)
+			}
+		}
 
 		app.flash(w, r, "Report submitted successfully!")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
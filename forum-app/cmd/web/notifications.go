@@ -0,0 +1,459 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	models2 "forum-app/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Упоминания вида @alice; имена с пробелами упомянуть нельзя
+var mentionRX = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_][\p{L}\p{N}_.-]*)`)
+
+// Сколько упоминаний из одного комментария превращаются в уведомления
+const maxMentions = 10
+
+// mentionedNames возвращает упомянутые имена без повторов, не больше maxMentions
+func mentionedNames(text string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range mentionRX.FindAllStringSubmatch(text, -1) {
+		name := strings.TrimRight(m[1], ".-")
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+		}
+		if len(names) == maxMentions {
+			break
+		}
+	}
+	return names
+}
+
+// notifyComment уведомляет о новом комментарии: автора родительского комментария (reply),
+// автора поста (comment) и упомянутых (mention). Каждый получает одно уведомление,
+// автор комментария — ни одного; канал выбирает NotificationModel.Insert по настройкам получателя.
+func (app *application) notifyComment(post *models2.Post, parent, comment *models2.Comment) {
+	notified := map[int]bool{comment.UserID: true}
+	notify := func(userID int, ntype string) {
+		if notified[userID] {
+			return
+		}
+		notified[userID] = true
+		if err := app.notificationsModel.Insert(userID, comment.UserID, ntype, post.ID, comment.ID); err != nil {
+			app.errorLog.Println("Failed to create notification:", err)
+		}
+	}
+
+	if parent != nil {
+		notify(parent.UserID, models2.NotificationReply)
+	}
+	notify(post.AuthorID, models2.NotificationComment)
+
+	ids, err := app.users.IDsByName(mentionedNames(comment.Content))
+	if err != nil {
+		app.errorLog.Println("Failed to resolve mentions:", err)
+		return
+	}
+	for _, id := range ids {
+		notify(id, models2.NotificationMention)
+	}
+}
+
+// Сколько групп уведомлений на странице
+const notificationsPageSize = 20
+
+// notifications показывает уведомления группами, новые первыми: /notifications?type=&before=|after=.
+// Просмотр больше не помечает их прочитанными — для этого есть кнопки и переход по ссылке.
+func (app *application) notifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	q := models2.NotificationQuery{Type: query.Get("type"), PageSize: notificationsPageSize}
+	var ok bool
+	if q.Before, ok = notificationsCursor(query.Get("before")); !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if q.After, ok = notificationsCursor(query.Get("after")); !ok || q.Before > 0 && q.After > 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	page, err := app.notificationsModel.Groups(userID, q)
+	if errors.Is(err, models2.ErrInvalidNotificationType) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.NotificationGroups = page.Groups
+	data.NotificationType = q.Type
+	data.NotificationTypes = models2.NotificationTypes
+	if page.Prev > 0 {
+		data.PrevPage = notificationsURL(q.Type, "after", page.Prev)
+	}
+	if page.Next > 0 {
+		data.NextPage = notificationsURL(q.Type, "before", page.Next)
+	}
+	app.render(w, http.StatusOK, "notifications.html", data)
+}
+
+// notificationsCursor разбирает before или after; пустое значение — 0
+func notificationsCursor(v string) (int, bool) {
+	if v == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(v)
+	return id, err == nil && id > 0
+}
+
+// notificationsURL строит ссылку на страницу уведомлений; cursor — "before" или "after",
+// id 0 — первая страница
+func notificationsURL(ntype, cursor string, id int) string {
+	query := url.Values{}
+	if ntype != "" {
+		query.Set("type", ntype)
+	}
+	if id > 0 {
+		query.Set(cursor, strconv.Itoa(id))
+	}
+	if len(query) == 0 {
+		return "/notifications"
+	}
+	return "/notifications?" + query.Encode()
+}
+
+// notificationIDs читает повторяющееся поле id из формы или строки запроса
+func notificationIDs(r *http.Request) ([]int, bool) {
+	if err := r.ParseForm(); err != nil {
+		return nil, false
+	}
+	var ids []int
+	for _, v := range r.Form["id"] {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, len(ids) > 0
+}
+
+// notificationsReturn возвращает на ту же страницу списка, откуда пришла форма
+func notificationsReturn(r *http.Request) string {
+	if q := r.PostFormValue("return"); q != "" {
+		if values, err := url.ParseQuery(q); err == nil {
+			if after, _ := strconv.Atoi(values.Get("after")); after > 0 {
+				return notificationsURL(values.Get("type"), "after", after)
+			}
+			before, _ := strconv.Atoi(values.Get("before"))
+			return notificationsURL(values.Get("type"), "before", before)
+		}
+	}
+	return "/notifications"
+}
+
+// updateNotifications — общая часть POST /notifications/read, /unread и /delete
+func (app *application) updateNotifications(w http.ResponseWriter, r *http.Request, update func(userID int, ids []int) error, message string) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	ids, ok := notificationIDs(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if err := update(userID, ids); errors.Is(err, models2.ErrNoRecord) {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.flash(w, r, message)
+	http.Redirect(w, r, notificationsReturn(r), http.StatusSeeOther)
+}
+
+func (app *application) markNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	app.updateNotifications(w, r, func(userID int, ids []int) error {
+		return app.notificationsModel.SetRead(userID, ids, true)
+	}, "Marked as read.")
+}
+
+func (app *application) markNotificationsUnread(w http.ResponseWriter, r *http.Request) {
+	app.updateNotifications(w, r, func(userID int, ids []int) error {
+		return app.notificationsModel.SetRead(userID, ids, false)
+	}, "Marked as unread.")
+}
+
+func (app *application) deleteNotifications(w http.ResponseWriter, r *http.Request) {
+	app.updateNotifications(w, r, app.notificationsModel.Delete, "Notification deleted.")
+}
+
+// markAllNotificationsRead — POST /notifications/read-all
+func (app *application) markAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	if err := app.notificationsModel.MarkAllAsRead(userID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.flash(w, r, "All notifications marked as read.")
+	http.Redirect(w, r, notificationsReturn(r), http.StatusSeeOther)
+}
+
+// openNotification помечает группу прочитанной и переходит к посту или комментарию:
+// /notifications/open?id=3&id=5
+func (app *application) openNotification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	ids, ok := notificationIDs(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	n, err := app.notificationsModel.Get(ids[0])
+	if err != nil || n.UserID != userID {
+		app.notFound(w)
+		return
+	}
+	if err := app.notificationsModel.SetRead(userID, ids, true); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	target := fmt.Sprintf("/post/view/%d", n.PostID)
+	if n.CommentID > 0 {
+		target += fmt.Sprintf("#comment-%d", n.CommentID)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// pruneNotifications каждые interval удаляет прочитанные уведомления старше retention, пока не закрыт done
+func (app *application) pruneNotifications(retention, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := app.notificationsModel.Prune(time.Now().Add(-retention))
+			if err != nil {
+				app.errorLog.Println("Failed to prune notifications:", err)
+				continue
+			}
+			if n > 0 {
+				app.infoLog.Printf("Deleted %d read notification(s) older than %s", n, retention)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// notificationSettings — настройки уведомлений: /user/profile/notifications.
+// Для каждой категории выбирается канал: на сайт, в email-дайджест или никуда.
+func (app *application) notificationSettings(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := app.notificationsModel.Preferences(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data := app.newTemplateData(w, r)
+		data.NotificationPreferences = prefs
+		data.NotificationCategories = models2.NotificationCategories
+		data.NotificationChannels = models2.NotificationChannels
+		app.render(w, http.StatusOK, "notification_settings.html", data)
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		prefs := map[string]string{}
+		for _, category := range models2.NotificationCategories {
+			if channel := r.PostForm.Get(category); channel != "" {
+				prefs[category] = channel
+			}
+		}
+		err := app.notificationsModel.SetPreferences(userID, prefs)
+		if errors.Is(err, models2.ErrInvalidPreference) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.flash(w, r, "Notification settings saved.")
+		http.Redirect(w, r, "/user/profile/notifications", http.StatusSeeOther)
+	default:
+		app.methodNotAllowed(w)
+	}
+}
+
+// Как часто поток шлёт комментарий-пульс, чтобы прокси не закрывали простаивающее соединение
+var sseHeartbeat = 15 * time.Second
+
+// Через сколько миллисекунд браузер переподключается после обрыва
+const sseRetry = 3000
+
+// notificationStream — поток server-sent events для текущего пользователя: /notifications/stream.
+// События: notification (новое уведомление, id — его номер) и unread (счётчик непрочитанных).
+// При переподключении браузер присылает Last-Event-ID, и пропущенные уведомления досылаются из базы.
+func (app *application) notificationStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	lastID := 0
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		lastID, _ = strconv.Atoi(v)
+	} else if v := r.URL.Query().Get("lastEventId"); v != "" {
+		lastID, _ = strconv.Atoi(v)
+	}
+
+	// Соединение живёт дольше WriteTimeout сервера, поэтому снимаем дедлайн записи
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverError(w, err)
+		return
+	}
+
+	// Подписываемся до чтения пропущенного: уведомление, вставленное между ними, придёт
+	// по подписке, а повтор отсеется по id
+	sub := app.notificationHub.Subscribe(userID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+
+	if lastID > 0 {
+		missed, err := app.notificationsModel.Since(userID, lastID)
+		if err != nil {
+			app.errorLog.Println("notification stream:", err)
+			return
+		}
+		for _, n := range missed {
+			if err := writeEvent(w, int64(n.ID), models2.EventNotification, n); err != nil {
+				return
+			}
+			lastID = n.ID
+		}
+	}
+	if err := app.writeUnread(w, userID); err != nil || rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			// Канал закрыт — клиент отстал; он переподключится и догонит по Last-Event-ID
+			if !ok {
+				return
+			}
+			if e.ID != 0 {
+				if e.ID <= int64(lastID) {
+					continue
+				}
+				lastID = int(e.ID)
+				if err := writeEvent(w, e.ID, e.Name, e.Data); err != nil {
+					return
+				}
+			}
+			if err := app.writeUnread(w, userID); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeUnread отправляет текущее число непрочитанных уведомлений
+func (app *application) writeUnread(w io.Writer, userID int) error {
+	count, err := app.notificationsModel.GetUnreadCount(userID)
+	if err != nil {
+		return err
+	}
+	return writeEvent(w, 0, "unread", map[string]int{"count": count})
+}
+
+// writeEvent пишет одно событие в формате text/event-stream; id 0 не отправляется
+func writeEvent(w io.Writer, id int64, name string, data any) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, js)
+	return err
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent — разобранное событие text/event-stream; комментарии-пульсы приходят с name "heartbeat"
+type sseEvent struct {
+	id, name, data string
+}
+
+// readEvents разбирает поток в канал, пока соединение не закроется
+func readEvents(body *bufio.Reader) <-chan sseEvent {
+	ch := make(chan sseEvent)
+	go func() {
+		defer close(ch)
+		var e sseEvent
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				if e != (sseEvent{}) {
+					ch <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, ": heartbeat"):
+				e.name = "heartbeat"
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return ch
+}
+
+// nextEvent ждёт следующее событие с именем name, пропуская остальные
+func nextEvent(t *testing.T, events <-chan sseEvent, name string) sseEvent {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("Stream closed while waiting for %q", name)
+			}
+			if e.name == name {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q", name)
+		}
+	}
+}
+
+func TestNotificationStream(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	loginAs(t, app, "bob", "user")
+	postID, err := app.posts.Insert("post", "body", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(d time.Duration) { sseHeartbeat = d }(sseHeartbeat)
+	sseHeartbeat = 50 * time.Millisecond
+
+	// Короткий WriteTimeout: поток обязан его снять, иначе оборвётся раньше пульсов
+	srv := httptest.NewUnstartedServer(app.routes())
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	connect := func(lastEventID string) (*http.Response, <-chan sseEvent) {
+		req, err := http.NewRequest("GET", srv.URL+"/notifications/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(alice)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Expected text/event-stream, got %q", ct)
+		}
+		return resp, readEvents(bufio.NewReader(resp.Body))
+	}
+
+	resp, stream := connect("")
+	if e := nextEvent(t, stream, "unread"); e.data != `{"count":0}` {
+		t.Errorf("Expected no unread notifications, got %s", e.data)
+	}
+
+	if err := app.notificationsModel.Insert(1, 2, "post_like", postID, 0); err != nil {
+		t.Fatal(err)
+	}
+	first := nextEvent(t, stream, "notification")
+	if !strings.Contains(first.data, `"actor_name":"bob"`) || first.id == "" {
+		t.Errorf("Unexpected notification event %+v", first)
+	}
+	if e := nextEvent(t, stream, "unread"); e.data != `{"count":1}` {
+		t.Errorf("Expected 1 unread notification, got %s", e.data)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	nextEvent(t, stream, "heartbeat")
+
+	// После отключения подписка удаляется
+	resp.Body.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for app.notificationHub.Subscribers(1) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the subscriber to be removed after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Пропущенное, пока клиента не было, досылается по Last-Event-ID
+	if err := app.notificationsModel.Insert(1, 2, "post_reaction", postID, 0); err != nil {
+		t.Fatal(err)
+	}
+	resp, stream = connect(first.id)
+	defer resp.Body.Close()
+	missed := nextEvent(t, stream, "notification")
+	firstID, _ := strconv.Atoi(first.id)
+	if missed.id != strconv.Itoa(firstID+1) || !strings.Contains(missed.data, "post_reaction") {
+		t.Errorf("Expected the missed notification, got %+v", missed)
+	}
+	if e := nextEvent(t, stream, "unread"); e.data != `{"count":2}` {
+		t.Errorf("Expected 2 unread notifications, got %s", e.data)
+	}
+}
+
+func TestNotificationManagement(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	bob := loginAs(t, app, "bob", "user")
+	loginAs(t, app, "carol", "user")
+	loginAs(t, app, "dave", "user")
+	postID, err := app.posts.Insert("post", "body", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Три лайка склеиваются в одну группу, два комментария остаются отдельными
+	for _, actor := range []int{2, 3, 4} {
+		if err := app.notificationsModel.Insert(1, actor, models2.NotificationPostLike, postID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, commentID := range []int{7, 8} {
+		if err := app.notificationsModel.Insert(1, 2, models2.NotificationComment, postID, commentID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := app.notificationsModel.Groups(1, models2.NotificationQuery{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Groups) != 2 || first.Groups[0].CommentID != 8 || first.Groups[1].CommentID != 7 || first.Next == 0 || first.Prev != 0 {
+		t.Fatalf("Unexpected first page: %d group(s), next %d, prev %d", len(first.Groups), first.Next, first.Prev)
+	}
+	last, err := app.notificationsModel.Groups(1, models2.NotificationQuery{PageSize: 2, Before: first.Next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last.Groups) != 1 || last.Next != 0 || last.Prev == 0 {
+		t.Fatalf("Unexpected last page: %d group(s), next %d, prev %d", len(last.Groups), last.Next, last.Prev)
+	}
+	// Назад с последней страницы — снова первая
+	back, err := app.notificationsModel.Groups(1, models2.NotificationQuery{PageSize: 2, After: last.Prev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Groups) != 2 || back.Groups[0].CommentID != 8 || back.Groups[1].CommentID != 7 || back.Prev != 0 || back.Next != first.Next {
+		t.Fatalf("Unexpected previous page: %d group(s), next %d, prev %d", len(back.Groups), back.Next, back.Prev)
+	}
+	likes := last.Groups[0]
+	if likes.Unread != 3 || len(likes.IDs) != 3 || strings.Join(likes.Actors, ",") != "dave,carol,bob" {
+		t.Errorf("Unexpected like group %+v", likes)
+	}
+
+	do := func(cookie *http.Cookie, method, path, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	unread := func() int {
+		count, err := app.notificationsModel.GetUnreadCount(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	// Просмотр списка больше не помечает уведомления прочитанными
+	rr := do(alice, "GET", "/notifications", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "dave, carol and bob") {
+		t.Errorf("Expected the grouped like notification, got %d", rr.Code)
+	}
+	if unread() != 5 {
+		t.Errorf("Expected viewing to keep 5 unread, got %d", unread())
+	}
+	if rr := do(alice, "GET", "/notifications?type=bogus", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown type, got %d", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(alice, "GET", "/notifications?type=comment", "")
+	if body := rr.Body.String(); strings.Contains(body, "liked your post") || !strings.Contains(body, "commented on your post") {
+		t.Errorf("Expected only comment notifications with ?type=comment")
+	}
+
+	if rr := do(alice, "POST", "/notifications/read", likes.Query()); rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, rr.Code)
+	}
+	if unread() != 2 {
+		t.Errorf("Expected 2 unread after marking the group read, got %d", unread())
+	}
+
+	// Чужие уведомления не трогаются
+	if rr := do(bob, "POST", "/notifications/delete", likes.Query()); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for someone else's notifications, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	page, err := app.notificationsModel.Groups(1, models2.NotificationQuery{Type: models2.NotificationComment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments := page.Groups
+	rr = do(alice, "GET", "/notifications/open?"+comments[0].Query(), "")
+	if loc := rr.Header().Get("Location"); rr.Code != http.StatusSeeOther || loc != "/post/view/"+strconv.Itoa(postID)+"#comment-8" {
+		t.Errorf("Expected a redirect to the comment, got %d %q", rr.Code, loc)
+	}
+	if unread() != 1 {
+		t.Errorf("Expected 1 unread after opening a notification, got %d", unread())
+	}
+	if rr := do(alice, "POST", "/notifications/unread", comments[0].Query()); rr.Code != http.StatusSeeOther || unread() != 2 {
+		t.Errorf("Expected the notification to be unread again, got %d unread", unread())
+	}
+
+	if rr := do(alice, "POST", "/notifications/delete", comments[1].Query()); rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, rr.Code)
+	}
+	if rr := do(alice, "POST", "/notifications/read-all", ""); rr.Code != http.StatusSeeOther || unread() != 0 {
+		t.Errorf("Expected everything read, got %d unread", unread())
+	}
+
+	// Очистка удаляет только старые прочитанные уведомления
+	if _, err := app.posts.DB.Exec(`UPDATE notifications SET created = datetime('now', '-40 days') WHERE type = ?`, models2.NotificationPostLike); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.notificationsModel.Insert(1, 3, models2.NotificationReply, postID, 9); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := app.notificationsModel.Prune(time.Now().Add(-30 * 24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 3 {
+		t.Errorf("Expected 3 pruned notifications, got %d", pruned)
+	}
+	page, err = app.notificationsModel.Groups(1, models2.NotificationQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Groups) != 2 {
+		t.Errorf("Expected the recent comment and the unread reply to remain, got %d group(s)", len(page.Groups))
+	}
+}
+
+func TestActorList(t *testing.T) {
+	tests := []struct {
+		names  []string
+		others int
+		want   string
+	}{
+		{[]string{"alice"}, 0, "alice"},
+		{[]string{"alice", "bob"}, 0, "alice and bob"},
+		{[]string{"alice", "bob", "carol"}, 0, "alice, bob and carol"},
+		{[]string{"alice", "bob", "carol"}, 1, "alice, bob, carol and 1 other"},
+		{[]string{"alice", "bob", "carol"}, 4, "alice, bob, carol and 4 others"},
+	}
+	for _, tt := range tests {
+		if got := actorList(tt.names, tt.others); got != tt.want {
+			t.Errorf("actorList(%v, %d) = %q, want %q", tt.names, tt.others, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	bob := loginAs(t, app, "bob", "user")
+	carol := loginAs(t, app, "carol", "moderator")
+
+	post := func(cookie *http.Cookie, path, form string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+	unread := func(userID int) int {
+		count, err := app.notificationsModel.GetUnreadCount(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	if code := post(alice, "/user/profile/notifications", "reply=off&mention=email&comment=in_app"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	if code := post(alice, "/user/profile/notifications", "reaction=loud"); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown channel, got %d", http.StatusBadRequest, code)
+	}
+	prefs, err := app.notificationsModel.Preferences(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefs[models2.CategoryReply] != models2.ChannelOff || prefs[models2.CategoryMention] != models2.ChannelEmail ||
+		prefs[models2.CategoryReaction] != models2.ChannelInApp {
+		t.Errorf("Unexpected preferences %v", prefs)
+	}
+
+	alicePost, err := app.posts.Insert("alice's post", "body", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPost, err := app.posts.Insert("bob's post", "body", "", "bob", "approved", 2, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Комментарий на сайте; упоминание bob приходит ему по умолчанию на сайт
+	if code := post(carol, "/comments/add", "post_id="+strconv.Itoa(alicePost)+"&content=hi+%40bob"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	if unread(1) != 1 || unread(2) != 1 {
+		t.Errorf("Expected a comment for alice and a mention for bob, got %d and %d", unread(1), unread(2))
+	}
+
+	// Упоминание alice уходит в email-дайджест и не видно на сайте
+	if code := post(carol, "/comments/add", "post_id="+strconv.Itoa(bobPost)+"&content=%40Alice+look"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	var queued int
+	err = app.posts.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = 1 AND channel = 'email'`).Scan(&queued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread(1) != 1 || queued != 1 {
+		t.Errorf("Expected the mention in the email queue only, got %d unread and %d queued", unread(1), queued)
+	}
+
+	// Ответы alice выключила
+	comment := &models2.Comment{PostID: alicePost, Content: "first", UserID: 1, Author: "alice"}
+	if err := app.comments.Insert(comment); err != nil {
+		t.Fatal(err)
+	}
+	form := "post_id=" + strconv.Itoa(alicePost) + "&parent_id=" + strconv.Itoa(comment.ID) + "&content=reply"
+	if code := post(bob, "/comments/add", form); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	if unread(1) != 1 {
+		t.Errorf("Expected no reply notification, got %d unread", unread(1))
+	}
+
+	// Итог модерации и ответ на жалобу тоже проходят через настройки
+	pending, err := app.posts.Insert("pending", "body", "", "alice", "pending", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := post(carol, "/post/approve", "post_id="+strconv.Itoa(pending)); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	page, err := app.notificationsModel.Groups(1, models2.NotificationQuery{Type: models2.NotificationPostApproved})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups := page.Groups; len(groups) != 1 || groups[0].PostID != pending {
+		t.Errorf("Expected a moderation notification for the approved post")
+	}
+}
+
+func TestMentionedNames(t *testing.T) {
+	got := mentionedNames("hi @bob and @Bob, mail a@b.com, thanks @al.ice. (@Zoë)")
+	if want := "bob,al.ice,Zoë"; strings.Join(got, ",") != want {
+		t.Errorf("mentionedNames = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postTitles(posts []*models2.Post) string {
+	titles := make([]string, len(posts))
+	for i, p := range posts {
+		titles[i] = p.Title
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestPostPageKeyset(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+
+	// p1..p5; лайки: p1=3, p2=0, p3=3, p4=1, p5=0; у p2 — 2 комментария
+	likes := []int{3, 0, 3, 1, 0}
+	for i, n := range likes {
+		id, err := app.posts.Insert(fmt.Sprintf("p%d", i+1), "body", "", "alice", "approved", 1, []int{1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.posts.DB.Exec(`UPDATE posts SET likes = ? WHERE id = ?`, n, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := app.posts.DB.Exec(`INSERT INTO comments (post_id, content, user_id, author, created) VALUES (2, 'c', 1, 'alice', CURRENT_TIMESTAMP)`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sort  string
+		pages []string
+	}{
+		{models2.SortNewest, []string{"p5,p4", "p3,p2", "p1"}},
+		{models2.SortLiked, []string{"p3,p1", "p4,p5", "p2"}},
+		{models2.SortCommented, []string{"p2,p5", "p4,p3", "p1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			var pages []*models2.PostPage
+			query := models2.PostQuery{Sort: tt.sort, Limit: 2}
+			for {
+				page, err := app.posts.Page(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, page)
+				if page.Next == "" {
+					break
+				}
+				query.After = page.Next
+			}
+
+			if len(pages) != len(tt.pages) {
+				t.Fatalf("Expected %d pages, got %d", len(tt.pages), len(pages))
+			}
+			for i, page := range pages {
+				if got := postTitles(page.Posts); got != tt.pages[i] {
+					t.Errorf("Page %d: expected %q, got %q", i+1, tt.pages[i], got)
+				}
+			}
+			if pages[0].Prev != "" {
+				t.Errorf("Expected no previous page for the first page")
+			}
+
+			// Назад с последней страницы возвращает ту же предпоследнюю
+			back, err := app.posts.Page(models2.PostQuery{Sort: tt.sort, Limit: 2, Before: pages[len(pages)-1].Prev})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := postTitles(back.Posts); got != tt.pages[len(tt.pages)-2] {
+				t.Errorf("Expected %q going back, got %q", tt.pages[len(tt.pages)-2], got)
+			}
+		})
+	}
+
+	// Курсор другой сортировки не принимается
+	first, err := app.posts.Page(models2.PostQuery{Sort: models2.SortNewest, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.posts.Page(models2.PostQuery{Sort: models2.SortHot, Limit: 2, After: first.Next}); err != models2.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestHomePagination(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+
+	for i := 0; i < feedPageSize+1; i++ {
+		if _, err := app.posts.Insert(fmt.Sprintf("post %d", i), "body", "", "alice", "approved", 1, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/?sort=hot&category=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "Next page") || strings.Contains(body, "Previous page") {
+		t.Errorf("Expected only a next page link on the first page")
+	}
+	if !strings.Contains(body, "category=1&amp;sort=hot") {
+		t.Errorf("Expected page links to keep the filters")
+	}
+
+	for _, sort := range models2.PostSorts {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/?sort="+sort, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("sort=%s: expected status %d, got %d", sort, http.StatusOK, rr.Code)
+		}
+	}
+
+	for _, url := range []string{"/?sort=random", "/?after=garbage"} {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", url, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHomePersonalFilters(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	loginAs(t, app, "bob", "user")
+
+	// alice (id 1) написала "own", лайкнула "liked", дизлайкнула и прокомментировала "argued"
+	if _, err := app.posts.Insert("own", "body", "", "alice", "approved", 1, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	liked, err := app.posts.Insert("liked", "body", "", "bob", "approved", 2, []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	argued, err := app.posts.Insert("argued", "body", "", "bob", "approved", 2, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.reactions.LikePost(liked, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.reactions.DislikePost(argued, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.comments.Insert(&models2.Comment{PostID: argued, Content: "no", UserID: 1, Author: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"mine=1", []string{"own"}},
+		{"liked=1", []string{"liked"}},
+		{"disliked=1&commented=1", []string{"argued"}},
+		{"mine=1&liked=1", nil},
+		{"commented=1&category=2", nil},
+		{"commented=on&category=1", []string{"argued"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?"+tt.query, nil)
+			req.AddCookie(alice)
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			body := rr.Body.String()
+			for _, title := range []string{"own", "liked", "argued"} {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == title
+				}
+				if got := strings.Contains(body, ">"+title+"</a>"); got != want {
+					t.Errorf("Post %q shown = %v, want %v", title, got, want)
+				}
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/?liked=1", nil))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Errorf("Expected guests to be redirected to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/validator"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Сброс пароля: ссылка живёт час, на один адрес — не больше трёх писем в час
+const (
+	passwordResetTTL    = time.Hour
+	passwordResetLimit  = 3
+	passwordResetWindow = time.Hour
+)
+
+// Один ответ на любой адрес: по нему нельзя понять, есть ли такой пользователь
+const passwordResetSent = "If an account exists for that email, we have sent a link to reset the password."
+
+type forgotPasswordForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type resetPasswordForm struct {
+	Token               string `form:"token"`
+	NewPassword         string `form:"newPassword"`
+	ConfirmPassword     string `form:"confirmPassword"`
+	validator.Validator `form:"-"`
+}
+
+// forgotPassword — /user/password/forgot: форма с адресом и отправка ссылки
+func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		data := app.newTemplateData(w, r)
+		data.Form = forgotPasswordForm{}
+		app.render(w, http.StatusOK, "forgot_password.html", data)
+	case http.MethodPost:
+		form := forgotPasswordForm{Email: r.PostFormValue("email")}
+		form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+		if !form.Valid() {
+			data := app.newTemplateData(w, r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "forgot_password.html", data)
+			return
+		}
+
+		if err := app.sendPasswordReset(form.Email); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.flash(w, r, passwordResetSent)
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	default:
+		app.methodNotAllowed(w)
+	}
+}
+
+// sendPasswordReset ставит письмо со ссылкой в очередь, если адрес зарегистрирован и лимит не исчерпан.
+// Неизвестный адрес и исчерпанный лимит не ошибки: пользователь видит тот же ответ.
+func (app *application) sendPasswordReset(email string) error {
+	now := time.Now()
+	allowed, err := app.passwordResets.AllowRequest(email, passwordResetLimit, passwordResetWindow, now)
+	if err != nil || !allowed {
+		return err
+	}
+
+	user, err := app.users.GetByEmail(email)
+	if errors.Is(err, models2.ErrNoRecord) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	token, err := app.passwordResets.Create(user.ID, passwordResetTTL, now)
+	if err != nil {
+		return err
+	}
+	return app.queueEmail(user.Email, "password_reset", &emailData{
+		Name: user.Name,
+		Link: app.baseURL + "/user/password/reset?token=" + url.QueryEscape(token),
+	})
+}
+
+// resetPassword — /user/password/reset?token=: новый пароль по ссылке из письма.
+// После сброса завершаются все сессии пользователя.
+func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	form := resetPasswordForm{Token: r.FormValue("token")}
+	if _, err := app.passwordResets.UserID(form.Token, time.Now()); errors.Is(err, models2.ErrInvalidResetToken) {
+		app.flash(w, r, "This password reset link is invalid or has expired. Please request a new one.")
+		http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		data := app.newTemplateData(w, r)
+		data.Form = form
+		app.render(w, http.StatusOK, "reset_password.html", data)
+		return
+	}
+
+	form.NewPassword = r.PostFormValue("newPassword")
+	form.ConfirmPassword = r.PostFormValue("confirmPassword")
+	form.CheckField(validator.ValidatePassword(form.NewPassword), "newPassword", "Password must be 8-20 characters long and contain a lowercase letter, an uppercase letter, a digit and a special character")
+	form.CheckField(validator.ComparePassword(form.NewPassword, form.ConfirmPassword), "confirmPassword", "This field must be the same as newPassword")
+	if !form.Valid() {
+		data := app.newTemplateData(w, r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "reset_password.html", data)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(form.NewPassword), 12)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	userID, err := app.passwordResets.Reset(form.Token, string(hashedPassword), time.Now())
+	if errors.Is(err, models2.ErrInvalidResetToken) {
+		// Ссылку успели использовать между показом формы и отправкой
+		app.flash(w, r, "This password reset link is invalid or has expired. Please request a new one.")
+		http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Тот, кто знал старый пароль, не должен остаться залогиненным или сохранить выпущенные токены
+	if err := app.deleteAllSessions(w, userID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.apiTokens.DeleteByUser(userID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.flash(w, r, "Your password has been reset. Please log in with the new password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+	session := loginAs(t, app, "alice", "user")
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	outbox := func() []string {
+		rows, err := app.outbox.DB.Query(`SELECT text_body FROM email_outbox ORDER BY id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var bodies []string
+		for rows.Next() {
+			var body string
+			if err := rows.Scan(&body); err != nil {
+				t.Fatal(err)
+			}
+			bodies = append(bodies, body)
+		}
+		return bodies
+	}
+
+	// Известный и неизвестный адрес неотличимы по ответу
+	known := post("/user/password/forgot", url.Values{"email": {"alice@example.com"}})
+	unknown := post("/user/password/forgot", url.Values{"email": {"nobody@example.com"}})
+	for _, rr := range []*httptest.ResponseRecorder{known, unknown} {
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+			t.Fatalf("Expected a redirect to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+	}
+	if known.Header().Get("Set-Cookie") != unknown.Header().Get("Set-Cookie") {
+		t.Errorf("Expected the same response for known and unknown emails")
+	}
+
+	bodies := outbox()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 reset email, got %d", len(bodies))
+	}
+	match := regexp.MustCompile(`http://forum\.test/user/password/reset\?token=([0-9a-f]+)`).FindStringSubmatch(bodies[0])
+	if match == nil {
+		t.Fatalf("Expected a reset link in the email, got %q", bodies[0])
+	}
+	token := match[1]
+
+	// Лимит: после трёх запросов за час письма больше не отправляются, но ответ тот же
+	for range 3 {
+		if rr := post("/user/password/forgot", url.Values{"email": {"alice@example.com"}}); rr.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, rr.Code)
+		}
+	}
+	if n := len(outbox()); n != passwordResetLimit {
+		t.Errorf("Expected %d reset emails, got %d", passwordResetLimit, n)
+	}
+
+	rr := post("/user/password/reset", url.Values{"token": {"bogus"}, "newPassword": {"NewValid123!"}, "confirmPassword": {"NewValid123!"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/password/forgot" {
+		t.Errorf("Expected an invalid token to redirect to forgot, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = post("/user/password/reset", url.Values{"token": {token}, "newPassword": {"weak"}, "confirmPassword": {"weak"}})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for a weak password, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	if _, err := app.apiTokens.Insert(1, "script", models2.ScopeWrite, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	rr = post("/user/password/reset", url.Values{"token": {token}, "newPassword": {"NewValid123!"}, "confirmPassword": {"NewValid123!"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Fatalf("Expected a redirect to login after the reset, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if _, err := app.sessions.Get(session.Value); !errors.Is(err, models2.ErrNoRecord) {
+		t.Errorf("Expected the reset to end existing sessions, got %v", err)
+	}
+	if tokens, err := app.apiTokens.ListByUser(1); err != nil || len(tokens) != 0 {
+		t.Errorf("Expected the reset to revoke API tokens, got %d (%v)", len(tokens), err)
+	}
+	if _, err := app.users.Authenticate("alice@example.com", "NewValid123!"); err != nil {
+		t.Errorf("Expected the new password to work, got %v", err)
+	}
+	if _, err := app.users.Authenticate("alice@example.com", "ValidPass123!"); err == nil {
+		t.Errorf("Expected the old password to stop working")
+	}
+
+	// Ссылка одноразовая
+	rr = post("/user/password/reset", url.Values{"token": {token}, "newPassword": {"Other123!x"}, "confirmPassword": {"Other123!x"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/password/forgot" {
+		t.Errorf("Expected a used token to be rejected, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
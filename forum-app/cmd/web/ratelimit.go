@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ratePolicy — корзина токенов: burst запросов сразу, дальше по every на запрос
+type ratePolicy struct {
+	name  string // метка в метриках и ключах
+	every time.Duration
+	burst int
+	off   bool // без ограничений
+}
+
+// routePolicy применяет policy к запросам на path; path с "/" на конце — префикс.
+// Пустой method — любой метод.
+type routePolicy struct {
+	method string
+	path   string
+	policy string
+}
+
+// Политики по умолчанию; их можно переопределить флагом -rate-limits
+var defaultRatePolicies = []ratePolicy{
+	{name: "auth", every: 12 * time.Second, burst: 5},  // вход, регистрация, сброс пароля: 5 в минуту
+	{name: "write", every: 10 * time.Second, burst: 5}, // посты и комментарии: 6 в минуту
+	{name: "stream", every: 5 * time.Second, burst: 3}, // переподключения SSE
+	{name: "static", every: 10 * time.Millisecond, burst: 200},
+	{name: "default", every: 100 * time.Millisecond, burst: 30}, // всё остальное: 10 в секунду
+}
+
+// Первое совпадение выигрывает; что не совпало — "default"
+var routePolicies = []routePolicy{
+	{http.MethodPost, "/user/login", "auth"},
+	{http.MethodPost, "/user/login/2fa", "auth"},
+	{http.MethodPost, "/user/signup", "auth"},
+	{http.MethodPost, "/user/password/forgot", "auth"},
+	{http.MethodPost, "/user/password/reset", "auth"},
+	{http.MethodPost, "/user/verify/resend", "auth"},
+	{http.MethodPost, "/comments/add", "write"},
+	{http.MethodPost, "/post/create", "write"},
+	{http.MethodPost, "/api/v1/posts", "write"},
+	{http.MethodPost, "/api/v1/posts/", "write"},
+	{http.MethodPost, "/api/v1/comments/", "write"},
+	{"", "/notifications/stream", "stream"},
+	{"", "/static/", "static"},
+}
+
+// parseRatePolicies разбирает -rate-limits: "auth=10/m:5,static=off". Формат — name=N/unit[:burst],
+// unit — s, m или h; без burst он равен N. Неупомянутые политики остаются по умолчанию.
+func parseRatePolicies(s string) (map[string]ratePolicy, error) {
+	policies := map[string]ratePolicy{}
+	for _, p := range defaultRatePolicies {
+		policies[p.name] = p
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		if _, known := policies[name]; !ok || !known {
+			return nil, fmt.Errorf("-rate-limits: %q: expected name=N/unit[:burst] with a known policy name", item)
+		}
+		if spec == "off" {
+			policies[name] = ratePolicy{name: name, off: true}
+			continue
+		}
+
+		spec, burstStr, hasBurst := strings.Cut(spec, ":")
+		countStr, unit, ok := strings.Cut(spec, "/")
+		count, err := strconv.Atoi(countStr)
+		units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+		if !ok || err != nil || count < 1 || units[unit] == 0 {
+			return nil, fmt.Errorf("-rate-limits: %q: expected name=N/unit[:burst], unit s, m or h", item)
+		}
+		burst := count
+		if hasBurst {
+			if burst, err = strconv.Atoi(burstStr); err != nil || burst < 1 {
+				return nil, fmt.Errorf("-rate-limits: %q: burst must be a positive integer", item)
+			}
+		}
+		policies[name] = ratePolicy{name: name, every: units[unit] / time.Duration(count), burst: burst}
+	}
+	return policies, nil
+}
+
+// policyFor выбирает политику для запроса
+func policyFor(r *http.Request) string {
+	for _, rp := range routePolicies {
+		if rp.method != "" && rp.method != r.Method {
+			continue
+		}
+		if r.URL.Path == rp.path || strings.HasSuffix(rp.path, "/") && strings.HasPrefix(r.URL.Path, rp.path) {
+			return rp.policy
+		}
+	}
+	return "default"
+}
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter хранит корзины по ключу "политика:user:id" или "политика:ip:адрес".
+// Забытые корзины удаляет один sweep, а не горутина на каждого посетителя.
+type rateLimiter struct {
+	policies map[string]ratePolicy
+	mu       sync.Mutex
+	visitors map[string]*visitor
+}
+
+func newRateLimiter(policies map[string]ratePolicy) *rateLimiter {
+	return &rateLimiter{policies: policies, visitors: map[string]*visitor{}}
+}
+
+// take забирает токен из корзины key. Возвращает, удалось ли, сколько токенов осталось
+// и через сколько корзина снова будет полной (или, при отказе, появится следующий токен).
+func (rl *rateLimiter) take(p ratePolicy, key string, now time.Time) (ok bool, remaining int, reset time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	v, exists := rl.visitors[key]
+	if !exists {
+		v = &visitor{limiter: rate.NewLimiter(rate.Every(p.every), p.burst)}
+		rl.visitors[key] = v
+	}
+	v.lastSeen = now
+
+	ok = v.limiter.AllowN(now, 1)
+	tokens := v.limiter.TokensAt(now)
+	if !ok {
+		return false, 0, time.Duration((1 - tokens) * float64(p.every))
+	}
+	return true, int(tokens), time.Duration((float64(p.burst) - tokens) * float64(p.every))
+}
+
+// sweep удаляет корзины, не тронутые дольше idle: к этому времени они всё равно полные
+func (rl *rateLimiter) sweep(idle time.Duration, now time.Time) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	n := 0
+	for key, v := range rl.visitors {
+		if now.Sub(v.lastSeen) > idle {
+			delete(rl.visitors, key)
+			n++
+		}
+	}
+	return n
+}
+
+// rateLimit ограничивает запросы по политике маршрута. Залогиненные считаются по пользователю,
+// остальные — по IP клиента. Стоит после authenticateToken, чтобы видеть пользователя API-токена.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.rateLimiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p := app.rateLimiter.policies[policyFor(r)]
+		if p.off {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		keyType, key := "ip", app.clientIP(r)
+		if p.name != "static" {
+			if userID, err := app.getCurrentUser(r); err == nil {
+				keyType, key = "user", strconv.Itoa(userID)
+			}
+		}
+		ok, remaining, reset := app.rateLimiter.take(p, p.name+":"+keyType+":"+key, time.Now())
+
+		seconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(p.burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", seconds)
+		if !ok {
+			rateLimitRejections.WithLabelValues(p.name, keyType).Inc()
+			w.Header().Set("Retry-After", seconds)
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				app.apiClientError(w, http.StatusTooManyRequests)
+			} else {
+				app.clientError(w, http.StatusTooManyRequests)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sweepRateLimits каждые interval забывает неактивных посетителей, пока не закрыт done
+func (app *application) sweepRateLimits(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			app.rateLimiter.sweep(10*time.Minute, time.Now())
+		case <-done:
+			return
+		}
+	}
+}
+
+// parseTrustedProxies разбирает -trusted-proxies: список IP и подсетей через запятую
+func parseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("-trusted-proxies: %w", err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func (app *application) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range app.trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP возвращает IP клиента без порта. X-Forwarded-For учитывается, только если запрос пришёл
+// от доверенного прокси: адреса разбираются справа налево, первый недоверенный — клиент.
+// Иначе заголовок мог бы подставить любой.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !app.trustedProxy(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		ip = hops[i]
+		if !app.trustedProxy(ip) {
+			return ip
+		}
+	}
+	return ip
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPolicyFor(t *testing.T) {
+	for _, tt := range []struct {
+		method, path, want string
+	}{
+		{"POST", "/user/login", "auth"},
+		{"GET", "/user/login", "default"},
+		{"POST", "/user/login/2fa", "auth"},
+		{"POST", "/comments/add", "write"},
+		{"POST", "/api/v1/posts/7/comments", "write"},
+		{"GET", "/static/css/main.css", "static"},
+		{"GET", "/staticfile", "default"},
+		{"GET", "/", "default"},
+	} {
+		if got := policyFor(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestParseRatePolicies(t *testing.T) {
+	policies, err := parseRatePolicies("auth=10/m:3, static=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := policies["auth"]; p.every != 6*time.Second || p.burst != 3 {
+		t.Errorf("Expected auth to be 10/m with burst 3, got %+v", p)
+	}
+	if !policies["static"].off || policies["default"].burst == 0 {
+		t.Errorf("Expected static off and default untouched, got %+v", policies)
+	}
+	for _, bad := range []string{"auth", "nope=1/s", "auth=1/d", "auth=0/s", "auth=1/s:x"} {
+		if _, err := parseRatePolicies(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	policies, err := parseRatePolicies("default=2/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.rateLimiter = newRateLimiter(policies)
+
+	get := func(path, remoteAddr string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Корзина на 2 запроса, третий получает 429 с подсказкой, когда повторить
+	for i, remaining := range []string{"1", "0"} {
+		rr := get("/", "198.51.100.1:1234", nil)
+		if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Remaining") != remaining || rr.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("Request %d: expected 200 with %s remaining, got %d %q", i+1, remaining, rr.Code, rr.Header().Get("RateLimit-Remaining"))
+		}
+	}
+	rr := get("/", "198.51.100.1:5678", nil)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "30" {
+		t.Fatalf("Expected 429 with Retry-After 30, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := get("/api/v1/categories", "198.51.100.1:1234", nil); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON 429 from the API, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	// Статика и залогиненный пользователь считаются отдельно от IP
+	if rr := get("/static/css/main.css", "198.51.100.1:1234", nil); rr.Code == http.StatusTooManyRequests {
+		t.Error("Expected static files to have their own limit")
+	}
+	if rr := get("/", "198.51.100.1:1234", alice); rr.Code != http.StatusOK {
+		t.Errorf("Expected a logged in user to have their own bucket, got %d", rr.Code)
+	}
+
+	// X-Forwarded-For учитывается только от доверенного прокси
+	if rr := get("/", "198.51.100.2:1234", nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected another IP to pass, got %d", rr.Code)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected X-Forwarded-For from an untrusted peer to be ignored, got %d", rr.Code)
+	}
+
+	if n := app.rateLimiter.sweep(10*time.Minute, time.Now().Add(11*time.Minute)); n == 0 {
+		t.Error("Expected idle buckets to be swept")
+	}
+	if len(app.rateLimiter.visitors) != 0 {
+		t.Errorf("Expected no buckets left, got %d", len(app.rateLimiter.visitors))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &application{trustedProxies: proxies}
+
+	for _, tt := range []struct {
+		remoteAddr, xff, want string
+	}{
+		{"198.51.100.1:1234", "203.0.113.9", "198.51.100.1"},
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		{"192.0.2.1:1234", "203.0.113.9", "203.0.113.9"},
+		{"10.1.2.3:1234", "1.1.1.1, 203.0.113.9, 10.0.0.5", "203.0.113.9"},
+		{"10.1.2.3:1234", "garbage, 10.0.0.5", "10.0.0.5"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if got := app.clientIP(req); got != tt.want {
+			t.Errorf("%s with %q: expected %s, got %s", tt.remoteAddr, tt.xff, tt.want, got)
+		}
+	}
+
+	if _, err := parseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("Expected a bad CIDR to be rejected")
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// attachReactions заполняет счётчики реакций поста и всех комментариев дерева
+func (app *application) attachReactions(post *models2.Post, comments []*models2.Comment, userID int) error {
+	postReactions, err := app.reactions.Summaries(models2.TargetPost, []int{post.ID}, userID)
+	if err != nil {
+		return err
+	}
+	post.Reactions = postReactions[post.ID]
+
+	var all []*models2.Comment
+	var walk func([]*models2.Comment)
+	walk = func(list []*models2.Comment) {
+		for _, c := range list {
+			all = append(all, c)
+			walk(c.Replies)
+		}
+	}
+	walk(comments)
+
+	ids := make([]int, len(all))
+	for i, c := range all {
+		ids[i] = c.ID
+	}
+	commentReactions, err := app.reactions.Summaries(models2.TargetComment, ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, c := range all {
+		c.Reactions = commentReactions[c.ID]
+	}
+	return nil
+}
+
+// reactionError переводит ошибку ReactionModel в ответ: неизвестный вид — 400, нет цели — 404
+func (app *application) reactionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models2.ErrInvalidReaction):
+		app.clientError(w, http.StatusBadRequest)
+	case errors.Is(err, models2.ErrNoRecord):
+		app.notFound(w)
+	default:
+		app.serverError(w, err)
+	}
+}
+
+// notifyReaction сообщает автору о новой реакции; like и dislike сохраняют прежние типы уведомлений
+func (app *application) notifyReaction(targetType, kind string, recipientID, actorID, postID, commentID int) {
+	if recipientID == actorID {
+		return
+	}
+	ntype := targetType + "_reaction"
+	if kind == models2.ReactionLike || kind == models2.ReactionDislike {
+		ntype = targetType + "_" + kind
+	}
+	if err := app.notificationsModel.Insert(recipientID, actorID, ntype, postID, commentID); err != nil {
+		app.errorLog.Println("Failed to create notification:", err)
+	}
+}
+
+// reactToPost ставит или снимает реакцию из поля kind; /post/like и /post/dislike — её старые псевдонимы
+func (app *application) reactToPost(w http.ResponseWriter, r *http.Request) {
+	app.togglePostReaction(w, r, r.FormValue("kind"))
+}
+
+func (app *application) likePost(w http.ResponseWriter, r *http.Request) {
+	app.togglePostReaction(w, r, models2.ReactionLike)
+}
+
+func (app *application) dislikePost(w http.ResponseWriter, r *http.Request) {
+	app.togglePostReaction(w, r, models2.ReactionDislike)
+}
+
+func (app *application) removeLikePost(w http.ResponseWriter, r *http.Request) {
+	app.removePostReaction(w, r, models2.ReactionLike)
+}
+
+func (app *application) removeDislikePost(w http.ResponseWriter, r *http.Request) {
+	app.removePostReaction(w, r, models2.ReactionDislike)
+}
+
+func (app *application) togglePostReaction(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	if err != nil || postID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	result, err := app.reactions.Toggle(models2.TargetPost, postID, userID, kind)
+	if err != nil {
+		app.reactionError(w, err)
+		return
+	}
+	if result != "" {
+		if post, err := app.posts.Get(postID); err == nil {
+			app.notifyReaction(models2.TargetPost, result, post.AuthorID, userID, postID, 0)
+		}
+		app.flash(w, r, "Reaction saved!")
+	} else {
+		app.flash(w, r, "Reaction removed!")
+	}
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
+}
+
+func (app *application) removePostReaction(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	if err != nil || postID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if err := app.reactions.Remove(models2.TargetPost, postID, userID, kind); err != nil {
+		app.reactionError(w, err)
+		return
+	}
+	app.flash(w, r, "Reaction removed!")
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
+}
+
+// reactToComment — то же для комментариев; /comment/like и /comment/dislike остаются псевдонимами
+func (app *application) reactToComment(w http.ResponseWriter, r *http.Request) {
+	app.toggleCommentReaction(w, r, r.FormValue("kind"))
+}
+
+func (app *application) likeComment(w http.ResponseWriter, r *http.Request) {
+	app.toggleCommentReaction(w, r, models2.ReactionLike)
+}
+
+func (app *application) dislikeComment(w http.ResponseWriter, r *http.Request) {
+	app.toggleCommentReaction(w, r, models2.ReactionDislike)
+}
+
+func (app *application) removeLikeComment(w http.ResponseWriter, r *http.Request) {
+	app.removeCommentReaction(w, r, models2.ReactionLike)
+}
+
+func (app *application) removeDislikeComment(w http.ResponseWriter, r *http.Request) {
+	app.removeCommentReaction(w, r, models2.ReactionDislike)
+}
+
+// reactionComment читает comment_id из формы и находит комментарий вместе с текущим пользователем
+func (app *application) reactionComment(w http.ResponseWriter, r *http.Request) (*models2.Comment, int, bool) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return nil, 0, false
+	}
+	commentID, err := strconv.Atoi(r.FormValue("comment_id"))
+	if err != nil || commentID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, 0, false
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return nil, 0, false
+	}
+	comment, err := app.comments.GetByID(commentID)
+	if err == nil && comment.Deleted != nil {
+		err = models2.ErrNoRecord
+	}
+	if err != nil {
+		app.reactionError(w, err)
+		return nil, 0, false
+	}
+	return comment, userID, true
+}
+
+func (app *application) toggleCommentReaction(w http.ResponseWriter, r *http.Request, kind string) {
+	comment, userID, ok := app.reactionComment(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := app.reactions.Toggle(models2.TargetComment, comment.ID, userID, kind)
+	if err != nil {
+		app.reactionError(w, err)
+		return
+	}
+	if result != "" {
+		app.notifyReaction(models2.TargetComment, result, comment.UserID, userID, comment.PostID, comment.ID)
+		app.flash(w, r, "Reaction saved!")
+	} else {
+		app.flash(w, r, "Reaction removed!")
+	}
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d#comment-%d", comment.PostID, comment.ID), http.StatusSeeOther)
+}
+
+func (app *application) removeCommentReaction(w http.ResponseWriter, r *http.Request, kind string) {
+	comment, userID, ok := app.reactionComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.reactions.Remove(models2.TargetComment, comment.ID, userID, kind); err != nil {
+		app.reactionError(w, err)
+		return
+	}
+	app.flash(w, r, "Reaction removed!")
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d#comment-%d", comment.PostID, comment.ID), http.StatusSeeOther)
+}
+
+// postReactions показывает, кто отреагировал на пост: /post/reactions/{id}
+func (app *application) postReactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/post/reactions/"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	post, err := app.posts.Get(id)
+	if err != nil {
+		app.reactionError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Post = post
+	if !app.loadReactors(w, r, data, models2.TargetPost, post.ID) {
+		return
+	}
+	app.render(w, http.StatusOK, "reactions.html", data)
+}
+
+// commentReactions — то же для комментария: /comment/reactions/{id}
+func (app *application) commentReactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comment/reactions/"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	comment, err := app.comments.GetByID(id)
+	if err != nil {
+		app.reactionError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Comment = comment
+	if !app.loadReactors(w, r, data, models2.TargetComment, comment.ID) {
+		return
+	}
+	app.render(w, http.StatusOK, "reactions.html", data)
+}
+
+// loadReactors кладёт в данные шаблона счётчики и список отреагировавших
+func (app *application) loadReactors(w http.ResponseWriter, r *http.Request, data *templateData, targetType string, targetID int) bool {
+	userID, _ := app.getCurrentUser(r)
+	summaries, err := app.reactions.Summaries(targetType, []int{targetID}, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return false
+	}
+	reactors, err := app.reactions.Reactors(targetType, targetID)
+	if err != nil {
+		app.serverError(w, err)
+		return false
+	}
+	data.ReactionCounts = summaries[targetID]
+	data.Reactors = reactors
+	data.IsAuthenticated = app.isAuthenticated(r)
+	return true
+}
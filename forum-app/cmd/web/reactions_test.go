@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReactionsConcurrentToggles(t *testing.T) {
+	app := newTestApplication(t)
+	const users = 4
+	for i := 1; i <= users; i++ {
+		loginAs(t, app, fmt.Sprintf("user%d", i), "user")
+	}
+	postID, err := app.posts.Insert("post", "body", "", "user1", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Каждый пользователь жмёт "лайк" трижды и "дизлайк" дважды одновременно с остальными;
+	// итог у каждого зависит от порядка, но счётчики обязаны совпасть с таблицей реакций
+	var wg sync.WaitGroup
+	errs := make(chan error, users*5)
+	for i := 1; i <= users; i++ {
+		for _, kind := range []string{"like", "dislike", "like", "dislike", "like"} {
+			wg.Add(1)
+			go func(userID int, kind string) {
+				defer wg.Done()
+				_, err := app.reactions.Toggle(models2.TargetPost, postID, userID, kind)
+				errs <- err
+			}(i, kind)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post, err := app.posts.Get(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var likes, dislikes int
+	for i := 1; i <= users; i++ {
+		switch kind, _ := app.reactions.Get(models2.TargetPost, postID, i); kind {
+		case models2.ReactionLike:
+			likes++
+		case models2.ReactionDislike:
+			dislikes++
+		}
+	}
+	if post.Likes != likes || post.Dislikes != dislikes {
+		t.Errorf("Expected counters %d/%d, got %d/%d", likes, dislikes, post.Likes, post.Dislikes)
+	}
+
+	fixed, err := app.reactions.RecomputeCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 0 {
+		t.Errorf("Expected counters to be consistent, repair fixed %d row(s)", fixed)
+	}
+}
+
+func TestReactionsRepairAndCleanup(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+	loginAs(t, app, "bob", "user")
+
+	postID, err := app.posts.Insert("post", "body", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := &models2.Comment{PostID: postID, Content: "hi", UserID: 1, Author: "alice"}
+	if err := app.comments.Insert(comment); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.reactions.LikePost(postID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.reactions.DislikePost(postID, 2); err != nil {
+		t.Fatal(err)
+	}
+	// Лайк заменяет дизлайк, а не добавляется к нему
+	if err := app.reactions.LikePost(postID, 2); err != nil {
+		t.Fatal(err)
+	}
+	// Снятие реакции, которой нет, не трогает счётчики
+	if err := app.reactions.RemoveDislikePost(postID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.reactions.LikeComment(comment.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.reactions.LikePost(postID+100, 1); !errors.Is(err, models2.ErrNoRecord) {
+		t.Errorf("Expected ErrNoRecord for a missing post, got %v", err)
+	}
+
+	post, err := app.posts.Get(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Likes != 2 || post.Dislikes != 0 {
+		t.Errorf("Expected 2 likes and 0 dislikes, got %d/%d", post.Likes, post.Dislikes)
+	}
+
+	if _, err := app.posts.DB.Exec(`UPDATE posts SET likes = 40, dislikes = 7`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.posts.DB.Exec(`UPDATE comments SET likes = 0`); err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := app.reactions.RecomputeCounters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 2 {
+		t.Errorf("Expected 2 repaired rows, got %d", fixed)
+	}
+	post, err = app.posts.Get(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Likes != 2 || post.Dislikes != 0 {
+		t.Errorf("Expected repaired counters 2/0, got %d/%d", post.Likes, post.Dislikes)
+	}
+
+	// Удаление поста удаляет и реакции на его комментарии
+	if _, err := app.posts.DeletePost(postID); err != nil {
+		t.Fatal(err)
+	}
+	var left int
+	if err := app.posts.DB.QueryRow(`SELECT COUNT(*) FROM reactions`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("Expected reactions of the deleted post to be removed, %d left", left)
+	}
+}
+
+func TestEmojiReactions(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+	bob := loginAs(t, app, "bob", "user")
+
+	postID, err := app.posts.Insert("post", "body", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(cookie *http.Cookie, path, form string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+	id := strconv.Itoa(postID)
+
+	if code := post(bob, "/post/react", "post_id="+id+"&kind=love"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	// Старый маршрут работает как псевдоним и заменяет реакцию bob
+	if code := post(bob, "/post/like", "post_id="+id); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	if code := post(alice, "/post/react", "post_id="+id+"&kind=laugh"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, code)
+	}
+	if code := post(alice, "/post/react", "post_id="+id+"&kind=angry"); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown kind, got %d", http.StatusBadRequest, code)
+	}
+
+	summaries, err := app.reactions.Summaries(models2.TargetPost, []int{postID}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, c := range summaries[postID] {
+		counts[c.Name] = c.Count
+		if c.Mine != (c.Name == "like") {
+			t.Errorf("Unexpected Mine=%v for %s", c.Mine, c.Name)
+		}
+	}
+	if counts["like"] != 1 || counts["laugh"] != 1 || counts["love"] != 0 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/post/view/"+id, nil))
+	if body := rr.Body.String(); !strings.Contains(body, "😂 1") || !strings.Contains(body, "👍 1") {
+		t.Errorf("Expected per-kind counts on the post page")
+	}
+
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/post/reactions/"+id, nil))
+	if body := rr.Body.String(); rr.Code != http.StatusOK || !strings.Contains(body, "alice") || !strings.Contains(body, "bob") {
+		t.Errorf("Expected both users in the reactions list, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/posts/"+id+"/reactions", nil))
+	var body struct {
+		Reactors []*models2.Reactor `json:"reactors"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Reactors) != 2 {
+		t.Errorf("Expected 2 reactors from the API, got %d", len(body.Reactors))
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"forum-app/internal/diff"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/validator"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type editCommentForm struct {
+	ID      int
+	PostID  int
+	Content string
+	validator.Validator
+}
+
+// revisionView — одна правка для страницы истории: кто, когда и что поменял
+type revisionView struct {
+	Editor  string
+	Created time.Time
+	Changes []diff.Op
+}
+
+// commentEditor возвращает комментарий и пользователя, если тот может его править (автор или модератор)
+func (app *application) commentEditor(w http.ResponseWriter, r *http.Request, commentID int) (*models2.Comment, *models2.User, bool) {
+	comment, err := app.comments.GetByID(commentID)
+	if err == nil && comment.Deleted != nil {
+		err = models2.ErrNoRecord // удалённый комментарий не правят
+	}
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil, false
+	}
+
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, nil, false
+	}
+
+	if comment.UserID != user.ID && !app.mayModerate(r, user) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, nil, false
+	}
+	return comment, user, true
+}
+
+func (app *application) editComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+		comment, _, ok := app.commentEditor(w, r, id)
+		if !ok {
+			return
+		}
+
+		data := app.newTemplateData(w, r)
+		data.Form = editCommentForm{ID: comment.ID, PostID: comment.PostID, Content: comment.Content}
+		app.render(w, http.StatusOK, "edit_comment.html", data)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.PostForm.Get("comment_id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	comment, user, ok := app.commentEditor(w, r, id)
+	if !ok {
+		return
+	}
+
+	form := editCommentForm{
+		ID:      comment.ID,
+		PostID:  comment.PostID,
+		Content: r.PostForm.Get("content"),
+	}
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	if !form.Valid() {
+		data := app.newTemplateData(w, r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit_comment.html", data)
+		return
+	}
+
+	if err := app.comments.Update(comment.ID, user.ID, form.Content); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(w, r, "Comment edited successfully!")
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d#comment-%d", comment.PostID, comment.ID), http.StatusSeeOther)
+}
+
+// commentHistory показывает модератору все правки комментария с подсветкой изменений
+func (app *application) commentHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comment/history/"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !app.mayModerate(r, user) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	comment, err := app.comments.GetByID(id)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	revisions, err := app.comments.Revisions(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.User = user
+	data.Comment = comment
+	data.Revisions = commentRevisionViews(revisions, comment.Content)
+	app.render(w, http.StatusOK, "comment_history.html", data)
+}
+
+// commentRevisionViews сравнивает каждую сохранённую версию со следующей (последнюю — с текущим текстом);
+// самые свежие правки идут первыми
+func commentRevisionViews(revisions []*models2.CommentRevision, current string) []revisionView {
+	views := make([]revisionView, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		next := current
+		if i+1 < len(revisions) {
+			next = revisions[i+1].Content
+		}
+		views = append(views, revisionView{
+			Editor:  revisions[i].Editor,
+			Created: revisions[i].Created,
+			Changes: diff.Words(revisions[i].Content, next),
+		})
+	}
+	return views
+}
+
+// postRevisionView — ревизия поста вместе со списком полей, которые поменяла следующая правка
+type postRevisionView struct {
+	*models2.PostRevision
+	Changed []string
+}
+
+// revisionField — двухколоночное сравнение одного поля поста
+type revisionField struct {
+	Name string
+	Rows []diff.Row
+}
+
+// canEditPost проверяет, что пост правит автор или модератор; иначе сама отвечает ошибкой
+func (app *application) canEditPost(w http.ResponseWriter, r *http.Request, post *models2.Post) bool {
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return false
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return false
+	}
+	if post.AuthorID != user.ID && !app.mayModerate(r, user) {
+		app.clientError(w, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// postRevisions показывает список правок поста и сравнение выбранной версии (?rev=) с той, что её заменила
+func (app *application) postRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/post/revisions/"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	post, err := app.posts.Get(id)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if !app.canEditPost(w, r, post) {
+		return
+	}
+
+	revisions, err := app.posts.Revisions(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Ревизии идут от новых к старым: версию i заменила версия i-1, а самую свежую — текущий пост
+	current := &models2.PostRevision{Title: post.Title, Content: post.Content, ImagePath: post.ImagePath, CategoryIDs: post.CategoryIDs(), Categories: post.Categories}
+	views := make([]postRevisionView, len(revisions))
+	for i, rev := range revisions {
+		next := current
+		if i > 0 {
+			next = revisions[i-1]
+		}
+		views[i] = postRevisionView{PostRevision: rev, Changed: changedFields(rev, next)}
+	}
+
+	data := app.newTemplateData(w, r)
+	data.Post = post
+	data.PostRevisions = views
+
+	if len(views) > 0 {
+		selected := 0
+		if revParam := r.URL.Query().Get("rev"); revParam != "" {
+			revID, err := strconv.Atoi(revParam)
+			if err != nil {
+				app.clientError(w, http.StatusBadRequest)
+				return
+			}
+			selected = -1
+			for i, v := range views {
+				if v.ID == revID {
+					selected = i
+				}
+			}
+			if selected < 0 {
+				app.notFound(w)
+				return
+			}
+		}
+
+		next := current
+		if selected > 0 {
+			next = revisions[selected-1]
+		}
+		rev := revisions[selected]
+		data.Revision = &views[selected]
+		data.RevisionDiff = []revisionField{
+			{Name: "Title", Rows: diff.SideBySide(rev.Title, next.Title)},
+			{Name: "Category", Rows: diff.SideBySide(categoryNames(rev.Categories), categoryNames(next.Categories))},
+			{Name: "Image", Rows: diff.SideBySide(rev.ImagePath, next.ImagePath)},
+			{Name: "Content", Rows: diff.SideBySide(rev.Content, next.Content)},
+		}
+	}
+
+	app.render(w, http.StatusOK, "post_revisions.html", data)
+}
+
+// restorePostRevision откатывает пост к выбранной ревизии
+func (app *application) restorePostRevision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	revisionID, err := strconv.Atoi(r.PostForm.Get("revision_id"))
+	if err != nil || revisionID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	rev, err := app.posts.GetRevision(revisionID)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	post, err := app.posts.Get(rev.PostID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !app.canEditPost(w, r, post) {
+		return
+	}
+
+	editorID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	if err := app.posts.RestoreRevision(rev.ID, editorID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.flash(w, r, "Post restored to the revision from "+humanDate(rev.Created))
+	http.Redirect(w, r, fmt.Sprintf("/post/view/%d", post.ID), http.StatusSeeOther)
+}
+
+func changedFields(old, next *models2.PostRevision) []string {
+	var changed []string
+	if old.Title != next.Title {
+		changed = append(changed, "title")
+	}
+	if old.Content != next.Content {
+		changed = append(changed, "content")
+	}
+	if old.ImagePath != next.ImagePath {
+		changed = append(changed, "image")
+	}
+	if categoryNames(old.Categories) != categoryNames(next.Categories) {
+		changed = append(changed, "category")
+	}
+	return changed
+}
+
+// categoryNames — названия категорий по одному на строку, чтобы сравнивать их построчно
+func categoryNames(categories []*models2.Category) string {
+	names := make([]string, len(categories))
+	for i, c := range categories {
+		names[i] = c.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\n")
+}
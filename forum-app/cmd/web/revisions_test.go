@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEditCommentKeepsHistory(t *testing.T) {
+	app := newTestApplication(t)
+	author := loginAs(t, app, "alice", "user")
+	other := loginAs(t, app, "bob", "user")
+	moderator := loginAs(t, app, "carol", "moderator")
+
+	postID, err := app.posts.Insert("Title", "Body", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment := &models2.Comment{PostID: postID, Content: "first version", UserID: 1, Author: "alice"}
+	if err := app.comments.Insert(comment); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(cookie *http.Cookie, content string) int {
+		form := url.Values{"comment_id": {fmt.Sprint(comment.ID)}, "content": {content}}
+		req := httptest.NewRequest("POST", "/comment/edit", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := edit(other, "hijacked"); code != http.StatusForbidden {
+		t.Errorf("Expected status %d for another user, got %d", http.StatusForbidden, code)
+	}
+	if code := edit(author, "second version"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d for the author, got %d", http.StatusSeeOther, code)
+	}
+	if code := edit(moderator, "third version"); code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d for a moderator, got %d", http.StatusSeeOther, code)
+	}
+
+	got, err := app.comments.GetByID(comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != "third version" || got.Edited == nil {
+		t.Errorf("Expected edited comment with new content, got %q (edited %v)", got.Content, got.Edited)
+	}
+
+	revisions, err := app.comments.Revisions(comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Content != "first version" || revisions[1].Editor != "carol" {
+		t.Fatalf("Unexpected revisions: %+v", revisions)
+	}
+
+	history := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/comment/history/%d", comment.ID), nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := history(author); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a regular user, got %d", http.StatusForbidden, rr.Code)
+	}
+	rr := history(moderator)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for a moderator, got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "<ins") || !strings.Contains(rr.Body.String(), "<del") {
+		t.Error("Expected history page to highlight the changes")
+	}
+}
+
+func TestPostRevisionsAndRestore(t *testing.T) {
+	app := newTestApplication(t)
+	author := loginAs(t, app, "alice", "user")
+	other := loginAs(t, app, "bob", "user")
+
+	postID, err := app.posts.Insert("Old title", "line one\nline two", "", "alice", "approved", 1, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.posts.UpdatePost("New title", "line one\nline 2", "", "alice", 1, postID, 1, []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	// Повторное сохранение без изменений не создаёт ревизию
+	if err := app.posts.UpdatePost("New title", "line one\nline 2", "", "alice", 1, postID, 1, []int{4}); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := app.posts.Revisions(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || revisions[0].Title != "Old title" || revisions[0].Editor != "alice" {
+		t.Fatalf("Unexpected revisions: %+v", revisions)
+	}
+
+	get := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/post/revisions/%d", postID), nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get(other); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for another user, got %d", http.StatusForbidden, rr.Code)
+	}
+	rr := get(author)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "line two") || !strings.Contains(body, "line 2") {
+		t.Error("Expected the side-by-side diff to show both versions of the changed line")
+	}
+
+	form := url.Values{"revision_id": {fmt.Sprint(revisions[0].ID)}}
+	req := httptest.NewRequest("POST", "/post/revisions/restore", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(author)
+	addCSRFToken(app, req)
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, rr.Code)
+	}
+
+	post, err := app.posts.Get(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "Old title" || len(post.Categories) != 1 || post.Categories[0].Name != "News" {
+		t.Errorf("Expected post to be restored, got %q in %v", post.Title, post.CategoryIDs())
+	}
+	revisions, err = app.posts.Revisions(postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Title != "New title" {
+		t.Errorf("Expected the replaced version to be kept as a revision, got %+v", revisions)
+	}
+}
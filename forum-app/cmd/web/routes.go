@@ -13,31 +13,66 @@ func (app *application) routes() http.Handler {
 	// Регистрация файл-сервера как обработчик для всех URL начинающиеся со static
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
+	// Картинки постов лежат в upload-dir, который может быть и вне ui/static
+	mux.Handle("/static/upload/", http.StripPrefix("/static/upload", http.FileServer(http.Dir(app.uploadDir))))
 
 	// Роуты приложения
 	mux.Handle("/post/view/", http.HandlerFunc(app.postView))
-	mux.Handle("/post/create", app.requireAuthentication(http.HandlerFunc(app.postCreateForm)))
+	mux.Handle("/post/create", app.requireAuthentication(app.requireVerifiedEmail(http.HandlerFunc(app.postCreateForm))))
 	mux.Handle("/", http.HandlerFunc(app.home))
 	mux.Handle("/user/signup", http.HandlerFunc(app.userSignup))
 	mux.Handle("/user/login", http.HandlerFunc(app.userLogin))
+	mux.Handle("/user/login/2fa", http.HandlerFunc(app.loginTwoFactor))
+	mux.Handle("/user/password/forgot", http.HandlerFunc(app.forgotPassword))
+	mux.Handle("/user/password/reset", http.HandlerFunc(app.resetPassword))
+	mux.Handle("/user/verify", http.HandlerFunc(app.verifyEmail))
+	mux.Handle("/user/verify/resend", app.requireAuthentication(http.HandlerFunc(app.resendEmailVerification)))
 	mux.Handle("/user/logout", app.requireAuthentication(http.HandlerFunc(app.userLogout)))
 	mux.Handle("/user/profile/", app.requireAuthentication(http.HandlerFunc(app.profile)))
+	mux.Handle("/user/profile/email", app.requireAuthentication(http.HandlerFunc(app.changeEmail)))
 	mux.Handle("/user/profile/changepassword", app.requireAuthentication(http.HandlerFunc(app.changePassword)))
+	mux.Handle("/user/profile/2fa", app.requireAuthentication(http.HandlerFunc(app.twoFactorPage)))
+	mux.Handle("/user/profile/2fa/setup", app.requireAuthentication(http.HandlerFunc(app.setupTwoFactor)))
+	mux.Handle("/user/profile/2fa/enable", app.requireAuthentication(http.HandlerFunc(app.enableTwoFactor)))
+	mux.Handle("/user/profile/2fa/disable", app.requireAuthentication(http.HandlerFunc(app.disableTwoFactor)))
+	mux.Handle("/user/profile/2fa/recovery-codes", app.requireAuthentication(http.HandlerFunc(app.regenerateRecoveryCodes)))
+	mux.Handle("/user/profile/sessions", app.requireAuthentication(http.HandlerFunc(app.sessionsPage)))
+	mux.Handle("/user/profile/sessions/revoke", app.requireAuthentication(http.HandlerFunc(app.revokeSession)))
+	mux.Handle("/user/profile/sessions/revoke-all", app.requireAuthentication(http.HandlerFunc(app.revokeAllSessions)))
+	mux.Handle("/user/profile/notifications", app.requireAuthentication(http.HandlerFunc(app.notificationSettings)))
+	mux.Handle("/user/profile/tokens", app.requireAuthentication(http.HandlerFunc(app.apiTokensPage)))
+	mux.Handle("/user/profile/tokens/create", app.requireAuthentication(http.HandlerFunc(app.createAPIToken)))
+	mux.Handle("/user/profile/tokens/revoke", app.requireAuthentication(http.HandlerFunc(app.revokeAPIToken)))
 	mux.Handle("/post/edit/", app.requireAuthentication(http.HandlerFunc(app.EditPost)))
 	mux.Handle("/post/delete/", app.requireAuthentication(http.HandlerFunc(app.DeletePost)))
+	mux.Handle("/post/revisions/", app.requireAuthentication(http.HandlerFunc(app.postRevisions)))
+	mux.Handle("/post/revisions/restore", app.requireAuthentication(http.HandlerFunc(app.restorePostRevision)))
+	mux.Handle("/post/react", app.requireAuthentication(http.HandlerFunc(app.reactToPost)))
+	mux.Handle("/post/reactions/", http.HandlerFunc(app.postReactions))
 	mux.Handle("/post/like", app.requireAuthentication(http.HandlerFunc(app.likePost)))
 	mux.Handle("/post/dislike", app.requireAuthentication(http.HandlerFunc(app.dislikePost)))
 	mux.Handle("/post/remove-like", app.requireAuthentication(http.HandlerFunc(app.removeLikePost)))
 	mux.Handle("/post/remove-dislike", app.requireAuthentication(http.HandlerFunc(app.removeDislikePost)))
 
+	mux.Handle("/comment/react", app.requireAuthentication(http.HandlerFunc(app.reactToComment)))
+	mux.Handle("/comment/reactions/", http.HandlerFunc(app.commentReactions))
 	mux.Handle("/comment/like", app.requireAuthentication(http.HandlerFunc(app.likeComment)))
 	mux.Handle("/comment/dislike", app.requireAuthentication(http.HandlerFunc(app.dislikeComment)))
 	mux.Handle("/comment/remove-like", app.requireAuthentication(http.HandlerFunc(app.removeLikeComment)))
 	mux.Handle("/comment/remove-dislike", app.requireAuthentication(http.HandlerFunc(app.removeDislikeComment)))
 	// Маршруты для комментариев
-	mux.Handle("/comments/add", app.requireAuthentication(http.HandlerFunc(app.addComment)))
+	mux.Handle("/comments/add", app.requireAuthentication(app.requireVerifiedEmail(http.HandlerFunc(app.addComment))))
 	mux.Handle("/comment/delete", app.requireAuthentication(http.HandlerFunc(app.deleteComment)))
+	mux.Handle("/comment/edit", app.requireAuthentication(http.HandlerFunc(app.editComment)))
+	mux.Handle("/comment/history/", app.requireAuthentication(http.HandlerFunc(app.commentHistory)))
+	mux.Handle("/search", http.HandlerFunc(app.search))
 	mux.Handle("/notifications", app.requireAuthentication(http.HandlerFunc(app.notifications)))
+	mux.Handle("/notifications/open", app.requireAuthentication(http.HandlerFunc(app.openNotification)))
+	mux.Handle("/notifications/read", app.requireAuthentication(http.HandlerFunc(app.markNotificationsRead)))
+	mux.Handle("/notifications/unread", app.requireAuthentication(http.HandlerFunc(app.markNotificationsUnread)))
+	mux.Handle("/notifications/delete", app.requireAuthentication(http.HandlerFunc(app.deleteNotifications)))
+	mux.Handle("/notifications/read-all", app.requireAuthentication(http.HandlerFunc(app.markAllNotificationsRead)))
+	mux.Handle("/notifications/stream", app.requireAuthentication(http.HandlerFunc(app.notificationStream)))
 	mux.Handle("/user/googlecallback", http.HandlerFunc(app.googleCallbackHandler))
 	mux.Handle("/user/login/google", http.HandlerFunc(app.googleLogin))
 	mux.Handle("/user/githubcallback", http.HandlerFunc(app.githubCallbackHandler))
@@ -62,11 +97,22 @@ func (app *application) routes() http.Handler {
 	mux.Handle("/admin/categories/delete", app.requireRole("admin", http.HandlerFunc(app.deleteCategory)))
 
 	mux.Handle("/admin/users", app.requireRole("admin", http.HandlerFunc(app.manageUsers)))
+	mux.Handle("/admin/locked-accounts", app.requireRole("admin", http.HandlerFunc(app.lockedAccounts)))
+	mux.Handle("/admin/locked-accounts/unlock", app.requireRole("admin", http.HandlerFunc(app.unlockAccount)))
 
 	mux.Handle("/user/apply-moderator", app.requireAuthentication(http.HandlerFunc(app.applyForModerator)))
 
+	// JSON API
+	mux.Handle("/api/", http.HandlerFunc(app.apiNotFound))
+	mux.Handle("/api/v1/posts", http.HandlerFunc(app.apiPosts))
+	mux.Handle("/api/v1/posts/", http.HandlerFunc(app.apiPost))
+	mux.Handle("/api/v1/comments/", http.HandlerFunc(app.apiComment))
+	mux.Handle("/api/v1/search", http.HandlerFunc(app.apiSearch))
+	mux.Handle("/api/v1/categories", http.HandlerFunc(app.apiCategories))
+	mux.Handle("/api/v1/categories/", http.HandlerFunc(app.apiCategory))
+
 	mux.Handle("/metrics", promhttp.Handler())
 
-	return enableCORS(app.recoverPanic(app.logRequest(metricsMiddleware(secureHeaders(mux)))))
+	return app.enableCORS(app.recoverPanic(app.logRequest(metricsMiddleware(secureHeaders(app.authenticateToken(app.rateLimit(app.csrf(mux))))))))
 
 }
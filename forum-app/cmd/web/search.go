@@ -0,0 +1,98 @@
+package main
+
+import (
+	models2 "forum-app/internal/models"
+	"forum-app/internal/validator"
+	"net/http"
+	"strings"
+)
+
+// Сколько постов и комментариев показывать на странице поиска
+const searchLimit = 20
+
+// searchQuery разбирает ?q= и решает, видны ли пользователю неодобренные посты
+func (app *application) searchQuery(r *http.Request) models2.SearchQuery {
+	query := models2.ParseSearchQuery(r.URL.Query().Get("q"))
+
+	if userID, err := app.getCurrentUser(r); err == nil {
+		if user, err := app.users.Get(userID); err == nil {
+			query.IncludePending = app.mayModerate(r, user)
+		}
+	}
+	return query
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	query := app.searchQuery(r)
+	posts, err := app.searchModel.Posts(query, searchLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	comments, err := app.searchModel.Comments(query, searchLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.SearchQuery = strings.TrimSpace(r.URL.Query().Get("q"))
+	data.PostHits = posts
+	data.CommentHits = comments
+	app.render(w, http.StatusOK, "search.html", data)
+}
+
+// apiSearch — GET /api/v1/search?q=
+func (app *application) apiSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		app.apiClientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := app.searchQuery(r)
+	if query.Empty() {
+		var v validator.Validator
+		v.AddFieldError("q", "This field cannot be blank")
+		app.apiValidationError(w, v)
+		return
+	}
+	posts, err := app.searchModel.Posts(query, searchLimit)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	comments, err := app.searchModel.Comments(query, searchLimit)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// В JSON маркеры совпадений заменяются на <mark>, как и в HTML
+	type postResult struct {
+		Post    *models2.Post `json:"post"`
+		Snippet string        `json:"snippet"`
+		Rank    float64       `json:"rank"`
+	}
+	type commentResult struct {
+		Comment   *models2.Comment `json:"comment"`
+		PostTitle string           `json:"post_title"`
+		Snippet   string           `json:"snippet"`
+		Rank      float64          `json:"rank"`
+	}
+	postResults := []postResult{}
+	for _, hit := range posts {
+		postResults = append(postResults, postResult{Post: hit.Post, Snippet: string(highlight(hit.Snippet)), Rank: hit.Rank})
+	}
+	commentResults := []commentResult{}
+	for _, hit := range comments {
+		commentResults = append(commentResults, commentResult{Comment: hit.Comment, PostTitle: hit.PostTitle, Snippet: string(highlight(hit.Snippet)), Rank: hit.Rank})
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"posts": postResults, "comments": commentResults})
+}
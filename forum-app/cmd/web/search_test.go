@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	moderator := loginAs(t, app, "mod", "moderator")
+	loginAs(t, app, "alice", "user")
+
+	// Пользователи: 1 mod, 2 alice. Категории: 1 News, 2 Technology
+	goPost, err := app.posts.Insert("Learning Golang", "Goroutines <script>make</script> concurrency easy", "", "alice", "approved", 2, []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.posts.Insert("Weather report", "Concurrency of clouds and rain", "", "mod", "approved", 1, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.posts.Insert("Secret golang draft", "not yet approved", "", "alice", "pending", 2, []int{2}); err != nil {
+		t.Fatal(err)
+	}
+	comment := &models2.Comment{PostID: goPost, Content: "Channels are great for concurrency", UserID: 1, Author: "mod"}
+	if err := app.comments.Insert(comment); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(q string, cookie *http.Cookie) string {
+		req := httptest.NewRequest("GET", "/search?q="+url.QueryEscape(q), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d for %q, got %d", http.StatusOK, q, rr.Code)
+		}
+		return rr.Body.String()
+	}
+
+	tests := []struct {
+		name     string
+		q        string
+		cookie   *http.Cookie
+		contains []string
+		excludes []string
+	}{
+		{"prefix", "golan*", nil, []string{"Learning <mark>Golang</mark>"}, []string{"Secret"}},
+		{"pending visible to moderators", "golang", moderator, []string{"Secret", "(pending)"}, nil},
+		{"phrase", `"clouds and rain"`, nil, []string{"Weather report"}, []string{"Learning"}},
+		{"phrase order matters", `"rain and clouds"`, nil, []string{"No posts found."}, nil},
+		{"author filter", "concurrency author:alice", nil, []string{"Learning"}, []string{"Weather report"}},
+		{"category filter", "concurrency category:news", nil, []string{"Weather report"}, []string{"Learning"}},
+		{"comments", "channels", nil, []string{"<mark>Channels</mark> are great"}, nil},
+		{"snippet is escaped", "goroutines", nil, []string{"&lt;script&gt;"}, []string{"<script>make"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := search(tt.q, tt.cookie)
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("Expected results for %q to contain %q", tt.q, s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("Expected results for %q not to contain %q", tt.q, s)
+				}
+			}
+		})
+	}
+
+	// Индекс обновляется триггерами при правке и удалении
+	if err := app.posts.UpdatePost("Learning Rust", "Ownership", "", "alice", 2, goPost, 2, []int{2}); err != nil {
+		t.Fatal(err)
+	}
+	if body := search("golang", nil); strings.Contains(body, "Learning") {
+		t.Error("Expected edited post to drop out of the old results")
+	}
+	if body := search("ownership", nil); !strings.Contains(body, "Learning Rust") {
+		t.Error("Expected edited post to be found by its new content")
+	}
+	if _, err := app.posts.DeletePost(goPost); err != nil {
+		t.Fatal(err)
+	}
+	if body := search("channels", nil); !strings.Contains(body, "No comments found.") {
+		t.Error("Expected comments of a deleted post to leave the index")
+	}
+}
+
+func TestAPISearchRanksTitleMatchesFirst(t *testing.T) {
+	app := newTestApplication(t)
+	loginAs(t, app, "alice", "user")
+
+	// Слабых совпадений больше, чем помещается в выдачу: ранжировать нужно до LIMIT
+	for range searchLimit {
+		if _, err := app.posts.Insert("Cooking tips", "Some sqlite trivia buried in a long text about food", "", "alice", "approved", 1, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := app.posts.Insert("SQLite tips", "Indexes", "", "alice", "approved", 1, []int{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/search?q=sqlite", nil)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body struct {
+		Posts []struct {
+			Post struct {
+				Title string `json:"title"`
+			} `json:"post"`
+			Snippet string `json:"snippet"`
+		} `json:"posts"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Posts) != searchLimit || body.Posts[0].Post.Title != "SQLite tips" {
+		t.Fatalf("Expected the title match to rank first, got %d posts starting with %+v", len(body.Posts), body.Posts[:min(1, len(body.Posts))])
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/search?q=", nil)
+	rr = httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for an empty query, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
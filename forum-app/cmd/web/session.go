@@ -2,111 +2,137 @@ package main
 
 import (
 	"errors"
+	models2 "forum-app/internal/models"
 	"github.com/google/uuid"
 	"net/http"
 	"time"
 )
 
-func (app *application) setSession(w http.ResponseWriter, userID int) string {
-
-	app.mu.Lock()
-	defer app.mu.Unlock()
+const (
+	sessionCookieName = "session_id"
+	// Как часто обновлять last_seen, чтобы не писать в базу на каждый запрос
+	sessionTouchInterval = time.Minute
+)
 
-	for sessionID, uid := range app.sessions {
-		if uid == userID {
-			delete(app.sessions, sessionID)
-		}
+// setSession начинает новую сессию; остальные устройства пользователя остаются залогиненными
+func (app *application) setSession(w http.ResponseWriter, r *http.Request, userID int) string {
+	sessionID, err := app.createSession(w, r, userID)
+	if err != nil {
+		app.errorLog.Println("Failed to create session:", err)
 	}
+	return sessionID
+}
 
-	sessionID := uuid.New().String()
-	app.sessions[sessionID] = userID
+// createSession сохраняет новую сессию в хранилище и выставляет cookie
+func (app *application) createSession(w http.ResponseWriter, r *http.Request, userID int) (string, error) {
+	now := time.Now()
+	session := &models2.Session{
+		Token:     uuid.New().String(),
+		UserID:    userID,
+		Expiry:    now.Add(app.sessionTTL),
+		Created:   now,
+		LastSeen:  now,
+		UserAgent: r.UserAgent(),
+		IP:        app.clientIP(r),
+	}
+	if err := app.sessions.Create(session); err != nil {
+		return "", err
+	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
+		Name:     sessionCookieName,
+		Value:    session.Token,
 		Path:     "/",
-		Expires:  time.Now().Add(24 * time.Hour),
+		Expires:  session.Expiry,
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteStrictMode,
 	})
-	return sessionID
+	return session.Token, nil
 }
 
-func (app *application) getCurrentUser(r *http.Request) (int, error) {
-	app.mu.Lock()
-	defer app.mu.Unlock()
-
+func (app *application) currentSession(r *http.Request) (*models2.Session, error) {
 	// Получаем cookie сессии
-	cookie, err := r.Cookie("session_id")
+	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil {
-		return 0, errors.New("no session found")
+		return nil, errors.New("no session found")
 	}
 
 	// Проверяем, существует ли сессия
-	userID, exists := app.sessions[cookie.Value]
-	if !exists {
-		return 0, errors.New("invalid session")
+	session, err := app.sessions.Get(cookie.Value)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			return nil, errors.New("invalid session")
+		}
+		return nil, err
+	}
+
+	if time.Since(session.LastSeen) > sessionTouchInterval {
+		if err := app.sessions.Touch(session.Token, time.Now()); err != nil {
+			return nil, err
+		}
 	}
 
-	return userID, nil
+	return session, nil
 }
 
-func (app *application) renewSessionToken(w http.ResponseWriter, r *http.Request) error {
-	app.mu.Lock()
-	defer app.mu.Unlock()
+func (app *application) getCurrentUser(r *http.Request) (int, error) {
+	// Запросы с Authorization: Bearer уже проверены в authenticateToken
+	if token, ok := r.Context().Value(apiTokenContextKey).(*models2.APIToken); ok {
+		return token.UserID, nil
+	}
 
-	// Получаем текущую сессию
-	cookie, err := r.Cookie("session_id")
+	session, err := app.currentSession(r)
 	if err != nil {
-		return errors.New("no session found")
+		return 0, err
 	}
+	return session.UserID, nil
+}
 
-	// Проверяем существование сессии
-	userID, exists := app.sessions[cookie.Value]
-	if !exists {
-		return errors.New("invalid session")
+func (app *application) renewSessionToken(w http.ResponseWriter, r *http.Request) error {
+	// Получаем текущую сессию
+	session, err := app.currentSession(r)
+	if err != nil {
+		return err
 	}
 
-	// Удаляем ВСЕ сессии пользователя
-	for sessionID, uid := range app.sessions {
-		if uid == userID {
-			delete(app.sessions, sessionID)
-		}
+	// Удаляем только текущую сессию, другие устройства не трогаем
+	if err := app.sessions.Delete(session.Token); err != nil {
+		return err
 	}
 
-	// Создаем новую сессию
-	newSessionID := uuid.New().String()
-	app.sessions[newSessionID] = userID
-
-	// Обновляем cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    newSessionID,
-		Path:     "/",
-		Expires:  time.Now().Add(24 * time.Hour),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	// Создаем новую сессию и обновляем cookie
+	_, err = app.createSession(w, r, session.UserID)
+	return err
+}
 
+// deleteAllSessions завершает сессии пользователя на всех устройствах, включая текущее
+func (app *application) deleteAllSessions(w http.ResponseWriter, userID int) error {
+	if err := app.sessions.DeleteByUser(userID); err != nil {
+		return err
+	}
+	app.expireSessionCookie(w)
 	return nil
 }
 
-func (app *application) deleteSession(w http.ResponseWriter, r *http.Request) {
-	app.mu.Lock()
-	defer app.mu.Unlock()
-
-	cookie, err := r.Cookie("session_id")
+func (app *application) deleteSession(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil {
-		http.Error(w, "No session found", http.StatusUnauthorized)
-		return
+		return errors.New("no session found")
+	}
+
+	if err := app.sessions.Delete(cookie.Value); err != nil {
+		return err
 	}
 
-	delete(app.sessions, cookie.Value)
 	// Удаление cookie на клиенте
+	app.expireSessionCookie(w)
+	return nil
+}
+
+func (app *application) expireSessionCookie(w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
+		Name:     sessionCookieName,
 		Value:    "",
 		Path:     "/",
 		MaxAge:   -1,
@@ -114,6 +140,26 @@ func (app *application) deleteSession(w http.ResponseWriter, r *http.Request) {
 		Secure:   true,
 		SameSite: http.SameSiteStrictMode,
 	})
+}
 
-	app.flash(w, r, "Logout successfully")
+// sweepSessions периодически удаляет просроченные сессии из хранилища
+func (app *application) sweepSessions(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := app.sessions.DeleteExpired()
+			if err != nil {
+				app.errorLog.Println("Failed to delete expired sessions:", err)
+				continue
+			}
+			if n > 0 {
+				app.infoLog.Printf("Deleted %d expired session(s)", n)
+			}
+		case <-done:
+			return
+		}
+	}
 }
@@ -5,39 +5,178 @@ import (
 	models2 "forum-app/internal/models"
 	"html/template"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 // templateData — структура для хранения данных, передаваемых в HTML-шаблоны
 type templateData struct {
-	CurrentYear         int
-	Post                *models2.Post   // Один пост (для страницы просмотра одного поста)
-	Posts               []*models2.Post // Список постов (например, для главной страницы)
-	User                *models2.User
-	Users               []*models2.User
-	Comment             *models2.Comment
-	Comments            []*models2.Comment
-	Notifications       []*models2.Notification
-	UnreadNotifications int
-	Categories          []*models2.Category
-	Form                any
-	IsLiked             bool
-	IsDisliked          bool
-	SelectedCategory    string
-	Flash               string
-	IsAuthenticated     bool
-	Status              int
-	Message             string
-	PendingPosts        []*models2.Post
-	Reports             []*models2.Report
+	CurrentYear        int
+	Post               *models2.Post   // Один пост (для страницы просмотра одного поста)
+	Posts              []*models2.Post // Список постов (например, для главной страницы)
+	User               *models2.User
+	Users              []*models2.User
+	Comment            *models2.Comment
+	Comments           []*models2.Comment
+	NotificationGroups []*models2.NotificationGroup
+	NotificationType   string   // выбранный фильтр по типу, "" — все
+	NotificationTypes  []string // все типы для фильтра
+	// Настройки уведомлений: канал по категории и варианты для формы
+	NotificationPreferences map[string]string
+	NotificationCategories  []string
+	NotificationChannels    []string
+	UnreadNotifications     int
+	Categories              []*models2.Category
+	Form                    any
+	IsLiked                 bool
+	IsDisliked              bool
+	CategoryFilter          models2.CategoryFilter
+	PersonalFilter          models2.PersonalFilter
+	Flash                   string
+	CSRFToken               string // для скрытого поля csrf_token в формах
+	GoogleLogin             bool   // вход через Google настроен
+	GitHubLogin             bool
+	IsAuthenticated         bool
+	Status                  int
+	Message                 string
+	PendingPosts            []*models2.Post
+	Reports                 []*models2.Report
+	Sessions                []*models2.Session
+	CurrentSessionID        int
+	APITokens               []*models2.APIToken
+	NewAPIToken             string
+	TwoFactor               *models2.TwoFactor
+	TwoFactorRequired       bool     // роль пользователя требует 2FA (-require-2fa)
+	TOTPURI                 string   // otpauth:// для приложения-аутентификатора во время настройки
+	RecoveryCodes           []string // только что выпущенные коды восстановления
+	LockedAccounts          []*models2.LockedAccount
+	Revisions               []revisionView
+	PostRevisions           []postRevisionView
+	Revision                *postRevisionView
+	RevisionDiff            []revisionField
+	SearchQuery             string
+	PostHits                []*models2.PostHit
+	CommentHits             []*models2.CommentHit
+	Sort                    string   // выбранный режим сортировки ленты
+	Sorts                   []string // все режимы для переключателя
+	PrevPage                string   // ссылки на соседние страницы ленты, пустые на краях
+	NextPage                string
+	ReactionCounts          []models2.ReactionCount
+	Reactors                []*models2.Reactor
 }
 
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 15:04")
 }
 
+// deviceName превращает User-Agent в короткое описание вида "Firefox on Windows"
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"), strings.Contains(userAgent, "Opera"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(userAgent, "curl/"):
+		browser = "curl"
+	}
+
+	platform := "unknown OS"
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		platform = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
+
+// highlight экранирует сниппет поиска и превращает маркеры совпадений в <mark>
+func highlight(snippet string) template.HTML {
+	escaped := template.HTMLEscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, models2.SnippetOpen, "<mark>")
+	escaped = strings.ReplaceAll(escaped, models2.SnippetClose, "</mark>")
+	return template.HTML(escaped)
+}
+
+// commentNode передаёт в рекурсивный шаблон "comment" сам комментарий и данные страницы
+type commentNode struct {
+	Comment *models2.Comment
+	Page    *templateData
+}
+
+func newCommentNode(c *models2.Comment, page *templateData) commentNode {
+	return commentNode{Comment: c, Page: page}
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"commentNode":      newCommentNode,
+	"humanDate":        humanDate,
+	"deviceName":       deviceName,
+	"highlight":        highlight,
+	"actorList":        actorList,
+	"notificationText": notificationText,
+}
+
+// notificationText — что сделал автор уведомления: "liked your post"
+func notificationText(ntype string) string {
+	switch ntype {
+	case models2.NotificationPostLike:
+		return "liked your post"
+	case models2.NotificationPostDislike:
+		return "disliked your post"
+	case models2.NotificationPostReaction:
+		return "reacted to your post"
+	case models2.NotificationCommentReaction:
+		return "reacted to your comment"
+	case models2.NotificationComment:
+		return "commented on your post"
+	case models2.NotificationReply:
+		return "replied to your comment"
+	case models2.NotificationCommentLike:
+		return "liked your comment"
+	case models2.NotificationCommentDislike:
+		return "disliked your comment"
+	case models2.NotificationMention:
+		return "mentioned you in a comment"
+	case models2.NotificationPostApproved:
+		return "approved your post"
+	case models2.NotificationReportAnswered:
+		return "answered your report"
+	default:
+		return ntype
+	}
+}
+
+// actorList склеивает имена группы уведомлений: "alice, bob and carol", "alice, bob, carol and 2 others"
+func actorList(names []string, others int) string {
+	switch {
+	case others == 1:
+		return strings.Join(names, ", ") + " and 1 other"
+	case others > 1:
+		return fmt.Sprintf("%s and %d others", strings.Join(names, ", "), others)
+	case len(names) > 1:
+		return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	default:
+		return strings.Join(names, "")
+	}
 }
 
 // newTemplateCache создаёт кэш шаблонов, чтобы не парсить их каждый раз
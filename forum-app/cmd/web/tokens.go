@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type apiTokenForm struct {
+	Name      string
+	Scope     string
+	ExpiresIn string
+	validator.Validator
+}
+
+// apiTokensPage показывает персональные токены пользователя и форму выпуска нового
+func (app *application) apiTokensPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	app.renderAPITokens(w, r, userID, http.StatusOK, &apiTokenForm{Scope: models2.ScopeRead, ExpiresIn: "30"}, "")
+}
+
+func (app *application) renderAPITokens(w http.ResponseWriter, r *http.Request, userID, status int, form *apiTokenForm, newToken string) {
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tokens, err := app.apiTokens.ListByUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.User = user
+	data.APITokens = tokens
+	data.NewAPIToken = newToken
+	data.Form = form
+	app.render(w, status, "tokens.html", data)
+}
+
+func (app *application) createAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := &apiTokenForm{
+		Name:      r.PostForm.Get("name"),
+		Scope:     r.PostForm.Get("scope"),
+		ExpiresIn: r.PostForm.Get("expires_in"),
+	}
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 50), "name", "This field cannot be longer than 50 characters")
+	form.CheckField(models2.ValidScope(form.Scope), "scope", "Please choose a valid scope")
+	if form.Scope == models2.ScopeModeration {
+		form.CheckField(canModerate(user), "scope", "Only moderators and admins can create moderation tokens")
+	}
+	form.CheckField(validator.PermittedValue(form.ExpiresIn, "30", "90", "365", "0"), "expires_in", "Please choose a valid expiry")
+	if !form.Valid() {
+		app.renderAPITokens(w, r, userID, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	var expiry time.Time
+	if days, _ := strconv.Atoi(form.ExpiresIn); days > 0 {
+		expiry = time.Now().AddDate(0, 0, days)
+	}
+
+	token, err := app.apiTokens.Insert(userID, form.Name, form.Scope, expiry)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Токен в открытом виде показывается только один раз, поэтому без редиректа
+	app.renderAPITokens(w, r, userID, http.StatusCreated, &apiTokenForm{Scope: models2.ScopeRead, ExpiresIn: "30"}, token)
+}
+
+func (app *application) revokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.apiTokens.Delete(userID, id)
+	if err != nil {
+		if errors.Is(err, models2.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.flash(w, r, "Token revoked successfully!")
+	http.Redirect(w, r, "/user/profile/tokens", http.StatusSeeOther)
+}
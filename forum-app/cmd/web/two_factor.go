@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/totp"
+	"forum-app/internal/validator"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Второй шаг входа: на ввод кода даётся 5 минут и 5 попыток, дальше вход начинается заново с пароля
+const (
+	loginChallengeCookie      = "login_challenge"
+	loginChallengeTTL         = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
+)
+
+// totpIssuer — название сайта в приложении-аутентификаторе
+const totpIssuer = "Forum"
+
+// Роли, для которых можно сделать 2FA обязательной (-require-2fa)
+var twoFactorRoleChoices = []string{"admin", "moderator"}
+
+type twoFactorForm struct {
+	Code            string `form:"code"`
+	CurrentPassword string `form:"currentPassword"`
+	validator.Validator
+}
+
+// parseTwoFactorRoles разбирает значение -require-2fa: "admin,moderator"
+func parseTwoFactorRoles(s string) (map[string]bool, error) {
+	roles := map[string]bool{}
+	for _, role := range strings.Split(s, ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+		if !validator.PermittedValue(role, twoFactorRoleChoices...) {
+			return nil, fmt.Errorf("-require-2fa: unknown role %q, expected %s", role, strings.Join(twoFactorRoleChoices, " or "))
+		}
+		roles[role] = true
+	}
+	return roles, nil
+}
+
+// twoFactorMissing сообщает, что роль пользователя требует 2FA, а он её не включил
+func (app *application) twoFactorMissing(user *models2.User) (bool, error) {
+	if !app.twoFactorRoles[user.Role] {
+		return false, nil
+	}
+	tf, err := app.twoFactor.Get(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return !tf.Enabled, nil
+}
+
+// twoFactorPage — /user/profile/2fa: состояние 2FA, настройка и управление кодами восстановления
+func (app *application) twoFactorPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		http.Redirect(w, r, "/user/login", http.StatusFound)
+		return
+	}
+	app.renderTwoFactor(w, r, userID, http.StatusOK, &twoFactorForm{}, nil)
+}
+
+// renderTwoFactor показывает страницу 2FA; recoveryCodes показываются один раз, сразу после выпуска
+func (app *application) renderTwoFactor(w http.ResponseWriter, r *http.Request, userID, status int, form *twoFactorForm, recoveryCodes []string) {
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	tf, err := app.twoFactor.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(w, r)
+	data.User = user
+	data.TwoFactor = tf
+	data.TwoFactorRequired = app.twoFactorRoles[user.Role]
+	if !tf.Enabled && tf.Secret != "" {
+		data.TOTPURI = totp.URI(totpIssuer, user.Email, tf.Secret)
+	}
+	data.RecoveryCodes = recoveryCodes
+	data.Form = form
+	app.render(w, status, "two_factor.html", data)
+}
+
+// setupTwoFactor — /user/profile/2fa/setup: новый секрет, который нужно подтвердить кодом
+func (app *application) setupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err := app.twoFactor.Begin(userID, secret); errors.Is(err, models2.ErrTwoFactorEnabled) {
+		app.flash(w, r, "Two-factor authentication is already enabled.")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/user/profile/2fa", http.StatusSeeOther)
+}
+
+// enableTwoFactor — /user/profile/2fa/enable: подтверждение секрета первым кодом из приложения
+func (app *application) enableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	tf, err := app.twoFactor.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if tf.Enabled || tf.Secret == "" {
+		http.Redirect(w, r, "/user/profile/2fa", http.StatusSeeOther)
+		return
+	}
+
+	form := &twoFactorForm{Code: r.PostFormValue("code")}
+	step, ok := totp.Validate(tf.Secret, form.Code, time.Now())
+	form.CheckField(ok, "code", "This code is incorrect. Check the time on your device and try again.")
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, userID, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	codes, err := app.twoFactor.Enable(userID, step)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.renderTwoFactor(w, r, userID, http.StatusOK, &twoFactorForm{}, codes)
+}
+
+// disableTwoFactor — /user/profile/2fa/disable: нужны пароль и код; для ролей из -require-2fa недоступно
+func (app *application) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if app.twoFactorRoles[user.Role] {
+		app.flash(w, r, "Two-factor authentication is required for your role and cannot be turned off.")
+		http.Redirect(w, r, "/user/profile/2fa", http.StatusSeeOther)
+		return
+	}
+
+	form := &twoFactorForm{Code: r.PostFormValue("code"), CurrentPassword: r.PostFormValue("currentPassword")}
+	form.CheckField(bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(form.CurrentPassword)) == nil, "currentPassword", "The current password is incorrect.")
+	if form.Valid() {
+		ok, _, err := app.checkSecondFactor(userID, form.Code)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.CheckField(ok, "code", "This code is incorrect.")
+	}
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, userID, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	if err := app.twoFactor.Disable(userID); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.flash(w, r, "Two-factor authentication has been turned off.")
+	http.Redirect(w, r, "/user/profile/2fa", http.StatusSeeOther)
+}
+
+// regenerateRecoveryCodes — /user/profile/2fa/recovery-codes: новые коды взамен всех прежних
+func (app *application) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	form := &twoFactorForm{Code: r.PostFormValue("code")}
+	ok, _, err := app.checkSecondFactor(userID, form.Code)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	form.CheckField(ok, "code", "This code is incorrect.")
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, userID, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	codes, err := app.twoFactor.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.renderTwoFactor(w, r, userID, http.StatusOK, &twoFactorForm{}, codes)
+}
+
+// checkSecondFactor принимает код из приложения (6 цифр) или код восстановления.
+// Каждый код срабатывает один раз.
+func (app *application) checkSecondFactor(userID int, code string) (ok, recovery bool, err error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, false, nil
+	}
+	tf, err := app.twoFactor.Get(userID)
+	if err != nil || !tf.Enabled {
+		return false, false, err
+	}
+
+	if step, valid := totp.Validate(tf.Secret, code, time.Now()); valid {
+		ok, err := app.twoFactor.UseStep(userID, step)
+		return ok, false, err
+	}
+	ok, err = app.twoFactor.UseRecoveryCode(userID, code)
+	return ok, ok, err
+}
+
+// startLogin вызывается после проверки пароля или входа через Google/GitHub:
+// с включённой 2FA сессия появится только после второго шага
+func (app *application) startLogin(w http.ResponseWriter, r *http.Request, userID int) {
+	tf, err := app.twoFactor.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !tf.Enabled {
+		app.completeLogin(w, r, userID)
+		return
+	}
+
+	token, err := app.twoFactor.CreateChallenge(userID, loginChallengeTTL, time.Now())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginChallengeCookie,
+		Value:    token,
+		Path:     "/user/login",
+		MaxAge:   int(loginChallengeTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+}
+
+// completeLogin создаёт сессию. Пользователя, чья роль требует 2FA, сразу отправляем её настроить.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, userID int) {
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	missing, err := app.twoFactorMissing(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Вход состоялся: неудачные попытки по этому адресу больше не считаются
+	if err := app.loginFailures.Clear(user.Email); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.setSession(w, r, userID)
+	if missing {
+		app.flash(w, r, "Your role requires two-factor authentication. Please set it up to continue.")
+		http.Redirect(w, r, "/user/profile/2fa", http.StatusSeeOther)
+		return
+	}
+	app.flash(w, r, "Account logged in successfully!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) expireLoginChallenge(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: loginChallengeCookie, Value: "", Path: "/user/login", MaxAge: -1, HttpOnly: true, Secure: true})
+}
+
+// loginTwoFactor — /user/login/2fa: второй шаг входа
+func (app *application) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	var token string
+	if cookie, err := r.Cookie(loginChallengeCookie); err == nil {
+		token = cookie.Value
+	}
+	userID, err := app.twoFactor.Challenge(token, time.Now())
+	if errors.Is(err, models2.ErrInvalidChallenge) {
+		app.expireLoginChallenge(w)
+		app.flash(w, r, "Your login has expired. Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := &twoFactorForm{}
+	if r.Method == http.MethodGet {
+		data := app.newTemplateData(w, r)
+		data.Form = form
+		app.render(w, http.StatusOK, "login_2fa.html", data)
+		return
+	}
+
+	// Неверные коды считаются неудачными входами наравне с паролем: иначе, зная пароль,
+	// можно перебирать коды, каждый раз начиная вход заново
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	wait, err := app.loginWait(user.Email, app.clientIP(r), app.now())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if wait > 0 {
+		app.tooManyLogins(w, r, "login_2fa.html", form, wait)
+		return
+	}
+
+	form.Code = r.PostFormValue("code")
+	ok, recovery, err := app.checkSecondFactor(userID, form.Code)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		if err := app.recordLoginFailure(user.Email, app.clientIP(r), app.now()); err != nil {
+			app.serverError(w, err)
+			return
+		}
+		attempts, err := app.twoFactor.FailChallenge(token, loginChallengeMaxAttempts)
+		if err != nil && !errors.Is(err, models2.ErrInvalidChallenge) {
+			app.serverError(w, err)
+			return
+		}
+		if attempts >= loginChallengeMaxAttempts || errors.Is(err, models2.ErrInvalidChallenge) {
+			app.expireLoginChallenge(w)
+			app.flash(w, r, "Too many incorrect codes. Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		form.AddFieldError("code", "This code is incorrect.")
+		data := app.newTemplateData(w, r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_2fa.html", data)
+		return
+	}
+
+	if err := app.twoFactor.DeleteChallenge(token); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.expireLoginChallenge(w)
+	if recovery {
+		app.infoLog.Printf("User %d logged in with a recovery code", userID)
+	}
+	app.completeLogin(w, r, userID)
+}
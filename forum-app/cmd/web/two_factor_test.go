@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"forum-app/internal/config"
+	"forum-app/internal/totp"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var recoveryCodeRX = regexp.MustCompile(`[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}`)
+
+func TestTwoFactorLogin(t *testing.T) {
+	app := newTestApplication(t)
+	alice := loginAs(t, app, "alice", "user")
+
+	send := func(path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	cookie := func(rr *httptest.ResponseRecorder, name string) *http.Cookie {
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == name && c.MaxAge >= 0 {
+				return c
+			}
+		}
+		return nil
+	}
+
+	// Настройка: секрет подтверждается кодом, в ответ — коды восстановления
+	send("/user/profile/2fa/setup", nil, alice)
+	tf, err := app.twoFactor.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tf.Secret == "" || tf.Enabled {
+		t.Fatalf("Expected a pending secret, got %+v", tf)
+	}
+	if rr := send("/user/profile/2fa/enable", url.Values{"code": {"000000"}}, alice); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a wrong code to be rejected, got %d", rr.Code)
+	}
+	now := time.Now()
+	code, _ := totp.Code(tf.Secret, totp.Step(now))
+	rr := send("/user/profile/2fa/enable", url.Values{"code": {code}}, alice)
+	codes := recoveryCodeRX.FindAllString(rr.Body.String(), -1)
+	if rr.Code != http.StatusOK || len(codes) != 10 {
+		t.Fatalf("Expected 10 recovery codes, got %d (status %d)", len(codes), rr.Code)
+	}
+
+	login := func() *http.Cookie {
+		rr := send("/user/login", url.Values{"email": {"alice@example.com"}, "password": {"ValidPass123!"}})
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login/2fa" {
+			t.Fatalf("Expected the second step, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+		if cookie(rr, sessionCookieName) != nil {
+			t.Fatal("Expected no session before the second step")
+		}
+		return cookie(rr, loginChallengeCookie)
+	}
+
+	challenge := login()
+	if rr := send("/user/login/2fa", url.Values{"code": {code}}, challenge); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected the code used for setup not to work again, got %d", rr.Code)
+	}
+	next, _ := totp.Code(tf.Secret, totp.Step(now)+1)
+	rr = send("/user/login/2fa", url.Values{"code": {next}}, challenge)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" || cookie(rr, sessionCookieName) == nil {
+		t.Fatalf("Expected a session after the second step, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := send("/user/login/2fa", url.Values{"code": {next}}, challenge); rr.Header().Get("Location") != "/user/login" {
+		t.Errorf("Expected a finished challenge to be gone, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	// Код восстановления срабатывает один раз, регистр и пробелы не важны
+	challenge = login()
+	if rr := send("/user/login/2fa", url.Values{"code": {" " + strings.ToUpper(codes[0]) + " "}}, challenge); cookie(rr, sessionCookieName) == nil {
+		t.Errorf("Expected a recovery code to log in, got %d", rr.Code)
+	}
+	challenge = login()
+	if rr := send("/user/login/2fa", url.Values{"code": {codes[0]}}, challenge); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a used recovery code to be rejected, got %d", rr.Code)
+	}
+	if tf, _ := app.twoFactor.Get(1); tf.RecoveryCodes != 9 {
+		t.Errorf("Expected 9 recovery codes left, got %d", tf.RecoveryCodes)
+	}
+
+	// После пяти неверных кодов вход начинается заново. Задержки между неудачными входами
+	// проверяются в TestLoginThrottle, здесь они сбрасываются
+	challenge = login()
+	for i := 1; i <= loginChallengeMaxAttempts; i++ {
+		if err := app.loginFailures.Clear("alice@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		rr = send("/user/login/2fa", url.Values{"code": {"000000"}}, challenge)
+	}
+	if rr.Header().Get("Location") != "/user/login" {
+		t.Errorf("Expected too many wrong codes to restart the login, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestRequiredTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+	moderator := loginAs(t, app, "carol", "moderator")
+	app.twoFactorRoles = map[string]bool{"moderator": true}
+
+	req := httptest.NewRequest("GET", "/moderation", nil)
+	req.AddCookie(moderator)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/profile/2fa" {
+		t.Errorf("Expected moderators without 2FA to be sent to the setup, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	if _, err := parseTwoFactorRoles("admin, moderator"); err != nil {
+		t.Error(err)
+	}
+	if _, err := parseTwoFactorRoles("user"); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+}
+
+// oauthProvider отвечает на запросы oauth2 вместо Google: токен и профиль пользователя
+type oauthProvider struct{}
+
+func (oauthProvider) RoundTrip(r *http.Request) (*http.Response, error) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Type", "application/json")
+	if strings.Contains(r.URL.Path, "token") {
+		rr.WriteString(`{"access_token":"access","token_type":"Bearer"}`)
+	} else {
+		rr.WriteString(`{"id":"g-1","email":"dave@example.com","name":"dave"}`)
+	}
+	return rr.Result(), nil
+}
+
+func TestOAuthLoginRequiresTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+	app.googleOAuth = googleOAuthConfig(config.OAuth{ClientID: "id", ClientSecret: "secret", RedirectURL: "http://localhost/user/googlecallback"})
+
+	callback := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/user/googlecallback?code=abc", nil)
+		req = req.WithContext(context.WithValue(req.Context(), oauth2.HTTPClient, &http.Client{Transport: oauthProvider{}}))
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	hasSession := func(rr *httptest.ResponseRecorder) bool {
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == sessionCookieName && c.MaxAge >= 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Без 2FA вход через провайдера сразу создаёт сессию
+	rr := callback()
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" || !hasSession(rr) {
+		t.Fatalf("Expected a session after the first login, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	user, err := app.users.GetByEmail("dave@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.twoFactor.Begin(user.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.twoFactor.Enable(user.ID, totp.Step(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	// С 2FA — сначала второй шаг
+	rr = callback()
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login/2fa" || hasSession(rr) {
+		t.Errorf("Expected the 2FA step instead of a session, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
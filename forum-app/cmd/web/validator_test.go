@@ -2,7 +2,7 @@
 package main
 
 import (
-	"forum-app/forum-app/internal/validator"
+	"forum-app/internal/validator"
 	"testing"
 )
 
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	models2 "forum-app/internal/models"
+	"forum-app/internal/signer"
+	"forum-app/internal/validator"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Подтверждение адреса: ссылка живёт сутки, писем — не больше трёх в час на пользователя
+const (
+	emailVerificationTTL     = 24 * time.Hour
+	emailVerificationLimit   = 3
+	emailVerificationWindow  = time.Hour
+	emailVerificationPurpose = "verify-email"
+)
+
+// emailClaims — содержимое подписанной ссылки. Email — адрес пользователя на момент отправки,
+// NewEmail — подтверждаемый адрес (при регистрации они совпадают).
+type emailClaims struct {
+	UserID   int    `json:"uid"`
+	Email    string `json:"email"`
+	NewEmail string `json:"new_email"`
+}
+
+type changeEmailForm struct {
+	Email               string `form:"email"`
+	CurrentPassword     string `form:"currentPassword"`
+	validator.Validator `form:"-"`
+}
+
+// sendEmailVerification ставит в очередь письмо со ссылкой подтверждения адреса target.
+// Возвращает false, если лимит писем для пользователя исчерпан.
+func (app *application) sendEmailVerification(user *models2.User, target string) (bool, error) {
+	now := time.Now()
+	allowed, err := app.users.AllowVerificationEmail(user.ID, emailVerificationLimit, emailVerificationWindow, now)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	claims := emailClaims{UserID: user.ID, Email: user.Email, NewEmail: target}
+	token, err := app.signer.Sign(emailVerificationPurpose, claims, now.Add(emailVerificationTTL))
+	if err != nil {
+		return false, err
+	}
+	err = app.queueEmail(target, "verify_email", &emailData{
+		Name: user.Name,
+		Link: app.baseURL + "/user/verify?token=" + url.QueryEscape(token),
+	})
+	return err == nil, err
+}
+
+// verifyEmail — /user/verify?token=: переход по ссылке из письма. Вход не нужен:
+// ссылку часто открывают в другом браузере или на телефоне.
+func (app *application) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowed(w)
+		return
+	}
+
+	redirect := "/user/login"
+	if app.isAuthenticated(r) {
+		redirect = "/user/profile/"
+	}
+
+	var claims emailClaims
+	err := app.signer.Verify(emailVerificationPurpose, r.URL.Query().Get("token"), &claims, time.Now())
+	if err == nil {
+		err = app.users.ConfirmEmail(claims.UserID, claims.Email, claims.NewEmail)
+	}
+	switch {
+	case err == nil:
+		app.flash(w, r, "Your email address has been confirmed.")
+	case errors.Is(err, signer.ErrExpired):
+		app.flash(w, r, "This confirmation link has expired. Please request a new one from your profile.")
+	case errors.Is(err, signer.ErrInvalid), errors.Is(err, models2.ErrEmailChanged):
+		app.flash(w, r, "This confirmation link is invalid or no longer valid.")
+	case errors.Is(err, models2.ErrDuplicateEmail):
+		app.flash(w, r, "This email address is already used by another account.")
+	default:
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// resendEmailVerification — /user/verify/resend: повторное письмо подтверждения
+func (app *application) resendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if user.EmailVerified {
+		app.flash(w, r, "Your email address is already confirmed.")
+	} else if sent, err := app.sendEmailVerification(user, user.Email); err != nil {
+		app.serverError(w, err)
+		return
+	} else if sent {
+		app.flash(w, r, "We have sent a new confirmation link to "+user.Email+".")
+	} else {
+		app.flash(w, r, "Too many confirmation emails. Please try again later.")
+	}
+	http.Redirect(w, r, "/user/profile/", http.StatusSeeOther)
+}
+
+// changeEmail — /user/profile/email: новый адрес вступает в силу после перехода по ссылке,
+// отправленной на него; до этого остаётся старый
+func (app *application) changeEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		app.methodNotAllowed(w)
+		return
+	}
+	userID, err := app.getCurrentUser(r)
+	if err != nil {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := changeEmailForm{Email: r.PostFormValue("email"), CurrentPassword: r.PostFormValue("currentPassword")}
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Please enter a valid email address.")
+	form.CheckField(form.Email != user.Email, "email", "This is already your email address.")
+	if form.Valid() && bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(form.CurrentPassword)) != nil {
+		form.AddFieldError("currentPassword", "The current password is incorrect.")
+	}
+	if form.Valid() {
+		if _, err := app.users.GetByEmail(form.Email); err == nil {
+			form.AddFieldError("email", "This email address is already in use.")
+		} else if !errors.Is(err, models2.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+	}
+	if !form.Valid() {
+		for _, msg := range form.FieldErrors {
+			app.flash(w, r, msg)
+			break
+		}
+		http.Redirect(w, r, "/user/profile/", http.StatusSeeOther)
+		return
+	}
+
+	sent, err := app.sendEmailVerification(user, form.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if sent {
+		app.flash(w, r, "We have sent a confirmation link to "+form.Email+". Your address changes once you open it.")
+	} else {
+		app.flash(w, r, "Too many confirmation emails. Please try again later.")
+	}
+	http.Redirect(w, r, "/user/profile/", http.StatusSeeOther)
+}
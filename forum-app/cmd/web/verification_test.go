@@ -0,0 +1,130 @@
+package main
+
+import (
+	models2 "forum-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var verifyLinkRX = regexp.MustCompile(`http://forum\.test/user/verify\?token=(\S+)`)
+
+func TestEmailVerification(t *testing.T) {
+	app := newTestApplication(t)
+
+	send := func(method, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		addCSRFToken(app, req)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+	// lastLink возвращает получателя и токен из последнего письма подтверждения
+	lastLink := func() (string, string) {
+		var to, body string
+		err := app.outbox.DB.QueryRow(`SELECT recipient, text_body FROM email_outbox ORDER BY id DESC LIMIT 1`).Scan(&to, &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := verifyLinkRX.FindStringSubmatch(body)
+		if match == nil {
+			t.Fatalf("Expected a confirmation link, got %q", body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return to, token
+	}
+
+	rr := send("POST", "/user/signup", url.Values{"name": {"dave"}, "email": {"dave@example.com"}, "password": {"ValidPass123!"}}, nil)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Expected signup to redirect, got %d", rr.Code)
+	}
+	to, signupToken := lastLink()
+	if to != "dave@example.com" {
+		t.Errorf("Expected the link to go to dave@example.com, got %s", to)
+	}
+
+	user, err := app.users.GetByEmail("dave@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified {
+		t.Fatal("Expected a new account to be unverified")
+	}
+	loginAs(t, app, "erin", "user")
+	app.sessions.Create(&models2.Session{Token: "session-dave", UserID: user.ID, Expiry: time.Now().Add(time.Hour), LastSeen: time.Now()})
+	cookie := &http.Cookie{Name: "session_id", Value: "session-dave"}
+
+	// Без подтверждения нельзя писать ни через сайт, ни через API
+	rr = send("POST", "/comments/add", url.Values{"post_id": {"1"}, "content": {"hi"}}, cookie)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/profile/" {
+		t.Errorf("Expected unverified comments to redirect to profile, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	req := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(`{"title":"t","content":"c","categories":["1"]}`))
+	req.AddCookie(cookie)
+	addCSRFToken(app, req)
+	api := httptest.NewRecorder()
+	app.routes().ServeHTTP(api, req)
+	if api.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d from the API, got %d", http.StatusForbidden, api.Code)
+	}
+
+	// Регистрация отправила одно письмо, ещё два можно запросить, дальше лимит
+	for i := range 3 {
+		rr = send("POST", "/user/verify/resend", nil, cookie)
+		flash := rr.Result().Cookies()[0].Value
+		if throttled := strings.Contains(flash, "Too many"); throttled != (i == 2) {
+			t.Errorf("Resend %d: unexpected flash %q", i+1, flash)
+		}
+	}
+
+	rr = send("GET", "/user/verify?token="+url.QueryEscape(signupToken), nil, nil)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/user/login" {
+		t.Fatalf("Expected a redirect to login, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if user, _ = app.users.Get(user.ID); !user.EmailVerified {
+		t.Fatal("Expected the link to confirm the address")
+	}
+
+	// Смена адреса: старый действует, пока не подтверждён новый
+	rr = send("POST", "/user/profile/email", url.Values{"email": {"erin@example.com"}, "currentPassword": {"ValidPass123!"}}, cookie)
+	if flash := rr.Result().Cookies()[0].Value; !strings.Contains(flash, "already in use") {
+		t.Errorf("Expected a taken address to be rejected, got %q", flash)
+	}
+	rr = send("POST", "/user/profile/email", url.Values{"email": {"dave@new.example.com"}, "currentPassword": {"wrong"}}, cookie)
+	if flash := rr.Result().Cookies()[0].Value; !strings.Contains(flash, "password is incorrect") {
+		t.Errorf("Expected a wrong password to be rejected, got %q", flash)
+	}
+	if _, err := app.users.DB.Exec(`DELETE FROM email_verification_requests`); err != nil {
+		t.Fatal(err)
+	}
+	send("POST", "/user/profile/email", url.Values{"email": {"dave@new.example.com"}, "currentPassword": {"ValidPass123!"}}, cookie)
+	to, changeToken := lastLink()
+	if to != "dave@new.example.com" {
+		t.Errorf("Expected the link to go to the new address, got %s", to)
+	}
+	if user, _ = app.users.Get(user.ID); user.Email != "dave@example.com" {
+		t.Errorf("Expected the address to stay until confirmed, got %s", user.Email)
+	}
+
+	send("GET", "/user/verify?token="+url.QueryEscape(changeToken), nil, cookie)
+	if user, _ = app.users.Get(user.ID); user.Email != "dave@new.example.com" || !user.EmailVerified {
+		t.Errorf("Expected the new address to be confirmed, got %s (verified %v)", user.Email, user.EmailVerified)
+	}
+
+	// Старые ссылки после смены адреса не работают
+	rr = send("GET", "/user/verify?token="+url.QueryEscape(signupToken), nil, cookie)
+	if flash := rr.Result().Cookies()[0].Value; !strings.Contains(flash, "invalid") {
+		t.Errorf("Expected an outdated link to be rejected, got %q", flash)
+	}
+}
@@ -0,0 +1,353 @@
+// Package config собирает настройки сервера из значений по умолчанию, JSON-файла, переменных
+// окружения и флагов командной строки — в таком порядке, каждый следующий источник важнее.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Secret — значение, которое не должно попасть в логи: fmt печатает его как [redacted]
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+
+// OAuth — приложение у OAuth-провайдера. Провайдер без ClientID выключен.
+type OAuth struct {
+	ClientID     string
+	ClientSecret Secret
+	RedirectURL  string // по умолчанию — BaseURL + путь callback
+}
+
+// Enabled сообщает, настроен ли провайдер
+func (o OAuth) Enabled() bool { return o.ClientID != "" }
+
+// Config — все настройки сервера
+type Config struct {
+	Addr    string
+	BaseURL string // адрес сайта для ссылок в письмах и OAuth callback
+	DSN     string // путь к файлу SQLite
+
+	UploadDir     string // куда сохраняются картинки постов; отдаются по /static/upload/
+	MaxUploadSize int64  // предел формы с картинкой, байт
+	MaxBodySize   int64  // предел JSON-тела запроса к API, байт
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	SessionTTL   time.Duration
+
+	Reactions             string
+	NotificationRetention time.Duration
+	DigestInterval        time.Duration
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword Secret
+	MailFrom     string
+	MailDir      string
+	SigningKey   Secret // ключ подписи ссылок из писем и CSRF-токенов
+
+	Require2FA     string
+	RateLimits     string
+	TrustedProxies string
+	CORSOrigins    string
+
+	Google OAuth
+	GitHub OAuth
+}
+
+// Default возвращает настройки по умолчанию — те, с которыми сервер работает локально без конфигурации
+func Default() *Config {
+	return &Config{
+		Addr:                  ":4000",
+		BaseURL:               "http://localhost:4000",
+		DSN:                   "./data/forum.db",
+		UploadDir:             "./ui/static/upload",
+		MaxUploadSize:         20 << 20,
+		MaxBodySize:           1 << 20,
+		ReadTimeout:           5 * time.Second,
+		WriteTimeout:          10 * time.Second,
+		IdleTimeout:           time.Minute,
+		SessionTTL:            24 * time.Hour,
+		NotificationRetention: 30 * 24 * time.Hour,
+		DigestInterval:        24 * time.Hour,
+		SMTPPort:              587,
+		MailFrom:              "Forum <noreply@localhost>",
+		MailDir:               "./data/mail",
+	}
+}
+
+// setting связывает поле Config с именем в файле, флагом и переменной окружения.
+// Имя флага и ключ в файле совпадают с name, переменная окружения — FORUM_NAME_IN_CAPS.
+type setting struct {
+	name   string
+	usage  string
+	secret bool     // без флага: секреты в командной строке видны в ps
+	env    []string // прежние имена переменных окружения, которые тоже принимаются
+	field  func(c *Config) any
+}
+
+var settings = []setting{
+	{name: "addr", usage: "HTTP listen address", field: func(c *Config) any { return &c.Addr }},
+	{name: "base-url", usage: "public URL of the site, used for links in emails and OAuth callbacks", field: func(c *Config) any { return &c.BaseURL }},
+	{name: "dsn", usage: "path to the SQLite database", field: func(c *Config) any { return &c.DSN }},
+	{name: "upload-dir", usage: "directory for uploaded post images", field: func(c *Config) any { return &c.UploadDir }},
+	{name: "max-upload-size", usage: "maximum size of a post form with an image, in bytes", field: func(c *Config) any { return &c.MaxUploadSize }},
+	{name: "max-body-size", usage: "maximum size of an API request body, in bytes", field: func(c *Config) any { return &c.MaxBodySize }},
+	{name: "read-timeout", usage: "HTTP server read timeout", field: func(c *Config) any { return &c.ReadTimeout }},
+	{name: "write-timeout", usage: "HTTP server write timeout", field: func(c *Config) any { return &c.WriteTimeout }},
+	{name: "idle-timeout", usage: "HTTP server keep-alive timeout", field: func(c *Config) any { return &c.IdleTimeout }},
+	{name: "session-ttl", usage: "how long a login session lasts", field: func(c *Config) any { return &c.SessionTTL }},
+	{name: "reactions", usage: `reaction kinds as name:emoji pairs, e.g. "like:👍,dislike:👎,love:❤️" (empty: built-in set)`, field: func(c *Config) any { return &c.Reactions }},
+	{name: "notification-retention", usage: "delete read notifications older than this (0 keeps them forever)", field: func(c *Config) any { return &c.NotificationRetention }},
+	{name: "digest-interval", usage: "how often email digests of notifications are sent", field: func(c *Config) any { return &c.DigestInterval }},
+	{name: "smtp-host", usage: "SMTP server; empty writes emails to mail-dir instead", field: func(c *Config) any { return &c.SMTPHost }},
+	{name: "smtp-port", usage: "SMTP server port", field: func(c *Config) any { return &c.SMTPPort }},
+	{name: "smtp-user", usage: "SMTP username (empty: no authentication)", field: func(c *Config) any { return &c.SMTPUser }},
+	{name: "smtp-password", secret: true, env: []string{"SMTP_PASSWORD"}, field: func(c *Config) any { return &c.SMTPPassword }},
+	{name: "mail-from", usage: "sender address of outgoing emails", field: func(c *Config) any { return &c.MailFrom }},
+	{name: "mail-dir", usage: "directory for .eml files when smtp-host is empty", field: func(c *Config) any { return &c.MailDir }},
+	{name: "signing-key", secret: true, env: []string{"SIGNING_KEY"}, field: func(c *Config) any { return &c.SigningKey }},
+	{name: "require-2fa", usage: `roles that must use two-factor authentication: "admin", "moderator" or "admin,moderator"`, field: func(c *Config) any { return &c.Require2FA }},
+	{name: "rate-limits", usage: `override rate limit policies, e.g. "auth=10/m:5,static=off"; policies: auth, write, stream, static, default`, field: func(c *Config) any { return &c.RateLimits }},
+	{name: "trusted-proxies", usage: "comma-separated IPs or CIDRs of reverse proxies whose X-Forwarded-For is trusted", field: func(c *Config) any { return &c.TrustedProxies }},
+	{name: "cors-origins", usage: "comma-separated origins allowed to call the site cross-origin, e.g. https://app.example.com", field: func(c *Config) any { return &c.CORSOrigins }},
+	{name: "google-client-id", usage: "Google OAuth client ID (empty: Google login is off)", field: func(c *Config) any { return &c.Google.ClientID }},
+	{name: "google-client-secret", secret: true, field: func(c *Config) any { return &c.Google.ClientSecret }},
+	{name: "google-redirect-url", usage: "Google OAuth callback (default: base-url + /user/googlecallback)", field: func(c *Config) any { return &c.Google.RedirectURL }},
+	{name: "github-client-id", usage: "GitHub OAuth client ID (empty: GitHub login is off)", field: func(c *Config) any { return &c.GitHub.ClientID }},
+	{name: "github-client-secret", secret: true, field: func(c *Config) any { return &c.GitHub.ClientSecret }},
+	{name: "github-redirect-url", usage: "GitHub OAuth callback (default: base-url + /user/githubcallback)", field: func(c *Config) any { return &c.GitHub.RedirectURL }},
+}
+
+// envName — переменная окружения для настройки: dsn → FORUM_DSN, base-url → FORUM_BASE_URL
+func envName(name string) string {
+	return "FORUM_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Load регистрирует флаги настроек и -config в fs, разбирает args и собирает Config:
+// значения по умолчанию, затем файл из -config (или $FORUM_CONFIG), окружение и флаги.
+// Флаги самой программы можно зарегистрировать в fs заранее — они разберутся вместе с остальными.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	path := fs.String("config", "", "JSON file with settings; keys are flag names (default $FORUM_CONFIG)")
+
+	flagged := map[string]string{}
+	for _, s := range settings {
+		if s.secret {
+			continue
+		}
+		usage := s.usage + " ($" + envName(s.name) + ")"
+		if def := format(s.field(cfg)); def != "" {
+			usage += fmt.Sprintf(" (default %q)", def)
+		}
+		fs.Func(s.name, usage, func(v string) error {
+			// Проверяем сразу, чтобы ошибка указала на флаг
+			if err := set(s.field(Default()), v); err != nil {
+				return err
+			}
+			flagged[s.name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path == "" {
+		*path = getenv("FORUM_CONFIG")
+	}
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		for _, name := range append([]string{envName(s.name)}, s.env...) {
+			v := getenv(name)
+			if v == "" {
+				continue
+			}
+			if err := set(s.field(cfg), v); err != nil {
+				return nil, fmt.Errorf("config: $%s: %w", name, err)
+			}
+			break
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := flagged[s.name]; ok {
+			if err := set(s.field(cfg), v); err != nil {
+				return nil, fmt.Errorf("config: -%s: %w", s.name, err)
+			}
+		}
+	}
+
+	cfg.fillDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile читает JSON-объект вида {"dsn": "/var/lib/forum.db", "smtp-port": 465}.
+// Неизвестный ключ — ошибка: опечатка в имени иначе тихо оставила бы значение по умолчанию.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	for key, raw := range values {
+		s, ok := lookup(key)
+		if !ok {
+			return fmt.Errorf("config: %s: unknown setting %q", path, key)
+		}
+		// Строки приходят в кавычках, числа — как есть
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			v = string(raw)
+		}
+		if err := set(s.field(c), v); err != nil {
+			return fmt.Errorf("config: %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+func lookup(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// fillDefaults заполняет значения, зависящие от других настроек
+func (c *Config) fillDefaults() {
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	if c.Google.RedirectURL == "" {
+		c.Google.RedirectURL = c.BaseURL + "/user/googlecallback"
+	}
+	if c.GitHub.RedirectURL == "" {
+		c.GitHub.RedirectURL = c.BaseURL + "/user/githubcallback"
+	}
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки разом
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, name, problem string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("config: %s %s", name, problem))
+		}
+	}
+
+	check(c.Addr != "", "addr", "must not be empty")
+	u, err := url.Parse(c.BaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "base-url", "must be an http(s) URL")
+	check(c.DSN != "", "dsn", "must not be empty")
+	check(c.UploadDir != "", "upload-dir", "must not be empty")
+	check(c.MaxUploadSize > 0, "max-upload-size", "must be positive")
+	check(c.MaxBodySize > 0, "max-body-size", "must be positive")
+	check(c.ReadTimeout > 0, "read-timeout", "must be positive")
+	check(c.WriteTimeout > 0, "write-timeout", "must be positive")
+	check(c.IdleTimeout > 0, "idle-timeout", "must be positive")
+	check(c.SessionTTL >= time.Minute, "session-ttl", "must be at least 1m")
+	check(c.NotificationRetention >= 0, "notification-retention", "must not be negative")
+	check(c.DigestInterval > 0, "digest-interval", "must be positive")
+	check(c.SMTPPort > 0 && c.SMTPPort < 65536, "smtp-port", "must be between 1 and 65535")
+	check(c.MailFrom != "", "mail-from", "must not be empty")
+	check(c.SMTPHost != "" || c.MailDir != "", "mail-dir", "must be set when smtp-host is empty")
+	check(c.SigningKey == "" || len(c.SigningKey) >= 32, "signing-key", "must be at least 32 characters")
+	check(c.Google.Enabled() == (c.Google.ClientSecret != ""), "google-client-secret", "and google-client-id must be set together")
+	check(c.GitHub.Enabled() == (c.GitHub.ClientSecret != ""), "github-client-secret", "and github-client-id must be set together")
+	return errors.Join(errs...)
+}
+
+// String перечисляет все настройки для лога запуска; секреты скрыты
+func (c *Config) String() string {
+	parts := make([]string, 0, len(settings))
+	for _, s := range settings {
+		parts = append(parts, s.name+"="+strconv.Quote(fmt.Sprint(deref(s.field(c)))))
+	}
+	return strings.Join(parts, " ")
+}
+
+// set разбирает v в поле по указателю p
+func set(p any, v string) error {
+	switch p := p.(type) {
+	case *string:
+		*p = v
+	case *Secret:
+		*p = Secret(v)
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*p = n
+	case *int64:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*p = n
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 30s or 24h", v)
+		}
+		*p = d
+	default:
+		panic(fmt.Sprintf("config: unsupported field type %T", p))
+	}
+	return nil
+}
+
+// format — значение поля для справки по флагам
+func format(p any) string {
+	s := fmt.Sprint(deref(p))
+	if s == "0" || s == "0s" {
+		return ""
+	}
+	return s
+}
+
+func deref(p any) any {
+	switch p := p.(type) {
+	case *string:
+		return *p
+	case *Secret:
+		return *p
+	case *int:
+		return *p
+	case *int64:
+		return *p
+	case *time.Duration:
+		return *p
+	}
+	return p
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, file string, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "forum.json")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	fs := flag.NewFlagSet("forum", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, func(name string) string { return env[name] })
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := `{"dsn": "/from/file.db", "addr": ":5000", "smtp-port": 465, "session-ttl": "2h", "smtp-password": "file-secret"}`
+	env := map[string]string{"FORUM_ADDR": ":6000", "FORUM_SESSION_TTL": "3h", "SIGNING_KEY": strings.Repeat("k", 32)}
+
+	cfg, err := load(t, file, env, "-session-ttl", "4h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want any
+	}{
+		{"file over default", cfg.DSN, "/from/file.db"},
+		{"int from file", cfg.SMTPPort, 465},
+		{"env over file", cfg.Addr, ":6000"},
+		{"flag over env", cfg.SessionTTL, 4 * time.Hour},
+		{"legacy env name", string(cfg.SigningKey), strings.Repeat("k", 32)},
+		{"secret from file", string(cfg.SMTPPassword), "file-secret"},
+		{"untouched default", cfg.MaxUploadSize, int64(20 << 20)},
+		{"derived default", cfg.Google.RedirectURL, "http://localhost:4000/user/googlecallback"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown file key", `{"dbpath": "x"}`, nil, nil, `unknown setting "dbpath"`},
+		{"bad file value", `{"smtp-port": "many"}`, nil, nil, "smtp-port"},
+		{"bad env value", "", map[string]string{"FORUM_READ_TIMEOUT": "soon"}, nil, "FORUM_READ_TIMEOUT"},
+		{"secrets have no flag", "", nil, []string{"-smtp-password", "x"}, "not defined"},
+		{"validation", "", map[string]string{"FORUM_BASE_URL": "forum.example.com", "FORUM_SMTP_PORT": "0"}, nil, "base-url"},
+		{"oauth pair", `{"github-client-id": "id"}`, nil, nil, "github-client-secret"},
+	} {
+		_, err := load(t, tt.file, tt.env, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// Validate сообщает обо всех ошибках сразу
+	_, err := load(t, "", map[string]string{"FORUM_BASE_URL": "forum.example.com", "FORUM_SMTP_PORT": "0"})
+	if err == nil || !strings.Contains(err.Error(), "smtp-port") {
+		t.Errorf("Expected both errors to be reported, got %v", err)
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	cfg, err := load(t, "", map[string]string{
+		"FORUM_SMTP_PASSWORD":        "hunter2",
+		"FORUM_GOOGLE_CLIENT_ID":     "client-id",
+		"FORUM_GOOGLE_CLIENT_SECRET": "google-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{cfg.String(), fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", *cfg), fmt.Sprintf("%#v", cfg.Google)} {
+		if strings.Contains(out, "hunter2") || strings.Contains(out, "google-secret") {
+			t.Errorf("Expected secrets to be redacted, got %s", out)
+		}
+	}
+	if !strings.Contains(cfg.String(), `smtp-password="[redacted]"`) || !strings.Contains(cfg.String(), `google-client-id="client-id"`) {
+		t.Errorf("Expected settings in the log line, got %s", cfg)
+	}
+}
@@ -0,0 +1,157 @@
+// Package diff сравнивает две версии текста по словам или по строкам (LCS).
+package diff
+
+import "strings"
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// Op — кусок текста, который совпадает, добавлен или удалён
+type Op struct {
+	Kind Kind
+	Text string
+}
+
+func (o Op) Equal() bool  { return o.Kind == Equal }
+func (o Op) Insert() bool { return o.Kind == Insert }
+func (o Op) Delete() bool { return o.Kind == Delete }
+
+// Words сравнивает тексты по словам; пробелы — отдельные куски,
+// поэтому склеенные Text всех Equal и Insert дают новую версию
+func Words(a, b string) []Op {
+	return merge(compare(splitWords(a), splitWords(b)))
+}
+
+// Lines сравнивает тексты построчно; каждая Op — одна строка без перевода строки
+func Lines(a, b string) []Op {
+	return compare(splitLines(a), splitLines(b))
+}
+
+// Row — строка двухколоночного сравнения; nil означает пустую ячейку
+type Row struct {
+	Left  *Op
+	Right *Op
+}
+
+// SideBySide раскладывает построчное сравнение на две колонки:
+// слева старая версия, справа новая, удалённые и добавленные строки стоят напротив друг друга
+func SideBySide(a, b string) []Row {
+	var rows []Row
+	var deleted, inserted []Op
+
+	flush := func() {
+		for i := 0; i < len(deleted) || i < len(inserted); i++ {
+			var row Row
+			if i < len(deleted) {
+				row.Left = &deleted[i]
+			}
+			if i < len(inserted) {
+				row.Right = &inserted[i]
+			}
+			rows = append(rows, row)
+		}
+		deleted, inserted = nil, nil
+	}
+
+	for _, op := range Lines(a, b) {
+		switch op.Kind {
+		case Delete:
+			deleted = append(deleted, op)
+		case Insert:
+			inserted = append(inserted, op)
+		default:
+			flush()
+			left, right := op, op
+			rows = append(rows, Row{Left: &left, Right: &right})
+		}
+	}
+	flush()
+	return rows
+}
+
+func compare(a, b []string) []Op {
+	// lcs[i][j] — длина наибольшей общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []Op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Op{Equal, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, Op{Delete, a[i]})
+			i++
+		default:
+			ops = append(ops, Op{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, Op{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, Op{Insert, b[j]})
+	}
+	return ops
+}
+
+// merge склеивает соседние куски одного вида, чтобы в шаблоне было меньше тегов
+func merge(ops []Op) []Op {
+	var out []Op
+	for _, op := range ops {
+		if n := len(out); n > 0 && out[n-1].Kind == op.Kind {
+			out[n-1].Text += op.Text
+			continue
+		}
+		out = append(out, op)
+	}
+	return out
+}
+
+// splitWords режет текст на чередующиеся куски из слов и пробелов
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i, r := range s {
+		if i > start && isSpace(r) != isSpace(rune(s[start])) {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
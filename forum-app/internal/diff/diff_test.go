@@ -0,0 +1,78 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	got := Words("the quick fox jumps", "the slow fox jumps high")
+	want := []Op{
+		{Equal, "the "},
+		{Delete, "quick"},
+		{Insert, "slow"},
+		{Equal, " fox jumps"},
+		{Insert, " high"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Words() = %#v, want %#v", got, want)
+	}
+
+	var rebuilt string
+	for _, op := range got {
+		if !op.Delete() {
+			rebuilt += op.Text
+		}
+	}
+	if rebuilt != "the slow fox jumps high" {
+		t.Errorf("Expected Equal+Insert to rebuild the new text, got %q", rebuilt)
+	}
+}
+
+func TestLines(t *testing.T) {
+	got := Lines("a\nb\nc\n", "a\nc\nd")
+	want := []Op{
+		{Equal, "a"},
+		{Delete, "b"},
+		{Equal, "c"},
+		{Insert, "d"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %#v, want %#v", got, want)
+	}
+
+	if ops := Lines("", ""); len(ops) != 0 {
+		t.Errorf("Expected no ops for empty texts, got %#v", ops)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	rows := SideBySide("a\nold 1\nold 2\nz", "a\nnew 1\nz\nextra")
+
+	type cells struct{ left, right string }
+	var got []cells
+	for _, row := range rows {
+		var c cells
+		if row.Left != nil {
+			c.left = row.Left.Text
+		}
+		if row.Right != nil {
+			c.right = row.Right.Text
+		}
+		got = append(got, c)
+	}
+
+	want := []cells{
+		{"a", "a"},
+		{"old 1", "new 1"},
+		{"old 2", ""},
+		{"z", "z"},
+		{"", "extra"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SideBySide() = %v, want %v", got, want)
+	}
+	if !rows[1].Left.Delete() || !rows[1].Right.Insert() {
+		t.Error("Expected changed lines to be marked as deleted and inserted")
+	}
+}
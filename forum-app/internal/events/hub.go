@@ -0,0 +1,91 @@
+// Package events — внутрипроцессная шина: обработчики публикуют события для пользователя,
+// открытые SSE-соединения этого пользователя их получают.
+package events
+
+import "sync"
+
+// DefaultBuffer — сколько событий подписчик может не успеть прочитать, прежде чем его отключат
+const DefaultBuffer = 16
+
+// Event — одно событие; ID (если не 0) клиент вернёт в Last-Event-ID при переподключении
+type Event struct {
+	ID   int64
+	Name string
+	Data any
+}
+
+// Hub рассылает события подписчикам одного пользователя. Нулевое значение не готово к работе — используйте NewHub.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[int]map[*Subscription]struct{}
+	buffer int
+}
+
+// Subscription — одно соединение пользователя. C закрывается после Close или если подписчик
+// отстал больше чем на buffer событий: пропущенное клиент догонит по Last-Event-ID.
+type Subscription struct {
+	UserID int
+	C      <-chan Event
+
+	c      chan Event
+	hub    *Hub
+	closed bool // под hub.mu
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[int]map[*Subscription]struct{}{}, buffer: DefaultBuffer}
+}
+
+// Subscribe подписывает новое соединение пользователя
+func (h *Hub) Subscribe(userID int) *Subscription {
+	c := make(chan Event, h.buffer)
+	sub := &Subscription{UserID: userID, C: c, c: c, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[*Subscription]struct{}{}
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+// Close отписывает соединение; повторный вызов ничего не делает
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// remove вызывается под h.mu
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.c)
+	delete(h.subs[s.UserID], s)
+	if len(h.subs[s.UserID]) == 0 {
+		delete(h.subs, s.UserID)
+	}
+}
+
+// Publish отправляет событие всем соединениям пользователя и никогда не блокируется
+func (h *Hub) Publish(userID int, e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[userID] {
+		select {
+		case sub.c <- e:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribers возвращает число открытых соединений пользователя
+func (h *Hub) Subscribers(userID int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[userID])
+}
@@ -0,0 +1,53 @@
+package events
+
+import "testing"
+
+func TestHubDeliversPerUser(t *testing.T) {
+	h := NewHub()
+	alice := h.Subscribe(1)
+	alice2 := h.Subscribe(1)
+	bob := h.Subscribe(2)
+
+	h.Publish(1, Event{ID: 7, Name: "notification"})
+
+	for _, sub := range []*Subscription{alice, alice2} {
+		if e := <-sub.C; e.ID != 7 {
+			t.Errorf("Expected event 7, got %+v", e)
+		}
+	}
+	select {
+	case e := <-bob.C:
+		t.Errorf("Bob received someone else's event %+v", e)
+	default:
+	}
+
+	alice.Close()
+	alice.Close()
+	if _, ok := <-alice.C; ok {
+		t.Error("Expected a closed channel after Close")
+	}
+	if n := h.Subscribers(1); n != 1 {
+		t.Errorf("Expected 1 subscriber left, got %d", n)
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	h := NewHub()
+	sub := h.Subscribe(1)
+
+	for i := 0; i <= DefaultBuffer; i++ {
+		h.Publish(1, Event{ID: int64(i + 1)})
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != DefaultBuffer {
+		t.Errorf("Expected %d buffered events before the drop, got %d", DefaultBuffer, n)
+	}
+	if h.Subscribers(1) != 0 {
+		t.Error("Expected the slow subscriber to be removed")
+	}
+	sub.Close()
+}
@@ -0,0 +1,159 @@
+// Package mailer отправляет письма: по SMTP в продакшене и в файлы/лог при разработке.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message — письмо с текстовой и HTML-версией; HTML может быть пустым
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer доставляет письмо или возвращает ошибку — тогда outbox повторит попытку позже
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// SMTP отправляет письма через SMTP-сервер. Если сервер объявляет STARTTLS, соединение шифруется;
+// AUTH PLAIN без TLS net/smtp разрешает только для localhost.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string // пусто — без авторизации
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	return smtp.SendMail(addr, auth, addressOnly(s.From), []string{msg.To}, Build(s.From, msg, time.Now()))
+}
+
+// FileMailer пишет каждое письмо в Dir как .eml и коротко сообщает о нём в Logger —
+// для разработки, когда SMTP не настроен. Пустой Dir — только лог.
+type FileMailer struct {
+	Dir    string
+	From   string
+	Logger *log.Logger
+}
+
+func (f *FileMailer) Send(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	var path string
+	if f.Dir != "" {
+		if err := os.MkdirAll(f.Dir, 0o750); err != nil {
+			return err
+		}
+		path = filepath.Join(f.Dir, time.Now().Format("20060102-150405")+"-"+randomID()+".eml")
+		if err := os.WriteFile(path, Build(f.From, msg, time.Now()), 0o640); err != nil {
+			return err
+		}
+	}
+	if f.Logger != nil {
+		if path != "" {
+			f.Logger.Printf("Mail to %s: %q saved to %s", msg.To, msg.Subject, path)
+		} else {
+			f.Logger.Printf("Mail to %s: %q\n%s", msg.To, msg.Subject, msg.Text)
+		}
+	}
+	return nil
+}
+
+// validate не пускает переводы строк в заголовки
+func (msg *Message) validate() error {
+	if msg.To == "" || strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("mailer: invalid recipient or subject %q", msg.To)
+	}
+	return nil
+}
+
+// Build собирает письмо в формате RFC 5322: text/plain или multipart/alternative с HTML
+func Build(from string, msg *Message, date time.Time) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+randomID()+"@"+domain(from)+">")
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		writeQP(&b, msg.Text)
+		return b.Bytes()
+	}
+
+	boundary := "b-" + randomID()
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		header("Content-Type", part.contentType+`; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		writeQP(&b, part.body)
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+func writeQP(b *bytes.Buffer, s string) {
+	w := quotedprintable.NewWriter(b)
+	w.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")))
+	w.Close()
+}
+
+// addressOnly достаёт адрес из "Forum <noreply@example.com>" для MAIL FROM
+func addressOnly(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
+
+func domain(from string) string {
+	if _, d, ok := strings.Cut(addressOnly(from), "@"); ok && d != "" {
+		return d
+	}
+	return "localhost"
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
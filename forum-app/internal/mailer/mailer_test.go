@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"forum-app/internal/mailer/smtptest"
+)
+
+func TestSMTPSend(t *testing.T) {
+	srv := smtptest.NewServer(t)
+	m := &SMTP{Host: srv.Host, Port: srv.Port, Username: "forum", Password: "secret", From: "Forum <noreply@forum.test>"}
+
+	msg := &Message{To: "alice@example.com", Subject: "Привет", Text: "plain body", HTML: "<p>html body</p>"}
+	if err := m.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	got := srv.Messages()
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(got))
+	}
+	if got[0].From != "noreply@forum.test" || len(got[0].To) != 1 || got[0].To[0] != "alice@example.com" {
+		t.Errorf("Unexpected envelope %q -> %v", got[0].From, got[0].To)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Привет" {
+		t.Errorf("Expected the decoded subject, got %q (%v)", subject, err)
+	}
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		p, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(p)
+		bodies = append(bodies, p.Header.Get("Content-Type")+": "+strings.TrimSpace(string(b)))
+	}
+	if len(bodies) != 2 || !strings.Contains(bodies[0], "text/plain") || !strings.HasSuffix(bodies[1], "<p>html body</p>") {
+		t.Errorf("Unexpected parts %q", bodies)
+	}
+
+	srv.Fail("451 try again later")
+	if err := m.Send(msg); err == nil {
+		t.Error("Expected an error when the server rejects the message")
+	}
+	if err := m.Send(&Message{To: "bob@example.com\r\nBcc: eve@example.com", Subject: "x"}); err == nil {
+		t.Error("Expected header injection to be rejected")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "noreply@forum.test"}
+	if err := m.Send(&Message{To: "alice@example.com", Subject: "Hi", Text: "line one\nline two"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected 1 .eml file, got %v (%v)", files, err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date, err := parsed.Header.Date(); err != nil || time.Since(date) > time.Minute {
+		t.Errorf("Unexpected Date header %v (%v)", date, err)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	if !strings.Contains(string(body), "line one\r\nline two") {
+		t.Errorf("Unexpected body %q", body)
+	}
+}
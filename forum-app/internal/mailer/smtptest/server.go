@@ -0,0 +1,145 @@
+// Package smtptest — SMTP-сервер в памяти для тестов: принимает письма и отдаёт их списком.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Mail — принятое письмо
+type Mail struct {
+	From string
+	To   []string
+	Data string // письмо целиком, с заголовками
+}
+
+// Server понимает ровно столько SMTP, сколько нужно net/smtp: EHLO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, QUIT
+type Server struct {
+	Host string
+	Port int
+
+	mu   sync.Mutex
+	fail string // ответ на DATA вместо 250, см. Fail
+	mail []Mail
+
+	ln net.Listener
+	wg sync.WaitGroup
+}
+
+// NewServer запускает сервер на свободном порту 127.0.0.1 и останавливает его в конце теста
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	s := &Server{Host: "127.0.0.1", Port: addr.Port, ln: ln}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+// Addr возвращает host:port
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Fail заставляет сервер отвечать reply на DATA; пустая строка снова принимает письма
+func (s *Server) Fail(reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = reply
+}
+
+// Messages возвращает копию принятых писем
+func (s *Server) Messages() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mail...)
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 smtptest ready")
+	var cur Mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-smtptest")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 authenticated")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			cur = Mail{From: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			cur.To = append(cur.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			cur.Data = data.String()
+
+			s.mu.Lock()
+			fail := s.fail
+			if fail == "" {
+				s.mail = append(s.mail, cur)
+			}
+			s.mu.Unlock()
+			if fail != "" {
+				reply(fail)
+			} else {
+				reply("250 queued")
+			}
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var ErrChecksumMismatch = errors.New("migrations: applied migration has been modified")
+var ErrMissingDown = errors.New("migrations: no down script for migration")
+
+// Migration одна версия схемы, собранная из пары файлов NNNN_name.up.sql / NNNN_name.down.sql
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status состояние миграции для флага -migrate status
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator применяет и откатывает миграции из Source (по умолчанию встроенные sql/*.sql)
+type Migrator struct {
+	DB     *sql.DB
+	Source fs.FS
+}
+
+func (m *Migrator) source() (fs.FS, error) {
+	if m.Source != nil {
+		return m.Source, nil
+	}
+	return fs.Sub(embedded, "sql")
+}
+
+// Load читает все миграции и сортирует их по версии
+func (m *Migrator) Load() ([]*Migration, error) {
+	src, err := m.source()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(src, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		base := path.Base(file)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migrations: unexpected file name %q", base)
+		}
+
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(base, "."+direction+".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: file %q must be named NNNN_name.%s.sql", base, direction)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: invalid version in %q: %w", base, err)
+		}
+
+		content, err := fs.ReadFile(src, file)
+		if err != nil {
+			return nil, err
+		}
+
+		mg, exists := byVersion[version]
+		if !exists {
+			mg = &Migration{Version: version, Name: name}
+			byVersion[version] = mg
+		} else if mg.Name != name {
+			return nil, fmt.Errorf("migrations: version %d has conflicting names %q and %q", version, mg.Name, name)
+		}
+
+		if direction == "up" {
+			mg.Up = string(content)
+			sum := sha256.Sum256(content)
+			mg.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mg.Down = string(content)
+		}
+	}
+
+	var list []*Migration
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migrations: no up script for version %d", mg.Version)
+		}
+		list = append(list, mg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT     NOT NULL,
+		checksum   TEXT     NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) applied() (map[int]appliedMigration, error) {
+	rows, err := m.DB.Query(`SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// Up применяет все ещё не применённые миграции и проверяет контрольные суммы уже применённых
+func (m *Migrator) Up() (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+	list, err := m.Load()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mg := range list {
+		if a, ok := applied[mg.Version]; ok {
+			if a.checksum != mg.Checksum {
+				return count, fmt.Errorf("%w: %04d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+			}
+			continue
+		}
+
+		err = m.exec(mg.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+				mg.Version, mg.Name, mg.Checksum)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migrations: applying %04d_%s: %w", mg.Version, mg.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down откатывает последние steps применённых миграций
+func (m *Migrator) Down(steps int) (int, error) {
+	if err := m.ensureTable(); err != nil {
+		return 0, err
+	}
+	list, err := m.Load()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(list) - 1; i >= 0 && count < steps; i-- {
+		mg := list[i]
+		a, ok := applied[mg.Version]
+		if !ok {
+			continue
+		}
+		if a.checksum != mg.Checksum {
+			return count, fmt.Errorf("%w: %04d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+		}
+		if mg.Down == "" {
+			return count, fmt.Errorf("%w: %04d_%s", ErrMissingDown, mg.Version, mg.Name)
+		}
+
+		err = m.exec(mg.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, mg.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migrations: rolling back %04d_%s: %w", mg.Version, mg.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Status возвращает список всех известных миграций с отметкой о применении
+func (m *Migrator) Status() ([]*Status, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	list, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*Status
+	for _, mg := range list {
+		s := &Status{Version: mg.Version, Name: mg.Name}
+		if a, ok := applied[mg.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// exec выполняет скрипт миграции и запись в schema_migrations в одной транзакции
+func (m *Migrator) exec(script string, record func(tx *sql.Tx) error) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,121 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, name).Scan(&exists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+func TestEmbeddedUpAndDown(t *testing.T) {
+	db := newTestDB(t)
+	m := &Migrator{DB: db}
+
+	n, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Fatal("expected at least one migration to be applied")
+	}
+	for _, table := range []string{"users", "posts", "comments", "reactions", "notifications", "reports"} {
+		if !tableExists(t, db, table) {
+			t.Errorf("table %s was not created", table)
+		}
+	}
+
+	n, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("second Up applied %d migrations, want 0", n)
+	}
+
+	list, err := m.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = m.Down(len(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(list) {
+		t.Errorf("Down rolled back %d migrations, want %d", n, len(list))
+	}
+	if tableExists(t, db, "users") {
+		t.Error("table users still exists after rolling back everything")
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	db := newTestDB(t)
+	src := fstest.MapFS{
+		"0001_init.up.sql":   {Data: []byte(`CREATE TABLE t (id INTEGER);`)},
+		"0001_init.down.sql": {Data: []byte(`DROP TABLE t;`)},
+	}
+
+	if _, err := (&Migrator{DB: db, Source: src}).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	src["0001_init.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE t (id INTEGER, name TEXT);`)}
+	_, err := (&Migrator{DB: db, Source: src}).Up()
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	db := newTestDB(t)
+	src := fstest.MapFS{
+		"0001_first.up.sql":  {Data: []byte(`CREATE TABLE a (id INTEGER);`)},
+		"0002_second.up.sql": {Data: []byte(`CREATE TABLE b (id INTEGER);`)},
+	}
+	m := &Migrator{DB: db, Source: src}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	src["0003_third.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE c (id INTEGER);`)}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("got %d statuses, want 3", len(statuses))
+	}
+	if !statuses[0].Applied || !statuses[1].Applied || statuses[2].Applied {
+		t.Errorf("unexpected applied flags: %v %v %v", statuses[0].Applied, statuses[1].Applied, statuses[2].Applied)
+	}
+
+	if _, err := m.Down(1); !errors.Is(err, ErrMissingDown) {
+		t.Errorf("expected ErrMissingDown, got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS comment_dislikes;
+DROP TABLE IF EXISTS comment_likes;
+DROP TABLE IF EXISTS post_dislikes;
+DROP TABLE IF EXISTS post_likes;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
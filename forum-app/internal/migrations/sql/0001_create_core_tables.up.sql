@@ -0,0 +1,101 @@
+CREATE TABLE users (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    name            TEXT     NOT NULL,
+    email           TEXT     NOT NULL UNIQUE,
+    hashed_password TEXT     NOT NULL,
+    provider        TEXT     NOT NULL DEFAULT '',
+    provider_id     TEXT     NOT NULL DEFAULT '',
+    created         DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    role            TEXT     NOT NULL DEFAULT 'user'
+);
+
+CREATE TABLE categories (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE posts (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    title      TEXT     NOT NULL,
+    content    TEXT     NOT NULL,
+    image_path TEXT     NOT NULL DEFAULT '',
+    category   TEXT     NOT NULL DEFAULT '',
+    likes      INTEGER  NOT NULL DEFAULT 0,
+    dislikes   INTEGER  NOT NULL DEFAULT 0,
+    author     TEXT     NOT NULL,
+    author_id  INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status     TEXT     NOT NULL DEFAULT 'pending'
+);
+
+CREATE INDEX idx_posts_status_created ON posts (status, created);
+CREATE INDEX idx_posts_author_id ON posts (author_id);
+
+CREATE TABLE comments (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id  INTEGER  NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    content  TEXT     NOT NULL,
+    likes    INTEGER  NOT NULL DEFAULT 0,
+    dislikes INTEGER  NOT NULL DEFAULT 0,
+    user_id  INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    author   TEXT     NOT NULL,
+    created  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_comments_post_id ON comments (post_id);
+CREATE INDEX idx_comments_user_id ON comments (user_id);
+
+CREATE TABLE post_likes (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE post_dislikes (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE comment_likes (
+    comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE TABLE comment_dislikes (
+    comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE TABLE notifications (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       TEXT     NOT NULL,
+    post_id    INTEGER  NOT NULL DEFAULT 0,
+    comment_id INTEGER  NOT NULL DEFAULT 0,
+    actor_id   INTEGER  NOT NULL,
+    created    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    is_read    INTEGER  NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, is_read);
+
+CREATE TABLE reports (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id     INTEGER  NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    reporter_id INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason      TEXT     NOT NULL,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    answer      TEXT     NOT NULL DEFAULT '',
+    admin_id    INTEGER  NOT NULL DEFAULT 0,
+    solved      INTEGER  NOT NULL DEFAULT 0
+);
+
+INSERT INTO categories (name)
+VALUES ('News'),
+       ('Technology'),
+       ('Funny'),
+       ('Sport'),
+       ('Other');
@@ -0,0 +1,12 @@
+CREATE TABLE sessions (
+    token      TEXT PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expiry     DATETIME NOT NULL,
+    created    DATETIME NOT NULL,
+    last_seen  DATETIME NOT NULL,
+    user_agent TEXT     NOT NULL DEFAULT '',
+    ip         TEXT     NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_expiry ON sessions (expiry);
@@ -0,0 +1,19 @@
+CREATE TABLE sessions_old (
+    token      TEXT PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expiry     DATETIME NOT NULL,
+    created    DATETIME NOT NULL,
+    last_seen  DATETIME NOT NULL,
+    user_agent TEXT     NOT NULL DEFAULT '',
+    ip         TEXT     NOT NULL DEFAULT ''
+);
+
+INSERT INTO sessions_old (token, user_id, expiry, created, last_seen, user_agent, ip)
+SELECT token, user_id, expiry, created, last_seen, user_agent, ip
+FROM sessions;
+
+DROP TABLE sessions;
+ALTER TABLE sessions_old RENAME TO sessions;
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_expiry ON sessions (expiry);
@@ -0,0 +1,20 @@
+CREATE TABLE sessions_new (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    token      TEXT     NOT NULL UNIQUE,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expiry     DATETIME NOT NULL,
+    created    DATETIME NOT NULL,
+    last_seen  DATETIME NOT NULL,
+    user_agent TEXT     NOT NULL DEFAULT '',
+    ip         TEXT     NOT NULL DEFAULT ''
+);
+
+INSERT INTO sessions_new (token, user_id, expiry, created, last_seen, user_agent, ip)
+SELECT token, user_id, expiry, created, last_seen, user_agent, ip
+FROM sessions;
+
+DROP TABLE sessions;
+ALTER TABLE sessions_new RENAME TO sessions;
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_expiry ON sessions (expiry);
@@ -0,0 +1,12 @@
+CREATE TABLE api_tokens (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT     NOT NULL,
+    token_hash TEXT     NOT NULL UNIQUE,
+    scope      TEXT     NOT NULL,
+    created    DATETIME NOT NULL,
+    last_used  DATETIME,
+    expiry     DATETIME
+);
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
@@ -0,0 +1,13 @@
+ALTER TABLE comments ADD COLUMN edited DATETIME;
+
+-- Предыдущие версии комментария: content — текст до правки, editor_id — кто её сделал
+CREATE TABLE comment_revisions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER  NOT NULL,
+    content    TEXT     NOT NULL,
+    editor_id  INTEGER  NOT NULL,
+    created    DATETIME NOT NULL,
+    FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_comment_revisions_comment_id ON comment_revisions (comment_id);
@@ -0,0 +1,14 @@
+-- Предыдущие версии поста: поля до правки и кто её сделал
+CREATE TABLE post_revisions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id    INTEGER  NOT NULL,
+    title      TEXT     NOT NULL,
+    content    TEXT     NOT NULL,
+    image_path TEXT     NOT NULL DEFAULT '',
+    category   TEXT     NOT NULL,
+    editor_id  INTEGER  NOT NULL,
+    created    DATETIME NOT NULL,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_revisions_post_id ON post_revisions (post_id);
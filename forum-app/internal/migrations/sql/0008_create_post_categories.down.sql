@@ -0,0 +1,24 @@
+-- При откате у поста остаётся только первая из его категорий
+ALTER TABLE post_revisions ADD COLUMN category TEXT NOT NULL DEFAULT '';
+
+UPDATE post_revisions
+SET category = IFNULL((
+    SELECT c.name FROM categories c
+    WHERE CAST(c.id AS TEXT) = CASE
+        WHEN instr(post_revisions.category_ids, ',') > 0
+        THEN substr(post_revisions.category_ids, 1, instr(post_revisions.category_ids, ',') - 1)
+        ELSE post_revisions.category_ids
+    END
+), '');
+
+ALTER TABLE post_revisions DROP COLUMN category_ids;
+
+ALTER TABLE posts ADD COLUMN category TEXT NOT NULL DEFAULT '';
+
+UPDATE posts
+SET category = IFNULL((
+    SELECT c.name FROM post_categories pc JOIN categories c ON c.id = pc.category_id
+    WHERE pc.post_id = posts.id ORDER BY c.id LIMIT 1
+), '');
+
+DROP TABLE IF EXISTS post_categories;
@@ -0,0 +1,22 @@
+-- Пост может относиться к нескольким категориям; связь по id, поэтому переименование категории
+-- сразу видно во всех постах
+CREATE TABLE post_categories (
+    post_id     INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, category_id)
+);
+
+CREATE INDEX idx_post_categories_category_id ON post_categories (category_id);
+
+INSERT INTO post_categories (post_id, category_id)
+SELECT p.id, c.id FROM posts p JOIN categories c ON c.name = p.category;
+
+ALTER TABLE posts DROP COLUMN category;
+
+-- В ревизиях храним id категорий через запятую, например "1,4"
+ALTER TABLE post_revisions ADD COLUMN category_ids TEXT NOT NULL DEFAULT '';
+
+UPDATE post_revisions
+SET category_ids = IFNULL((SELECT CAST(c.id AS TEXT) FROM categories c WHERE c.name = post_revisions.category), '');
+
+ALTER TABLE post_revisions DROP COLUMN category;
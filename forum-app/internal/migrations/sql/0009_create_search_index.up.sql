@@ -0,0 +1,32 @@
+-- Полнотекстовый поиск. FTS5 в mattn/go-sqlite3 собирается только с тегом sqlite_fts5,
+-- поэтому используем FTS4: тот же синтаксис MATCH для фраз и префиксов, snippet() и matchinfo().
+-- docid совпадает с id поста или комментария.
+CREATE VIRTUAL TABLE posts_fts USING fts4(title, content, tokenize=unicode61);
+CREATE VIRTUAL TABLE comments_fts USING fts4(content, tokenize=unicode61);
+
+INSERT INTO posts_fts (docid, title, content) SELECT id, title, content FROM posts;
+INSERT INTO comments_fts (docid, content) SELECT id, content FROM comments;
+
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (docid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+    UPDATE posts_fts SET title = new.title, content = new.content WHERE docid = old.id;
+END;
+
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM posts_fts WHERE docid = old.id;
+END;
+
+CREATE TRIGGER comments_fts_insert AFTER INSERT ON comments BEGIN
+    INSERT INTO comments_fts (docid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+    UPDATE comments_fts SET content = new.content WHERE docid = old.id;
+END;
+
+CREATE TRIGGER comments_fts_delete AFTER DELETE ON comments BEGIN
+    DELETE FROM comments_fts WHERE docid = old.id;
+END;
@@ -0,0 +1,40 @@
+DROP TRIGGER IF EXISTS reactions_comment_delete;
+DROP TRIGGER IF EXISTS reactions_post_delete;
+DROP TRIGGER IF EXISTS reactions_count_delete;
+DROP TRIGGER IF EXISTS reactions_count_update;
+DROP TRIGGER IF EXISTS reactions_count_insert;
+
+CREATE TABLE post_likes (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE post_dislikes (
+    post_id INTEGER NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE TABLE comment_likes (
+    comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE TABLE comment_dislikes (
+    comment_id INTEGER NOT NULL REFERENCES comments (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+INSERT INTO post_likes (post_id, user_id)
+SELECT target_id, user_id FROM reactions WHERE target_type = 'post' AND kind = 'like';
+INSERT INTO post_dislikes (post_id, user_id)
+SELECT target_id, user_id FROM reactions WHERE target_type = 'post' AND kind = 'dislike';
+INSERT INTO comment_likes (comment_id, user_id)
+SELECT target_id, user_id FROM reactions WHERE target_type = 'comment' AND kind = 'like';
+INSERT INTO comment_dislikes (comment_id, user_id)
+SELECT target_id, user_id FROM reactions WHERE target_type = 'comment' AND kind = 'dislike';
+
+DROP TABLE IF EXISTS reactions;
@@ -0,0 +1,70 @@
+-- Одна таблица для всех реакций: у пользователя не больше одной реакции на пост или комментарий,
+-- поэтому лайк и дизлайк одновременно больше невозможны
+CREATE TABLE reactions (
+    target_type TEXT     NOT NULL CHECK (target_type IN ('post', 'comment')),
+    target_id   INTEGER  NOT NULL,
+    user_id     INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind        TEXT     NOT NULL,
+    created     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (target_type, target_id, user_id)
+);
+
+CREATE INDEX idx_reactions_user_id ON reactions (user_id, target_type, kind);
+
+-- Если раньше у пользователя был и лайк, и дизлайк, остаётся лайк
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_id, kind)
+SELECT 'post', post_id, user_id, 'like' FROM post_likes;
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_id, kind)
+SELECT 'post', post_id, user_id, 'dislike' FROM post_dislikes;
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_id, kind)
+SELECT 'comment', comment_id, user_id, 'like' FROM comment_likes;
+INSERT OR IGNORE INTO reactions (target_type, target_id, user_id, kind)
+SELECT 'comment', comment_id, user_id, 'dislike' FROM comment_dislikes;
+
+DROP TABLE post_likes;
+DROP TABLE post_dislikes;
+DROP TABLE comment_likes;
+DROP TABLE comment_dislikes;
+
+-- Старые счётчики могли разойтись с реальными реакциями, пересчитываем их
+UPDATE posts SET
+    likes    = (SELECT COUNT(*) FROM reactions WHERE target_type = 'post' AND target_id = posts.id AND kind = 'like'),
+    dislikes = (SELECT COUNT(*) FROM reactions WHERE target_type = 'post' AND target_id = posts.id AND kind = 'dislike');
+UPDATE comments SET
+    likes    = (SELECT COUNT(*) FROM reactions WHERE target_type = 'comment' AND target_id = comments.id AND kind = 'like'),
+    dislikes = (SELECT COUNT(*) FROM reactions WHERE target_type = 'comment' AND target_id = comments.id AND kind = 'dislike');
+
+-- Счётчики меняются в той же транзакции, что и реакция
+CREATE TRIGGER reactions_count_insert AFTER INSERT ON reactions BEGIN
+    UPDATE posts SET likes = likes + (NEW.kind = 'like'), dislikes = dislikes + (NEW.kind = 'dislike')
+    WHERE NEW.target_type = 'post' AND id = NEW.target_id;
+    UPDATE comments SET likes = likes + (NEW.kind = 'like'), dislikes = dislikes + (NEW.kind = 'dislike')
+    WHERE NEW.target_type = 'comment' AND id = NEW.target_id;
+END;
+
+CREATE TRIGGER reactions_count_update AFTER UPDATE OF kind ON reactions BEGIN
+    UPDATE posts SET
+        likes    = likes - (OLD.kind = 'like') + (NEW.kind = 'like'),
+        dislikes = dislikes - (OLD.kind = 'dislike') + (NEW.kind = 'dislike')
+    WHERE NEW.target_type = 'post' AND id = NEW.target_id;
+    UPDATE comments SET
+        likes    = likes - (OLD.kind = 'like') + (NEW.kind = 'like'),
+        dislikes = dislikes - (OLD.kind = 'dislike') + (NEW.kind = 'dislike')
+    WHERE NEW.target_type = 'comment' AND id = NEW.target_id;
+END;
+
+CREATE TRIGGER reactions_count_delete AFTER DELETE ON reactions BEGIN
+    UPDATE posts SET likes = likes - (OLD.kind = 'like'), dislikes = dislikes - (OLD.kind = 'dislike')
+    WHERE OLD.target_type = 'post' AND id = OLD.target_id;
+    UPDATE comments SET likes = likes - (OLD.kind = 'like'), dislikes = dislikes - (OLD.kind = 'dislike')
+    WHERE OLD.target_type = 'comment' AND id = OLD.target_id;
+END;
+
+-- У target_id нет внешнего ключа, поэтому реакции удалённых постов и комментариев чистят триггеры
+CREATE TRIGGER reactions_post_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM reactions WHERE target_type = 'post' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER reactions_comment_delete AFTER DELETE ON comments BEGIN
+    DELETE FROM reactions WHERE target_type = 'comment' AND target_id = OLD.id;
+END;
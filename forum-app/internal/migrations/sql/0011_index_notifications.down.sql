@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_notifications_prune;
+DROP INDEX IF EXISTS idx_notifications_group;
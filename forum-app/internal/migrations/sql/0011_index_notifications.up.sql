@@ -0,0 +1,3 @@
+-- Группировка уведомлений ("5 people liked your post") и удаление старых прочитанных
+CREATE INDEX idx_notifications_group ON notifications (user_id, type, post_id, comment_id);
+CREATE INDEX idx_notifications_prune ON notifications (is_read, created);
@@ -0,0 +1,2 @@
+ALTER TABLE notifications DROP COLUMN channel;
+DROP TABLE IF EXISTS notification_preferences;
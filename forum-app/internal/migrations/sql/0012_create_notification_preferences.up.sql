@@ -0,0 +1,10 @@
+-- Куда доставлять уведомления каждой категории; нет строки — на сайт
+CREATE TABLE notification_preferences (
+    user_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    category TEXT    NOT NULL,
+    channel  TEXT    NOT NULL CHECK (channel IN ('in_app', 'email', 'off')),
+    PRIMARY KEY (user_id, category)
+);
+
+-- in_app — видно на сайте, email — ждёт дайджеста
+ALTER TABLE notifications ADD COLUMN channel TEXT NOT NULL DEFAULT 'in_app';
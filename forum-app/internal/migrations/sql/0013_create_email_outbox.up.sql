@@ -0,0 +1,15 @@
+-- Письма ждут отправки здесь, чтобы пережить перезапуск и недоступность SMTP
+CREATE TABLE email_outbox (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient    TEXT     NOT NULL,
+    subject      TEXT     NOT NULL,
+    text_body    TEXT     NOT NULL,
+    html_body    TEXT     NOT NULL DEFAULT '',
+    attempts     INTEGER  NOT NULL DEFAULT 0,
+    last_error   TEXT     NOT NULL DEFAULT '',
+    next_attempt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent         DATETIME,
+    created      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_outbox_due ON email_outbox (sent, next_attempt);
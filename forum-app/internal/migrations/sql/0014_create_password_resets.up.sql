@@ -0,0 +1,18 @@
+-- Одноразовые ссылки сброса пароля; хранится только SHA-256 токена
+CREATE TABLE password_resets (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT     NOT NULL UNIQUE,
+    created    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expiry     DATETIME NOT NULL
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
+
+-- Запросы сброса по адресу — и для несуществующих адресов тоже, чтобы лимит ничего не выдавал
+CREATE TABLE password_reset_requests (
+    email   TEXT     NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_reset_requests_email ON password_reset_requests (email, created);
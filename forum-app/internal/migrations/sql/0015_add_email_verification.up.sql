@@ -0,0 +1,11 @@
+-- Подтверждение адреса: новые аккаунты не подтверждены, уже существующие считаем подтверждёнными
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT 0;
+UPDATE users SET email_verified = 1;
+
+-- Отправленные письма подтверждения — для лимита повторной отправки
+CREATE TABLE email_verification_requests (
+    user_id INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_verification_requests_user_id ON email_verification_requests (user_id, created);
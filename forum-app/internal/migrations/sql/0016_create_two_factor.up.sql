@@ -0,0 +1,22 @@
+-- Двухфакторная аутентификация: секрет TOTP и последний принятый шаг, чтобы код нельзя было повторить
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+-- Одноразовые коды восстановления; хранится только SHA-256
+CREATE TABLE recovery_codes (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id   INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash TEXT    NOT NULL,
+    used      DATETIME
+);
+
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+-- Второй шаг входа: пароль уже проверен, ждём код
+CREATE TABLE login_challenges (
+    token_hash TEXT PRIMARY KEY,
+    user_id    INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    attempts   INTEGER  NOT NULL DEFAULT 0,
+    expiry     DATETIME NOT NULL
+);
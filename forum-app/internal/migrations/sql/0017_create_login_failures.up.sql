@@ -0,0 +1,11 @@
+-- Неудачные попытки входа: по ним считаются задержки и временная блокировка по адресу и по IP.
+-- email хранится в нижнем регистре и пишется и для несуществующих адресов
+CREATE TABLE login_failures (
+    id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    email   TEXT     NOT NULL,
+    ip      TEXT     NOT NULL,
+    created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_failures_email ON login_failures (email, created);
+CREATE INDEX idx_login_failures_ip ON login_failures (ip, created);
@@ -0,0 +1,40 @@
+-- Обратно к индексу FTS4 из 0009
+DROP TRIGGER IF EXISTS comments_fts_delete;
+DROP TRIGGER IF EXISTS comments_fts_update;
+DROP TRIGGER IF EXISTS comments_fts_insert;
+DROP TRIGGER IF EXISTS posts_fts_delete;
+DROP TRIGGER IF EXISTS posts_fts_update;
+DROP TRIGGER IF EXISTS posts_fts_insert;
+
+DROP TABLE IF EXISTS comments_fts;
+DROP TABLE IF EXISTS posts_fts;
+
+CREATE VIRTUAL TABLE posts_fts USING fts4(title, content, tokenize=unicode61);
+CREATE VIRTUAL TABLE comments_fts USING fts4(content, tokenize=unicode61);
+
+INSERT INTO posts_fts (docid, title, content) SELECT id, title, content FROM posts;
+INSERT INTO comments_fts (docid, content) SELECT id, content FROM comments;
+
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (docid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+    UPDATE posts_fts SET title = new.title, content = new.content WHERE docid = old.id;
+END;
+
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM posts_fts WHERE docid = old.id;
+END;
+
+CREATE TRIGGER comments_fts_insert AFTER INSERT ON comments BEGIN
+    INSERT INTO comments_fts (docid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+    UPDATE comments_fts SET content = new.content WHERE docid = old.id;
+END;
+
+CREATE TRIGGER comments_fts_delete AFTER DELETE ON comments BEGIN
+    DELETE FROM comments_fts WHERE docid = old.id;
+END;
@@ -0,0 +1,42 @@
+-- Поиск переезжает на FTS5: встроенные bm25() и snippet() вместо ранжирования по matchinfo() в Go.
+-- mattn/go-sqlite3 включает FTS5 только с тегом сборки sqlite_fts5 (go build -tags sqlite_fts5).
+-- rowid совпадает с id поста или комментария.
+DROP TRIGGER comments_fts_delete;
+DROP TRIGGER comments_fts_update;
+DROP TRIGGER comments_fts_insert;
+DROP TRIGGER posts_fts_delete;
+DROP TRIGGER posts_fts_update;
+DROP TRIGGER posts_fts_insert;
+
+DROP TABLE comments_fts;
+DROP TABLE posts_fts;
+
+CREATE VIRTUAL TABLE posts_fts USING fts5(title, content, tokenize = 'unicode61');
+CREATE VIRTUAL TABLE comments_fts USING fts5(content, tokenize = 'unicode61');
+
+INSERT INTO posts_fts (rowid, title, content) SELECT id, title, content FROM posts;
+INSERT INTO comments_fts (rowid, content) SELECT id, content FROM comments;
+
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+    UPDATE posts_fts SET title = new.title, content = new.content WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM posts_fts WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER comments_fts_insert AFTER INSERT ON comments BEGIN
+    INSERT INTO comments_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+    UPDATE comments_fts SET content = new.content WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER comments_fts_delete AFTER DELETE ON comments BEGIN
+    DELETE FROM comments_fts WHERE rowid = old.id;
+END;
@@ -0,0 +1,3 @@
+-- Удалённый комментарий, на который уже ответили, остаётся в дереве без текста;
+-- deleted — когда его удалили, NULL — не удалён
+ALTER TABLE comments ADD COLUMN deleted DATETIME;
@@ -2,12 +2,13 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"strings"
 )
 
 type Category struct {
-	ID   int
-	Name string
+	ID   int    `json:"id"`
+	Name string `json:"name"`
 }
 
 type CategoryModel struct {
@@ -33,22 +34,41 @@ func (m *CategoryModel) GetAll() ([]*Category, error) {
 	}
 	return categories, nil
 }
-func (m *CategoryModel) Insert(name string) error {
+func (m *CategoryModel) Insert(name string) (int, error) {
 	stmt := `INSERT INTO categories (name) VALUES (?)`
-	_, err := m.DB.Exec(stmt, name)
+	result, err := m.DB.Exec(stmt, name)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			return ErrDuplicateCategory
+			return 0, ErrDuplicateCategory
 		}
-		return err
+		return 0, err
 	}
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
 }
 
+// Update переименовывает категорию; посты ссылаются на неё по id через post_categories,
+// поэтому новое название сразу видно во всех постах
 func (m *CategoryModel) Update(id int, newName string) error {
 	stmt := `UPDATE categories SET name = ? WHERE id = ?`
-	_, err := m.DB.Exec(stmt, newName, id)
-	return err
+	result, err := m.DB.Exec(stmt, newName, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrDuplicateCategory
+		}
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
 }
 
 func (m *CategoryModel) Delete(id int) error {
@@ -56,3 +76,17 @@ func (m *CategoryModel) Delete(id int) error {
 	_, err := m.DB.Exec(stmt, id)
 	return err
 }
+
+func (m *CategoryModel) Get(id int) (*Category, error) {
+	stmt := `SELECT id, name FROM categories WHERE id = ?`
+
+	c := &Category{}
+	err := m.DB.QueryRow(stmt, id).Scan(&c.ID, &c.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return c, nil
+}
@@ -2,25 +2,73 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"sort"
 	"time"
 )
 
+// Ограничения дерева комментариев: глубже CommentMaxDepth ответы выстраиваются на последнем уровне,
+// а ветки начиная с CommentCollapseDepth по умолчанию свёрнуты
+const (
+	CommentMaxDepth      = 5
+	CommentCollapseDepth = 3
+)
+
 type Comment struct {
-	ID       int
-	PostID   int
-	Content  string
-	Likes    int
-	Dislikes int
-	UserID   int
-	Author   string
-	Created  time.Time
+	ID       int        `json:"id"`
+	PostID   int        `json:"post_id"`
+	ParentID int        `json:"parent_id"`
+	Content  string     `json:"content"`
+	Likes    int        `json:"likes"`
+	Dislikes int        `json:"dislikes"`
+	UserID   int        `json:"user_id"`
+	Author   string     `json:"author"`
+	Created  time.Time  `json:"created"`
+	Edited   *time.Time `json:"edited,omitempty"`  // nil — комментарий не редактировался
+	Deleted  *time.Time `json:"deleted,omitempty"` // удалён, но оставлен ради ответов; текст пустой
+	Replies  []*Comment `json:"replies,omitempty"`
+	// Reactions заполняется только там, где показываются кнопки реакций
+	Reactions []ReactionCount `json:"reactions,omitempty"`
+	// Заполняются при сборке дерева
+	Depth      int  `json:"-"`
+	Collapsed  bool `json:"-"`
+	ReplyCount int  `json:"-"`
 }
+
+// CommentRevision — версия комментария до очередной правки
+type CommentRevision struct {
+	ID        int       `json:"id"`
+	CommentID int       `json:"comment_id"`
+	Content   string    `json:"content"`
+	EditorID  int       `json:"editor_id"`
+	Editor    string    `json:"editor"`
+	Created   time.Time `json:"created"` // когда текст был заменён
+}
+
 type CommentModel struct {
 	DB *sql.DB
 }
 
+const commentColumns = `id, post_id, IFNULL(parent_id, 0), content, likes, dislikes, user_id, author, created, edited, deleted`
+
+func scanComment(row rowScanner) (*Comment, error) {
+	comment := &Comment{}
+	var edited, deleted sql.NullTime
+	err := row.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Likes, &comment.Dislikes, &comment.UserID, &comment.Author, &comment.Created, &edited, &deleted)
+	if err != nil {
+		return nil, err
+	}
+	if edited.Valid {
+		comment.Edited = &edited.Time
+	}
+	if deleted.Valid {
+		comment.Deleted = &deleted.Time
+	}
+	return comment, nil
+}
+
 func (m *CommentModel) GetByPostID(postID int) ([]*Comment, error) {
-	stmt := `SELECT id, post_id, content, likes, dislikes, user_id, author, created FROM comments WHERE post_id = ? ORDER BY created ASC`
+	stmt := `SELECT ` + commentColumns + ` FROM comments WHERE post_id = ? ORDER BY created ASC, id ASC`
 
 	rows, err := m.DB.Query(stmt, postID)
 	if err != nil {
@@ -30,8 +78,7 @@ func (m *CommentModel) GetByPostID(postID int) ([]*Comment, error) {
 
 	var comments []*Comment
 	for rows.Next() {
-		comment := &Comment{}
-		err := rows.Scan(&comment.ID, &comment.PostID, &comment.Content, &comment.Likes, &comment.Dislikes, &comment.UserID, &comment.Author, &comment.Created)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -46,9 +93,14 @@ func (m *CommentModel) GetByPostID(postID int) ([]*Comment, error) {
 }
 
 func (m *CommentModel) Insert(comment *Comment) error {
-	stmt := `INSERT INTO comments (post_id, content, user_id, author, created) VALUES (?, ?, ?, ?,  DATETIME('now', 'localtime'))`
+	stmt := `INSERT INTO comments (post_id, parent_id, content, user_id, author, created) VALUES (?, ?, ?, ?, ?,  DATETIME('now', 'localtime'))`
 
-	result, err := m.DB.Exec(stmt, comment.PostID, comment.Content, comment.UserID, comment.Author)
+	var parentID sql.NullInt64
+	if comment.ParentID != 0 {
+		parentID = sql.NullInt64{Int64: int64(comment.ParentID), Valid: true}
+	}
+
+	result, err := m.DB.Exec(stmt, comment.PostID, parentID, comment.Content, comment.UserID, comment.Author)
 	if err != nil {
 		return err
 	}
@@ -62,41 +114,127 @@ func (m *CommentModel) Insert(comment *Comment) error {
 
 	return nil
 }
-func (m *CommentModel) Delete(commentID int) error {
-	stmt := `DELETE FROM comments WHERE id = ?`
 
-	_, err := m.DB.Exec(stmt, commentID)
+// Delete удаляет комментарий. Ответы других пользователей и реакции на них не трогаются:
+// если на комментарий уже ответили, строка остаётся, а текст и история правок стираются.
+// Удалённые комментарии, у которых больше не осталось ответов, удаляются совсем.
+func (m *CommentModel) Delete(commentID int) error {
+	tx, err := m.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	for id := commentID; id != 0; {
+		var parentID, replies int
+		var deleted sql.NullTime
+		err := tx.QueryRow(`SELECT IFNULL(parent_id, 0), deleted, (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id)
+		FROM comments c WHERE id = ?`, id).Scan(&parentID, &deleted, &replies)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		// Предки поднимаются, только если это удалённые комментарии без других ответов
+		if id != commentID && (!deleted.Valid || replies > 0) {
+			break
+		}
+		if replies > 0 {
+			if _, err := tx.Exec(`UPDATE comments SET content = '', deleted = ? WHERE id = ?`, time.Now().UTC(), id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM comment_revisions WHERE comment_id = ?`, id); err != nil {
+				return err
+			}
+			break
+		}
+		if _, err := tx.Exec(`DELETE FROM comments WHERE id = ?`, id); err != nil {
+			return err
+		}
+		id = parentID
+	}
+
+	return tx.Commit()
 }
-func (m *CommentModel) Update(commentID int, content string) error {
-	stmt := `UPDATE comments SET content = ?, created = UTC_TIMESTAMP() WHERE id = ?`
 
-	_, err := m.DB.Exec(stmt, content, commentID)
+// Update меняет текст комментария, сохраняя прежнюю версию в comment_revisions.
+// Если текст не изменился, ничего не записывается.
+func (m *CommentModel) Update(commentID, editorID int, content string) error {
+	tx, err := m.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	var current string
+	err = tx.QueryRow(`SELECT content FROM comments WHERE id = ?`, commentID).Scan(&current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	if current == content {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	stmt := `INSERT INTO comment_revisions (comment_id, content, editor_id, created) VALUES (?, ?, ?, ?)`
+	if _, err := tx.Exec(stmt, commentID, current, editorID, now); err != nil {
+		return err
+	}
+
+	stmt = `UPDATE comments SET content = ?, edited = ? WHERE id = ?`
+	if _, err := tx.Exec(stmt, content, now, commentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
-func (m *CommentModel) GetByID(commentID int) (*Comment, error) {
-	stmt := `SELECT id, post_id, content, likes, dislikes, user_id, author,  created FROM comments WHERE id = ?`
 
-	row := m.DB.QueryRow(stmt, commentID)
+// Revisions возвращает прежние версии комментария, от самой старой к новой
+func (m *CommentModel) Revisions(commentID int) ([]*CommentRevision, error) {
+	stmt := `SELECT r.id, r.comment_id, r.content, r.editor_id, IFNULL(u.name, ''), r.created
+	FROM comment_revisions r LEFT JOIN users u ON u.id = r.editor_id
+	WHERE r.comment_id = ? ORDER BY r.id ASC`
 
-	comment := &Comment{}
-	err := row.Scan(&comment.ID, &comment.PostID, &comment.Content, &comment.Likes, &comment.Dislikes, &comment.UserID, &comment.Author, &comment.Created)
+	rows, err := m.DB.Query(stmt, commentID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var revisions []*CommentRevision
+	for rows.Next() {
+		rev := &CommentRevision{}
+		err := rows.Scan(&rev.ID, &rev.CommentID, &rev.Content, &rev.EditorID, &rev.Editor, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (m *CommentModel) GetByID(commentID int) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments WHERE id = ?`
+
+	comment, err := scanComment(m.DB.QueryRow(stmt, commentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
 
 	return comment, nil
 }
 func (m *CommentModel) UserComments(userId int) ([]*Comment, error) {
-	stmt := `SELECT id, post_id, content, likes, dislikes, user_id, author,  created FROM comments WHERE user_id = ? ORDER BY created ASC`
+	stmt := `SELECT ` + commentColumns + ` FROM comments WHERE user_id = ? AND deleted IS NULL ORDER BY created ASC`
 
 	rows, err := m.DB.Query(stmt, userId)
 	if err != nil {
@@ -106,8 +244,7 @@ func (m *CommentModel) UserComments(userId int) ([]*Comment, error) {
 
 	var comments []*Comment
 	for rows.Next() {
-		comment := &Comment{}
-		err := rows.Scan(&comment.ID, &comment.PostID, &comment.Content, &comment.Likes, &comment.Dislikes, &comment.UserID, &comment.Author, &comment.Created)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -120,3 +257,59 @@ func (m *CommentModel) UserComments(userId int) ([]*Comment, error) {
 
 	return comments, nil
 }
+
+// GetThreadByPostID возвращает комментарии поста в виде дерева ответов
+func (m *CommentModel) GetThreadByPostID(postID int) ([]*Comment, error) {
+	comments, err := m.GetByPostID(postID)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCommentTree(comments, CommentMaxDepth, CommentCollapseDepth), nil
+}
+
+// BuildCommentTree собирает плоский список (в порядке создания) в дерево.
+// Ответы глубже maxDepth прикрепляются к ближайшему предку на уровне maxDepth-1,
+// ветки, начинающиеся на глубине collapseDepth, помечаются как свёрнутые.
+// Ответы на удалённые или чужие комментарии становятся корневыми.
+func BuildCommentTree(comments []*Comment, maxDepth, collapseDepth int) []*Comment {
+	byID := make(map[int]*Comment, len(comments))
+	for _, c := range comments {
+		c.Replies = nil
+		byID[c.ID] = c
+	}
+
+	// Родитель всегда создан раньше ответа, поэтому обходим по возрастанию ID
+	ordered := make([]*Comment, len(comments))
+	copy(ordered, comments)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].ID < ordered[j].ID })
+
+	var roots []*Comment
+	for _, c := range ordered {
+		parent, ok := byID[c.ParentID]
+		if !ok || c.ParentID == c.ID {
+			c.Depth = 0
+			roots = append(roots, c)
+			continue
+		}
+		for parent.Depth >= maxDepth {
+			parent = byID[parent.ParentID]
+		}
+		c.Depth = parent.Depth + 1
+		c.Collapsed = c.Depth == collapseDepth
+		parent.Replies = append(parent.Replies, c)
+	}
+
+	for _, root := range roots {
+		countReplies(root)
+	}
+	return roots
+}
+
+func countReplies(c *Comment) int {
+	total := 0
+	for _, reply := range c.Replies {
+		total += 1 + countReplies(reply)
+	}
+	c.ReplyCount = total
+	return total
+}
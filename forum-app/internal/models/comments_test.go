@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestBuildCommentTree(t *testing.T) {
+	// 1
+	// └─ 2
+	//    └─ 3
+	//       └─ 4   (глубже maxDepth=2, поднимается на уровень 3)
+	// 5 — ответ на несуществующий комментарий, становится корнем
+	comments := []*Comment{
+		{ID: 1},
+		{ID: 2, ParentID: 1},
+		{ID: 3, ParentID: 2},
+		{ID: 4, ParentID: 3},
+		{ID: 5, ParentID: 42},
+	}
+
+	roots := BuildCommentTree(comments, 2, 2)
+
+	if len(roots) != 2 || roots[0].ID != 1 || roots[1].ID != 5 {
+		t.Fatalf("Expected roots [1 5], got %v", ids(roots))
+	}
+	if roots[0].ReplyCount != 3 {
+		t.Errorf("Expected 3 replies under comment 1, got %d", roots[0].ReplyCount)
+	}
+
+	second := roots[0].Replies[0]
+	if len(second.Replies) != 2 {
+		t.Fatalf("Expected comment 4 to be flattened next to comment 3, got %v", ids(second.Replies))
+	}
+	for _, c := range second.Replies {
+		if c.Depth != 2 {
+			t.Errorf("Expected comment %d at depth 2, got %d", c.ID, c.Depth)
+		}
+		if !c.Collapsed {
+			t.Errorf("Expected comment %d to be collapsed", c.ID)
+		}
+	}
+	if second.Collapsed {
+		t.Error("Expected comment 2 to stay expanded")
+	}
+}
+
+func ids(comments []*Comment) []int {
+	var out []int
+	for _, c := range comments {
+		out = append(out, c.ID)
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrEmailChanged = errors.New("models: the email address has changed since the verification link was sent")
+
+// ConfirmEmail подтверждает адрес target пользователя id. Если target отличается от current,
+// это смена адреса: target становится новым адресом. Ссылка действует, только пока у пользователя
+// адрес current, поэтому после следующей смены адреса старые ссылки перестают работать.
+func (m *UserModel) ConfirmEmail(id int, current, target string) error {
+	stmt := `UPDATE users SET email = ?, email_verified = 1 WHERE id = ? AND email = ?`
+	result, err := m.DB.Exec(stmt, target, id, current)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: users.email") {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrEmailChanged
+	}
+	return nil
+}
+
+// AllowVerificationEmail записывает отправку письма подтверждения пользователю userID,
+// если за window их было меньше limit
+func (m *UserModel) AllowVerificationEmail(userID, limit int, window time.Duration, now time.Time) (bool, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM email_verification_requests WHERE created < ?`, sqliteTime(now.Add(-window))); err != nil {
+		return false, err
+	}
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM email_verification_requests WHERE user_id = ?`, userID).Scan(&count); err != nil {
+		return false, err
+	}
+	if count >= limit {
+		return false, tx.Commit()
+	}
+	if _, err := tx.Exec(`INSERT INTO email_verification_requests (user_id, created) VALUES (?, ?)`, userID, sqliteTime(now)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
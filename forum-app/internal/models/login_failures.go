@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoginFailures — неудачные попытки входа по адресу и по IP за период
+type LoginFailures struct {
+	Account     int
+	AccountLast time.Time // последняя неудача по адресу; нулевое значение — не было
+	IP          int
+	IPLast      time.Time
+}
+
+// LockedAccount — аккаунт с неудачными попытками входа, для страницы администратора
+type LockedAccount struct {
+	UserID      int
+	Name        string
+	Email       string
+	Failures    int
+	LastFailure time.Time
+}
+
+// LoginFailureModel считает неудачные входы. Решение о задержке и блокировке принимает вызывающий.
+type LoginFailureModel struct {
+	DB *sql.DB
+}
+
+// Record записывает неудачную попытку входа в аккаунт email с адреса ip
+func (m *LoginFailureModel) Record(email, ip string, now time.Time) error {
+	stmt := `INSERT INTO login_failures (email, ip, created) VALUES (?, ?, ?)`
+	_, err := m.DB.Exec(stmt, normalizeEmail(email), ip, failureTime(now))
+	return err
+}
+
+// Count возвращает неудачи по email и по ip начиная с since
+func (m *LoginFailureModel) Count(email, ip string, since time.Time) (*LoginFailures, error) {
+	f := &LoginFailures{}
+	var accountLast, ipLast sql.NullString
+	stmt := `SELECT
+	(SELECT COUNT(*) FROM login_failures WHERE email = ?1 AND created >= ?3),
+	(SELECT MAX(created) FROM login_failures WHERE email = ?1 AND created >= ?3),
+	(SELECT COUNT(*) FROM login_failures WHERE ip = ?2 AND created >= ?3),
+	(SELECT MAX(created) FROM login_failures WHERE ip = ?2 AND created >= ?3)`
+	err := m.DB.QueryRow(stmt, normalizeEmail(email), ip, failureTime(since)).Scan(&f.Account, &accountLast, &f.IP, &ipLast)
+	if err != nil {
+		return nil, err
+	}
+	if f.AccountLast, err = parseSQLiteTime(accountLast); err != nil {
+		return nil, err
+	}
+	if f.IPLast, err = parseSQLiteTime(ipLast); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Clear забывает неудачи по адресу: после успешного входа или разблокировки администратором
+func (m *LoginFailureModel) Clear(email string) error {
+	_, err := m.DB.Exec(`DELETE FROM login_failures WHERE email = ?`, normalizeEmail(email))
+	return err
+}
+
+// Locked возвращает существующие аккаунты, у которых с since набралось не меньше threshold неудач
+// и последняя была позже lastAfter — то есть блокировка ещё действует
+func (m *LoginFailureModel) Locked(threshold int, since, lastAfter time.Time) ([]*LockedAccount, error) {
+	stmt := `SELECT u.id, u.name, u.email, COUNT(*), MAX(f.created) FROM login_failures f
+	JOIN users u ON LOWER(u.email) = f.email
+	WHERE f.created >= ?
+	GROUP BY u.id HAVING COUNT(*) >= ? AND MAX(f.created) > ?
+	ORDER BY MAX(f.created) DESC`
+	rows, err := m.DB.Query(stmt, failureTime(since), threshold, failureTime(lastAfter))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*LockedAccount
+	for rows.Next() {
+		a := &LockedAccount{}
+		var last sql.NullString
+		if err := rows.Scan(&a.UserID, &a.Name, &a.Email, &a.Failures, &last); err != nil {
+			return nil, err
+		}
+		if a.LastFailure, err = parseSQLiteTime(last); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// Prune удаляет попытки старше before — они уже ни на что не влияют
+func (m *LoginFailureModel) Prune(before time.Time) (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM login_failures WHERE created < ?`, failureTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// failureTime — как sqliteTime, но с миллисекундами: паузы после неудач длятся секунды,
+// и округление времени неудачи вниз до секунды съедало бы их почти целиком
+func failureTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000")
+}
+
+// parseSQLiteTime разбирает результат MAX() по столбцу DATETIME: у агрегатов нет типа столбца,
+// и драйвер отдаёт строку в формате sqliteTime (доли секунды time.Parse принимает и без них в шаблоне)
+func parseSQLiteTime(s sql.NullString) (time.Time, error) {
+	if !s.Valid {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", s.String, time.UTC)
+}
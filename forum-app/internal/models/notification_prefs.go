@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+)
+
+// Куда доставлять уведомления
+const (
+	ChannelInApp = "in_app" // на сайт, с живым счётчиком
+	ChannelEmail = "email"  // в email-дайджест
+	ChannelOff   = "off"    // не уведомлять
+)
+
+var NotificationChannels = []string{ChannelInApp, ChannelEmail, ChannelOff}
+
+// Категории настроек; одна категория объединяет несколько типов уведомлений
+const (
+	CategoryComment    = "comment"
+	CategoryReply      = "reply"
+	CategoryReaction   = "reaction"
+	CategoryMention    = "mention"
+	CategoryModeration = "moderation"
+	CategoryReport     = "report"
+)
+
+// NotificationCategories — все категории в порядке вывода на странице настроек
+var NotificationCategories = []string{
+	CategoryComment, CategoryReply, CategoryReaction, CategoryMention, CategoryModeration, CategoryReport,
+}
+
+var ErrInvalidPreference = errors.New("models: invalid notification preference")
+
+// NotificationCategory возвращает категорию настроек для типа уведомления
+func NotificationCategory(ntype string) string {
+	switch ntype {
+	case NotificationComment:
+		return CategoryComment
+	case NotificationReply:
+		return CategoryReply
+	case NotificationMention:
+		return CategoryMention
+	case NotificationPostApproved:
+		return CategoryModeration
+	case NotificationReportAnswered:
+		return CategoryReport
+	default:
+		// лайки, дизлайки и эмодзи-реакции на посты и комментарии
+		return CategoryReaction
+	}
+}
+
+// Preferences возвращает канал для каждой категории; не заданные — ChannelInApp
+func (m *NotificationModel) Preferences(userID int) (map[string]string, error) {
+	prefs := make(map[string]string, len(NotificationCategories))
+	for _, c := range NotificationCategories {
+		prefs[c] = ChannelInApp
+	}
+
+	rows, err := m.DB.Query(`SELECT category, channel FROM notification_preferences WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var category, channel string
+		if err := rows.Scan(&category, &channel); err != nil {
+			return nil, err
+		}
+		if _, ok := prefs[category]; ok {
+			prefs[category] = channel
+		}
+	}
+	return prefs, rows.Err()
+}
+
+// Channel возвращает канал пользователя для категории
+func (m *NotificationModel) Channel(userID int, category string) (string, error) {
+	var channel string
+	stmt := `SELECT channel FROM notification_preferences WHERE user_id = ? AND category = ?`
+	err := m.DB.QueryRow(stmt, userID, category).Scan(&channel)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChannelInApp, nil
+	}
+	return channel, err
+}
+
+// SetPreferences сохраняет каналы для переданных категорий; остальные не меняются
+func (m *NotificationModel) SetPreferences(userID int, prefs map[string]string) error {
+	for category, channel := range prefs {
+		if !slices.Contains(NotificationCategories, category) || !slices.Contains(NotificationChannels, channel) {
+			return ErrInvalidPreference
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO notification_preferences (user_id, category, channel) VALUES (?, ?, ?)
+	ON CONFLICT (user_id, category) DO UPDATE SET channel = excluded.channel`
+	for category, channel := range prefs {
+		if _, err := tx.Exec(stmt, userID, category, channel); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
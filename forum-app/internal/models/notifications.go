@@ -2,48 +2,118 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"forum-app/internal/events"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Notification struct {
-	ID        int
-	UserID    int
-	Type      string
-	PostID    int
-	CommentID int
-	Created   time.Time
-	IsRead    bool
-	ActorID   int
-	ActorName string
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Type      string    `json:"type"`
+	PostID    int       `json:"post_id"`
+	CommentID int       `json:"comment_id"`
+	Created   time.Time `json:"created"`
+	IsRead    bool      `json:"is_read"`
+	ActorID   int       `json:"actor_id"`
+	ActorName string    `json:"actor_name"`
 }
 
+// Типы уведомлений
+const (
+	NotificationComment         = "comment"
+	NotificationReply           = "reply"
+	NotificationPostLike        = "post_like"
+	NotificationPostDislike     = "post_dislike"
+	NotificationPostReaction    = "post_reaction"
+	NotificationCommentLike     = "comment_like"
+	NotificationCommentDislike  = "comment_dislike"
+	NotificationCommentReaction = "comment_reaction"
+	NotificationMention         = "mention"
+	NotificationPostApproved    = "post_approved"
+	NotificationReportAnswered  = "report_answered"
+)
+
+// NotificationTypes — все типы в порядке вывода в фильтре
+var NotificationTypes = []string{
+	NotificationComment, NotificationReply,
+	NotificationPostLike, NotificationPostDislike, NotificationPostReaction,
+	NotificationCommentLike, NotificationCommentDislike, NotificationCommentReaction,
+	NotificationMention, NotificationPostApproved, NotificationReportAnswered,
+}
+
+var ErrInvalidNotificationType = errors.New("models: invalid notification type")
+
+// События, которые NotificationModel публикует в Events
+const (
+	EventNotification = "notification" // Data — *Notification, ID — id уведомления
+	EventRead         = "read"         // уведомления прочитаны или удалены в другой вкладке
+)
+
 type NotificationModel struct {
 	DB *sql.DB
+	// Events получает каждое новое уведомление; nil — живые обновления выключены
+	Events *events.Hub
 }
 
+// Insert создаёт уведомление с учётом настроек получателя: выключенное не сохраняется,
+// а уведомление для email-дайджеста не показывается на сайте и не публикуется в Events
 func (m *NotificationModel) Insert(userID, actorID int, ntype string, postID, commentID int) error {
-	stmt := `INSERT INTO notifications (user_id, type, post_id, comment_id, actor_id) VALUES (?, ?, ?, ?, ?)`
+	channel, err := m.Channel(userID, NotificationCategory(ntype))
+	if err != nil || channel == ChannelOff {
+		return err
+	}
 
-	_, err := m.DB.Exec(stmt, userID, ntype, postID, commentID, actorID)
-	return err
+	stmt := `INSERT INTO notifications (user_id, type, post_id, comment_id, actor_id, channel) VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := m.DB.Exec(stmt, userID, ntype, postID, commentID, actorID, channel)
+	if err != nil || m.Events == nil || channel != ChannelInApp {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	n, err := m.Get(int(id))
+	if err != nil {
+		return err
+	}
+	m.Events.Publish(userID, events.Event{ID: id, Name: EventNotification, Data: n})
+	return nil
 }
 
-func (m *NotificationModel) GetUnreadCount(userID int) (int, error) {
-	var count int
-	stmt := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0`
-	err := m.DB.QueryRow(stmt, userID).Scan(&count)
-	return count, err
+const notificationColumns = `n.id, n.user_id, n.type, n.post_id, n.comment_id, n.created, n.is_read, u.id, u.name`
+
+func scanNotification(row rowScanner) (*Notification, error) {
+	n := &Notification{}
+	var isRead int
+	err := row.Scan(&n.ID, &n.UserID, &n.Type, &n.PostID, &n.CommentID, &n.Created, &isRead, &n.ActorID, &n.ActorName)
+	if err != nil {
+		return nil, err
+	}
+	n.IsRead = isRead == 1
+	return n, nil
 }
 
-func (m *NotificationModel) GetAll(userID int) ([]*Notification, error) {
-	stmt := `SELECT n.id, n.type, n.post_id, n.comment_id, n.created, n.is_read,
-         u.id, u.name
-         FROM notifications n
-         JOIN users u ON n.actor_id = u.id
-         WHERE n.user_id = ?
-         ORDER BY n.created DESC`
+// Get возвращает уведомление вместе с именем того, кто его вызвал
+func (m *NotificationModel) Get(id int) (*Notification, error) {
+	stmt := `SELECT ` + notificationColumns + ` FROM notifications n JOIN users u ON n.actor_id = u.id WHERE n.id = ?`
+	n, err := scanNotification(m.DB.QueryRow(stmt, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	return n, err
+}
 
-	rows, err := m.DB.Query(stmt, userID)
+// Since возвращает уведомления пользователя с id больше afterID по возрастанию —
+// то, что клиент пропустил, пока был отключён от потока
+func (m *NotificationModel) Since(userID, afterID int) ([]*Notification, error) {
+	stmt := `SELECT ` + notificationColumns + ` FROM notifications n JOIN users u ON n.actor_id = u.id
+	WHERE n.user_id = ? AND n.id > ? AND n.channel = 'in_app' ORDER BY n.id LIMIT 100`
+	rows, err := m.DB.Query(stmt, userID, afterID)
 	if err != nil {
 		return nil, err
 	}
@@ -51,24 +121,305 @@ func (m *NotificationModel) GetAll(userID int) ([]*Notification, error) {
 
 	var notifications []*Notification
 	for rows.Next() {
-		var n Notification
-		var isRead int
-		err := rows.Scan(
-			&n.ID, &n.Type, &n.PostID, &n.CommentID, &n.Created, &isRead,
-			&n.ActorID, &n.ActorName,
-		)
+		n, err := scanNotification(rows)
 		if err != nil {
 			return nil, err
 		}
-		n.IsRead = isRead == 1
-		notifications = append(notifications, &n)
+		notifications = append(notifications, n)
 	}
-	return notifications, nil
+	return notifications, rows.Err()
+}
+
+func (m *NotificationModel) GetUnreadCount(userID int) (int, error) {
+	var count int
+	stmt := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0 AND channel = 'in_app'`
+	err := m.DB.QueryRow(stmt, userID).Scan(&count)
+	return count, err
 }
 
 func (m *NotificationModel) MarkAllAsRead(userID int) error {
 	stmt := `UPDATE notifications SET is_read = 1 
-	WHERE user_id = ?`
+	WHERE user_id = ? AND channel = 'in_app'`
 	_, err := m.DB.Exec(stmt, userID)
+	if err == nil && m.Events != nil {
+		m.Events.Publish(userID, events.Event{Name: EventRead})
+	}
+	return err
+}
+
+// NotificationGroup — уведомления одного типа об одном посте или комментарии: "5 people liked your post".
+// Комментарии и ответы не склеиваются, у каждого свой comment_id.
+type NotificationGroup struct {
+	IDs       []int // все уведомления группы, новые первыми
+	Type      string
+	PostID    int
+	CommentID int
+	Actors    []string // до GroupActors имён, последние первыми
+	Others    int      // сколько ещё людей не попало в Actors
+	Unread    int
+	Created   time.Time // время последнего уведомления
+}
+
+// GroupActors — сколько имён показывать в группе
+const GroupActors = 3
+
+// LatestID — id последнего уведомления группы, им же размечаются страницы
+func (g *NotificationGroup) LatestID() int {
+	return g.IDs[0]
+}
+
+// Query возвращает id группы в виде "id=3&id=5" для ссылок и форм
+func (g *NotificationGroup) Query() string {
+	parts := make([]string, len(g.IDs))
+	for i, id := range g.IDs {
+		parts[i] = "id=" + strconv.Itoa(id)
+	}
+	return strings.Join(parts, "&")
+}
+
+// NotificationQuery — страница списка уведомлений
+type NotificationQuery struct {
+	Type     string // "" — все типы
+	Before   int    // только группы, последнее уведомление которых старше этого id; 0 — с начала
+	After    int    // только группы новее этого id (предыдущая страница); задаётся не больше одного
+	PageSize int
+}
+
+// NotificationPage — страница групп и курсоры соседних страниц (0, если листать некуда):
+// Next передаётся дальше как Before, Prev — как After
+type NotificationPage struct {
+	Groups []*NotificationGroup
+	Next   int
+	Prev   int
+}
+
+// Groups возвращает страницу сгруппированных уведомлений, новые группы первыми.
+// Страницы размечаются по id, поэтому новые уведомления не сдвигают уже открытые страницы.
+func (m *NotificationModel) Groups(userID int, q NotificationQuery) (*NotificationPage, error) {
+	if q.Type != "" && !slices.Contains(NotificationTypes, q.Type) {
+		return nil, ErrInvalidNotificationType
+	}
+	if q.PageSize < 1 {
+		q.PageSize = 20
+	}
+
+	where := `WHERE user_id = ? AND channel = 'in_app'`
+	args := []any{userID}
+	if q.Type != "" {
+		where += ` AND type = ?`
+		args = append(args, q.Type)
+	}
+	stmt := `SELECT type, post_id, comment_id, GROUP_CONCAT(id) FROM notifications ` + where + ` GROUP BY type, post_id, comment_id`
+	pageArgs := slices.Clone(args)
+	switch {
+	case q.After > 0:
+		// Предыдущая страница: ближайшие к курсору более новые группы, потом в обычном порядке
+		stmt += ` HAVING MAX(id) > ? ORDER BY MAX(id) ASC LIMIT ?`
+		pageArgs = append(pageArgs, q.After)
+	case q.Before > 0:
+		stmt += ` HAVING MAX(id) < ? ORDER BY MAX(id) DESC LIMIT ?`
+		pageArgs = append(pageArgs, q.Before)
+	default:
+		stmt += ` ORDER BY MAX(id) DESC LIMIT ?`
+	}
+	pageArgs = append(pageArgs, q.PageSize+1)
+
+	rows, err := m.DB.Query(stmt, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*NotificationGroup
+	var lists []string
+	for rows.Next() {
+		g := &NotificationGroup{}
+		var list string
+		if err := rows.Scan(&g.Type, &g.PostID, &g.CommentID, &list); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Лишняя группа из LIMIT означает, что в эту сторону есть ещё страница
+	more := len(groups) > q.PageSize
+	if more {
+		groups, lists = groups[:q.PageSize], lists[:q.PageSize]
+	}
+	if q.After > 0 {
+		slices.Reverse(groups)
+		slices.Reverse(lists)
+	}
+	page := &NotificationPage{Groups: groups}
+
+	byID := map[int]*NotificationGroup{}
+	var ids []any
+	for i, list := range lists {
+		for _, s := range strings.Split(list, ",") {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, err
+			}
+			byID[id] = groups[i]
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return page, nil
+	}
+
+	// Второй запрос достаёт сами уведомления: имена, время и признак прочтения
+	stmt = `SELECT ` + notificationColumns + ` FROM notifications n JOIN users u ON n.actor_id = u.id
+	WHERE n.id IN (` + placeholders(len(ids)) + `) ORDER BY n.id DESC`
+	rows, err = m.DB.Query(stmt, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[*NotificationGroup]map[int]bool{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		g := byID[n.ID]
+		if len(g.IDs) == 0 {
+			g.Created = n.Created
+			seen[g] = map[int]bool{}
+		}
+		g.IDs = append(g.IDs, n.ID)
+		if !n.IsRead {
+			g.Unread++
+		}
+		if seen[g][n.ActorID] {
+			continue
+		}
+		seen[g][n.ActorID] = true
+		if len(g.Actors) < GroupActors {
+			g.Actors = append(g.Actors, n.ActorName)
+		} else {
+			g.Others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	newest, oldest := groups[0].LatestID(), groups[len(groups)-1].LatestID()
+	switch {
+	case q.After > 0:
+		// Сюда пришли со страницы более старых групп
+		page.Next = oldest
+		if more {
+			page.Prev = newest
+		}
+	default:
+		if more {
+			page.Next = oldest
+		}
+		// Новая группа всегда содержит самое новое уведомление, так что хватает MAX(id)
+		if q.Before > 0 {
+			var latest int
+			if err := m.DB.QueryRow(`SELECT IFNULL(MAX(id), 0) FROM notifications `+where, args...).Scan(&latest); err != nil {
+				return nil, err
+			}
+			if latest > newest {
+				page.Prev = newest
+			}
+		}
+	}
+	return page, nil
+}
+
+// SetRead помечает уведомления пользователя прочитанными или непрочитанными.
+// ErrNoRecord — ни одно из ids ему не принадлежит.
+func (m *NotificationModel) SetRead(userID int, ids []int, read bool) error {
+	stmt := `UPDATE notifications SET is_read = 0`
+	if read {
+		stmt = `UPDATE notifications SET is_read = 1`
+	}
+	return m.update(userID, ids, stmt)
+}
+
+// Delete удаляет уведомления пользователя; ErrNoRecord — ни одно из ids ему не принадлежит
+func (m *NotificationModel) Delete(userID int, ids []int) error {
+	return m.update(userID, ids, `DELETE FROM notifications`)
+}
+
+// update выполняет stmt для ids, ограничивая его уведомлениями userID, и обновляет счётчики в открытых вкладках
+func (m *NotificationModel) update(userID int, ids []int, stmt string) error {
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return ErrNoRecord
+	}
+	args := []any{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	result, err := m.DB.Exec(stmt+` WHERE user_id = ? AND channel = 'in_app' AND id IN (`+placeholders(len(ids))+`)`, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	if m.Events != nil {
+		m.Events.Publish(userID, events.Event{Name: EventRead})
+	}
+	return nil
+}
+
+// Prune удаляет прочитанные уведомления старше before и возвращает, сколько удалено
+func (m *NotificationModel) Prune(before time.Time) (int64, error) {
+	stmt := `DELETE FROM notifications WHERE is_read = 1 AND created < ?`
+	result, err := m.DB.Exec(stmt, sqliteTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DigestQueue возвращает уведомления, ждущие email-дайджеста, по получателям, старые первыми
+func (m *NotificationModel) DigestQueue() (map[int][]*Notification, error) {
+	stmt := `SELECT ` + notificationColumns + ` FROM notifications n JOIN users u ON n.actor_id = u.id
+	WHERE n.channel = 'email' AND n.is_read = 0 ORDER BY n.user_id, n.id`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queue := map[int][]*Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		queue[n.UserID] = append(queue[n.UserID], n)
+	}
+	return queue, rows.Err()
+}
+
+// MarkDigested отмечает уведомления отправленными в дайджесте; дальше их удалит Prune
+func (m *NotificationModel) MarkDigested(ids []int) error {
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	stmt := `UPDATE notifications SET is_read = 1 WHERE channel = 'email' AND id IN (` + placeholders(len(ids)) + `)`
+	_, err := m.DB.Exec(stmt, args...)
 	return err
 }
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// После стольких неудачных попыток письмо больше не отправляется и остаётся в таблице для разбора
+const MaxOutboxAttempts = 8
+
+// OutboxEmail — письмо в очереди на отправку
+type OutboxEmail struct {
+	ID        int
+	To        string
+	Subject   string
+	Text      string
+	HTML      string
+	Attempts  int
+	LastError string
+}
+
+// OutboxModel — очередь писем: обработчики кладут письма, фоновая отправка забирает и повторяет неудачные
+type OutboxModel struct {
+	DB *sql.DB
+}
+
+// sqliteTime переводит время в формат CURRENT_TIMESTAMP, чтобы сравнивать его со столбцами DATETIME строкой
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// Enqueue ставит письмо в очередь; оно уйдёт при ближайшем проходе отправки
+func (m *OutboxModel) Enqueue(to, subject, text, html string) (int, error) {
+	stmt := `INSERT INTO email_outbox (recipient, subject, text_body, html_body) VALUES (?, ?, ?, ?)`
+	result, err := m.DB.Exec(stmt, to, subject, text, html)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Due возвращает до limit неотправленных писем, время очередной попытки которых наступило
+func (m *OutboxModel) Due(now time.Time, limit int) ([]*OutboxEmail, error) {
+	stmt := `SELECT id, recipient, subject, text_body, html_body, attempts, last_error FROM email_outbox
+	WHERE sent IS NULL AND attempts < ? AND next_attempt <= ? ORDER BY next_attempt, id LIMIT ?`
+	rows, err := m.DB.Query(stmt, MaxOutboxAttempts, sqliteTime(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []*OutboxEmail
+	for rows.Next() {
+		e := &OutboxEmail{}
+		if err := rows.Scan(&e.ID, &e.To, &e.Subject, &e.Text, &e.HTML, &e.Attempts, &e.LastError); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// MarkSent отмечает письмо отправленным и стирает его текст: в письмах бывают ссылки для сброса
+// пароля и подтверждения адреса, и хранить их после отправки незачем
+func (m *OutboxModel) MarkSent(id int, now time.Time) error {
+	stmt := `UPDATE email_outbox SET sent = ?, attempts = attempts + 1, last_error = '', text_body = '', html_body = '' WHERE id = ?`
+	_, err := m.DB.Exec(stmt, sqliteTime(now), id)
+	return err
+}
+
+// PruneSent удаляет письма, отправленные раньше before
+func (m *OutboxModel) PruneSent(before time.Time) (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM email_outbox WHERE sent < ?`, sqliteTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MarkFailed записывает неудачную попытку и откладывает следующую по OutboxBackoff
+func (m *OutboxModel) MarkFailed(id int, sendErr error, now time.Time) error {
+	var attempts int
+	if err := m.DB.QueryRow(`SELECT attempts FROM email_outbox WHERE id = ?`, id).Scan(&attempts); err != nil {
+		return err
+	}
+	stmt := `UPDATE email_outbox SET attempts = attempts + 1, last_error = ?, next_attempt = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, sendErr.Error(), sqliteTime(now.Add(OutboxBackoff(attempts+1))), id)
+	return err
+}
+
+// OutboxBackoff — пауза после attempts неудачных попыток: минута, две, четыре... но не больше 6 часов
+func OutboxBackoff(attempts int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempts && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, 6*time.Hour)
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Режимы сортировки лент постов
+const (
+	SortNewest        = "newest"
+	SortLiked         = "liked"
+	SortCommented     = "commented"
+	SortControversial = "controversial"
+	SortHot           = "hot"
+)
+
+// PostSorts — все режимы в порядке вывода в интерфейсе
+var PostSorts = []string{SortNewest, SortHot, SortLiked, SortCommented, SortControversial}
+
+var (
+	ErrInvalidSort   = errors.New("models: invalid sort")
+	ErrInvalidCursor = errors.New("models: invalid cursor")
+)
+
+// cursorTimeLayout совпадает с форматом DATETIME('now', 'localtime'), которым пишется posts.created
+const cursorTimeLayout = "2006-01-02 15:04:05"
+
+// hotAge — возраст поста в часах на момент ? (момент фиксируется на первой странице)
+const hotAge = `((julianday(?) - julianday(posts.created)) * 24 + 2)`
+
+// postSortKeys — выражение, по убыванию которого идёт лента; при равенстве выше более новый id.
+// controversial растёт с числом голосов и тем сильнее, чем ближе лайки к дизлайкам;
+// hot — рейтинг, который затухает пропорционально квадрату возраста.
+var postSortKeys = map[string]string{
+	SortNewest:    `julianday(posts.created)`,
+	SortLiked:     `posts.likes`,
+	SortCommented: `(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id)`,
+	SortControversial: `CASE WHEN posts.likes = 0 OR posts.dislikes = 0 THEN 0.0
+		ELSE (posts.likes + posts.dislikes) * MIN(posts.likes, posts.dislikes) * 1.0 / MAX(posts.likes, posts.dislikes) END`,
+	SortHot: `(posts.likes - posts.dislikes) * 1.0 / (` + hotAge + ` * ` + hotAge + `)`,
+}
+
+// ValidPostSort сообщает, поддерживается ли режим сортировки
+func ValidPostSort(sort string) bool {
+	_, ok := postSortKeys[sort]
+	return ok
+}
+
+// Cursor — позиция в ленте: ключ сортировки и id крайнего поста страницы
+type Cursor struct {
+	Sort string  `json:"s"`
+	Key  float64 `json:"k"`
+	ID   int     `json:"i"`
+	// AsOf — момент, от которого считается hot; не меняется при листании, чтобы посты не перескакивали между страницами
+	AsOf string `json:"t"`
+}
+
+// Encode упаковывает курсор в строку для query string
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor разбирает строку из Encode
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 || !ValidPostSort(c.Sort) {
+		return c, ErrInvalidCursor
+	}
+	if _, err := time.Parse(cursorTimeLayout, c.AsOf); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// PostQuery — параметры любой ленты постов
+type PostQuery struct {
+	Categories     CategoryFilter
+	Personal       PersonalFilter
+	AuthorID       int  // 0 — посты всех авторов
+	IncludePending bool // иначе только одобренные
+	Sort           string
+	// After и Before — курсоры из PostPage; задаётся не больше одного
+	After  string
+	Before string
+	Offset int // для постраничного API без курсоров
+	Limit  int
+}
+
+// PostPage — страница ленты и курсоры соседних страниц (пустые, если листать некуда)
+type PostPage struct {
+	Posts []*Post
+	Sort  string
+	Next  string
+	Prev  string
+}
+
+// where собирает условия отбора постов без учёта курсора
+func (q PostQuery) where() (string, []any) {
+	cond, args := q.Categories.where()
+	personal, personalArgs := q.Personal.where()
+	conds := []string{cond, personal}
+	args = append(args, personalArgs...)
+	if !q.IncludePending {
+		conds = append(conds, `posts.status = 'approved'`)
+	}
+	if q.AuthorID != 0 {
+		conds = append(conds, `posts.author_id = ?`)
+		args = append(args, q.AuthorID)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Page возвращает страницу ленты с keyset-пагинацией: следующая страница начинается строго после
+// ключа последнего поста, поэтому новые посты и голоса не сдвигают и не дублируют выдачу.
+func (m *PostModel) Page(q PostQuery) (*PostPage, error) {
+	sortName := q.Sort
+	if sortName == "" {
+		sortName = SortNewest
+	}
+	key, ok := postSortKeys[sortName]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	var cursor *Cursor
+	backward := false
+	for _, s := range []string{q.After, q.Before} {
+		if s == "" {
+			continue
+		}
+		if cursor != nil {
+			return nil, ErrInvalidCursor
+		}
+		c, err := DecodeCursor(s)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != sortName {
+			return nil, ErrInvalidCursor
+		}
+		cursor = &c
+		backward = s == q.Before
+	}
+
+	asOf := time.Now().Format(cursorTimeLayout)
+	if cursor != nil {
+		asOf = cursor.AsOf
+	}
+
+	var args []any
+	for i := 0; i < strings.Count(key, "?"); i++ {
+		args = append(args, asOf)
+	}
+	cond, condArgs := q.where()
+	args = append(args, condArgs...)
+
+	stmt := `SELECT id, title, content, image_path, likes, dislikes, author, author_id, created, status, sort_key FROM (
+	SELECT posts.*, ` + key + ` AS sort_key FROM posts WHERE ` + cond + `)`
+	offset := q.Offset
+	switch {
+	case cursor == nil:
+		stmt += ` ORDER BY sort_key DESC, id DESC`
+	case backward:
+		stmt += ` WHERE sort_key > ? OR (sort_key = ? AND id > ?) ORDER BY sort_key ASC, id ASC`
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+		offset = 0
+	default:
+		stmt += ` WHERE sort_key < ? OR (sort_key = ? AND id < ?) ORDER BY sort_key DESC, id DESC`
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+		offset = 0
+	}
+	// Лишняя строка показывает, есть ли ещё одна страница
+	stmt += ` LIMIT ? OFFSET ?`
+	args = append(args, q.Limit+1, offset)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*Post
+	var keys []float64
+	for rows.Next() {
+		p := &Post{}
+		var k float64
+		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.ImagePath, &p.Likes, &p.Dislikes, &p.Author, &p.AuthorID, &p.Created, &p.Status, &k)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+		keys = append(keys, k)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(posts) > q.Limit
+	if more {
+		posts, keys = posts[:q.Limit], keys[:q.Limit]
+	}
+	if backward {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	page := &PostPage{Posts: posts, Sort: sortName}
+	if n := len(posts); n > 0 {
+		first := Cursor{Sort: sortName, Key: keys[0], ID: posts[0].ID, AsOf: asOf}
+		last := Cursor{Sort: sortName, Key: keys[n-1], ID: posts[n-1].ID, AsOf: asOf}
+		if backward {
+			// Назад листали от существующей страницы, значит вперёд всегда есть куда
+			page.Next = last.Encode()
+			if more {
+				page.Prev = first.Encode()
+			}
+		} else {
+			if more {
+				page.Next = last.Encode()
+			}
+			if cursor != nil || offset > 0 {
+				page.Prev = first.Encode()
+			}
+		}
+	}
+
+	return page, m.attachCategories(posts)
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("models: invalid or expired password reset token")
+
+// PasswordResetModel хранит одноразовые токены сброса пароля. В базе лежит только хеш токена,
+// так что утечка таблицы не даёт сбросить чужой пароль.
+type PasswordResetModel struct {
+	DB *sql.DB
+}
+
+// normalizeEmail приводит адрес к виду, по которому считается лимит запросов
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// AllowRequest записывает запрос сброса для email, если за window их было меньше limit.
+// Считаются и несуществующие адреса, поэтому по лимиту нельзя узнать, зарегистрирован ли адрес.
+func (m *PasswordResetModel) AllowRequest(email string, limit int, window time.Duration, now time.Time) (bool, error) {
+	email = normalizeEmail(email)
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Заодно чистим старые записи: они больше ни на что не влияют
+	if _, err := tx.Exec(`DELETE FROM password_reset_requests WHERE created < ?`, sqliteTime(now.Add(-window))); err != nil {
+		return false, err
+	}
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM password_reset_requests WHERE email = ?`, email).Scan(&count); err != nil {
+		return false, err
+	}
+	if count >= limit {
+		return false, tx.Commit()
+	}
+	if _, err := tx.Exec(`INSERT INTO password_reset_requests (email, created) VALUES (?, ?)`, email, sqliteTime(now)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Create выдаёт пользователю новый токен, действующий ttl, и возвращает его в открытом виде.
+// Прежние токены пользователя остаются в силе до срока или до сброса.
+func (m *PasswordResetModel) Create(userID int, ttl time.Duration, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if _, err := m.DB.Exec(`DELETE FROM password_resets WHERE expiry <= ?`, sqliteTime(now)); err != nil {
+		return "", err
+	}
+	stmt := `INSERT INTO password_resets (user_id, token_hash, created, expiry) VALUES (?, ?, ?, ?)`
+	_, err := m.DB.Exec(stmt, userID, hashToken(token), sqliteTime(now), sqliteTime(now.Add(ttl)))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UserID возвращает владельца действующего токена или ErrInvalidResetToken
+func (m *PasswordResetModel) UserID(token string, now time.Time) (int, error) {
+	var userID int
+	stmt := `SELECT user_id FROM password_resets WHERE token_hash = ? AND expiry > ?`
+	err := m.DB.QueryRow(stmt, hashToken(token), sqliteTime(now)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidResetToken
+	}
+	return userID, err
+}
+
+// Reset меняет пароль владельца токена и удаляет все его токены сброса — ссылка срабатывает один раз.
+// Возвращает id пользователя, чтобы вызывающий завершил его сессии.
+func (m *PasswordResetModel) Reset(token, hashedPassword string, now time.Time) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Удаление первым шагом берёт блокировку на запись: два одновременных сброса по одной ссылке
+	// не пройдут оба
+	var userID int
+	stmt := `DELETE FROM password_resets WHERE token_hash = ? AND expiry > ? RETURNING user_id`
+	err = tx.QueryRow(stmt, hashToken(token), sqliteTime(now)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidResetToken
+	} else if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM password_resets WHERE user_id = ?`, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET hashed_password = ? WHERE id = ?`, hashedPassword, userID); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
@@ -3,22 +3,149 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Post структура для хранения данных поста
 type Post struct {
-	ID        int
-	Title     string
-	Content   string
-	ImagePath string
-	Category  string
-	Likes     int
-	Dislikes  int
-	Author    string
-	AuthorID  int
-	Created   time.Time
-	Status    string
+	ID         int         `json:"id"`
+	Title      string      `json:"title"`
+	Content    string      `json:"content"`
+	ImagePath  string      `json:"image_path"`
+	Categories []*Category `json:"categories"`
+	Likes      int         `json:"likes"`
+	Dislikes   int         `json:"dislikes"`
+	Author     string      `json:"author"`
+	AuthorID   int         `json:"author_id"`
+	Created    time.Time   `json:"created"`
+	Status     string      `json:"status"`
+	// Reactions заполняется только там, где показываются кнопки реакций
+	Reactions []ReactionCount `json:"reactions,omitempty"`
+}
+
+// PostRevision — версия поста до очередной правки
+type PostRevision struct {
+	ID          int         `json:"id"`
+	PostID      int         `json:"post_id"`
+	Title       string      `json:"title"`
+	Content     string      `json:"content"`
+	ImagePath   string      `json:"image_path"`
+	CategoryIDs []int       `json:"category_ids"`
+	Categories  []*Category `json:"categories"` // только те, что ещё существуют
+	EditorID    int         `json:"editor_id"`
+	Editor      string      `json:"editor"`
+	Created     time.Time   `json:"created"` // когда версия была заменена
+}
+
+// CategoryIDs возвращает id категорий поста в порядке их вывода
+func (p *Post) CategoryIDs() []int {
+	ids := make([]int, len(p.Categories))
+	for i, c := range p.Categories {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// InCategory нужен шаблонам, чтобы отметить выбранные категории в форме
+func (p *Post) InCategory(id int) bool {
+	for _, c := range p.Categories {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoryFilter отбирает посты по категориям: хотя бы одна из IDs или, при MatchAll, все сразу
+type CategoryFilter struct {
+	IDs      []int
+	MatchAll bool
+}
+
+// Has сообщает, выбрана ли категория в фильтре
+func (f CategoryFilter) Has(id int) bool {
+	for _, selected := range f.IDs {
+		if selected == id {
+			return true
+		}
+	}
+	return false
+}
+
+// where возвращает условие на posts.id; пустой фильтр пропускает все посты
+func (f CategoryFilter) where() (string, []any) {
+	ids := uniqueIDs(f.IDs)
+	if len(ids) == 0 {
+		return "1 = 1", nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	cond := `posts.id IN (SELECT post_id FROM post_categories WHERE category_id IN (` + placeholders(len(ids)) + `)`
+	if f.MatchAll {
+		cond += ` GROUP BY post_id HAVING COUNT(*) = ?`
+		args = append(args, len(ids))
+	}
+	return cond + `)`, args
+}
+
+// PersonalFilter отбирает посты относительно пользователя UserID; выбранные условия объединяются через AND
+type PersonalFilter struct {
+	UserID    int
+	Mine      bool // созданные пользователем
+	Liked     bool
+	Disliked  bool
+	Commented bool // пользователь оставил хотя бы один комментарий
+}
+
+// Active сообщает, выбрано ли хоть одно условие
+func (f PersonalFilter) Active() bool {
+	return f.Mine || f.Liked || f.Disliked || f.Commented
+}
+
+// where возвращает условие на posts.id; без пользователя фильтр ничего не отбирает
+func (f PersonalFilter) where() (string, []any) {
+	if !f.Active() || f.UserID == 0 {
+		return "1 = 1", nil
+	}
+
+	var conds []string
+	var args []any
+	if f.Mine {
+		conds = append(conds, `posts.author_id = ?`)
+		args = append(args, f.UserID)
+	}
+	if f.Liked {
+		conds = append(conds, `posts.id IN (SELECT target_id FROM reactions WHERE target_type = 'post' AND kind = 'like' AND user_id = ?)`)
+		args = append(args, f.UserID)
+	}
+	if f.Disliked {
+		conds = append(conds, `posts.id IN (SELECT target_id FROM reactions WHERE target_type = 'post' AND kind = 'dislike' AND user_id = ?)`)
+		args = append(args, f.UserID)
+	}
+	if f.Commented {
+		conds = append(conds, `posts.id IN (SELECT post_id FROM comments WHERE user_id = ?)`)
+		args = append(args, f.UserID)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+const postRevisionColumns = `r.id, r.post_id, r.title, r.content, r.image_path, r.category_ids, r.editor_id, IFNULL(u.name, ''), r.created`
+
+func scanPostRevision(row rowScanner) (*PostRevision, error) {
+	rev := &PostRevision{}
+	var categoryIDs string
+	err := row.Scan(&rev.ID, &rev.PostID, &rev.Title, &rev.Content, &rev.ImagePath, &categoryIDs, &rev.EditorID, &rev.Editor, &rev.Created)
+	if err != nil {
+		return nil, err
+	}
+	rev.CategoryIDs = parseIDs(categoryIDs)
+	return rev, nil
 }
 
 // PostModel обёртка для соединения с базой данных
@@ -26,25 +153,18 @@ type PostModel struct {
 	DB *sql.DB
 }
 
-// Insert добавляет новый пост в базу данных
-func (m *PostModel) Insert(title, content, imagePath, category, author, status string, author_id int) (int, error) {
-	// Категория и автор могут быть заданы по умолчанию
-	// defaultCategory := "Uncategorized"
-	// defaultAuthor := "Anonymous"
-	stmt := `INSERT INTO posts (title, content, image_path, category, author, author_id, created, status) 
-         VALUES (?, ?, ?, ?, ?, ?, DATETIME('now', 'localtime'), ?)` // 8 параметров!
+// Insert добавляет новый пост в базу данных вместе с его категориями
+func (m *PostModel) Insert(title, content, imagePath, author, status string, author_id int, categoryIDs []int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	result, err := m.DB.Exec(
-		stmt,
-		title,     // 1
-		content,   // 2
-		imagePath, // 3
-		category,  // 4
-		author,    // 5
-		author_id, // 6
-		status,    // 8 (последний параметр)
-	)
+	stmt := `INSERT INTO posts (title, content, image_path, author, author_id, created, status) 
+         VALUES (?, ?, ?, ?, ?, DATETIME('now', 'localtime'), ?)`
 
+	result, err := tx.Exec(stmt, title, content, imagePath, author, author_id, status)
 	if err != nil {
 		return 0, err
 	}
@@ -54,17 +174,21 @@ func (m *PostModel) Insert(title, content, imagePath, category, author, status s
 		return 0, err
 	}
 
-	return int(id), nil
+	if err := setCategories(tx, int(id), categoryIDs); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
 // Get возвращает пост по ID
 func (m *PostModel) Get(id int) (*Post, error) {
-	stmt := `SELECT id, title, content, image_path, category, likes, dislikes, author, author_id, created, status FROM posts WHERE id = ?`
+	stmt := `SELECT id, title, content, image_path, likes, dislikes, author, author_id, created, status FROM posts WHERE id = ?`
 
 	row := m.DB.QueryRow(stmt, id)
 
 	p := &Post{}
-	err := row.Scan(&p.ID, &p.Title, &p.Content, &p.ImagePath, &p.Category, &p.Likes, &p.Dislikes, &p.Author, &p.AuthorID, &p.Created, &p.Status)
+	err := row.Scan(&p.ID, &p.Title, &p.Content, &p.ImagePath, &p.Likes, &p.Dislikes, &p.Author, &p.AuthorID, &p.Created, &p.Status)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -72,74 +196,219 @@ func (m *PostModel) Get(id int) (*Post, error) {
 		return nil, err
 	}
 
+	if err := m.attachCategories([]*Post{p}); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
-// Latest возвращает 10 последних постов
-func (m *PostModel) Latest() ([]*Post, error) {
-	stmt := `SELECT id, title, content, image_path,  category, author, author_id, created FROM posts WHERE status = "approved" ORDER BY created DESC LIMIT 10`
+func (m *PostModel) UpdatePost(title, content, imagePath, author string, author_id, id, editorID int, categoryIDs []int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	rows, err := m.DB.Query(stmt)
+	if err := saveRevision(tx, id, editorID, title, content, imagePath, categoryIDs); err != nil {
+		return err
+	}
+
+	stmt := `UPDATE posts SET title = ?, content = ?, image_path = ?, author = ?, author_id = ? WHERE id = ?`
+	_, err = tx.Exec(stmt, title, content, imagePath, author, author_id, id)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	if err := setCategories(tx, id, categoryIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	var posts []*Post
+// saveRevision записывает текущую версию поста, если новая от неё отличается
+func saveRevision(tx *sql.Tx, postID, editorID int, title, content, imagePath string, categoryIDs []int) error {
+	old := &PostRevision{}
+	stmt := `SELECT title, content, image_path FROM posts WHERE id = ?`
+	err := tx.QueryRow(stmt, postID).Scan(&old.Title, &old.Content, &old.ImagePath)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
 
+	rows, err := tx.Query(`SELECT category_id FROM post_categories WHERE post_id = ? ORDER BY category_id`, postID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 	for rows.Next() {
-		p := &Post{}
-		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.ImagePath, &p.Category, &p.Author, &p.AuthorID, &p.Created)
-		if err != nil {
-			return nil, err
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
 		}
-		posts = append(posts, p)
+		old.CategoryIDs = append(old.CategoryIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if old.Title == title && old.Content == content && old.ImagePath == imagePath && formatIDs(old.CategoryIDs) == formatIDs(categoryIDs) {
+		return nil
+	}
+
+	stmt = `INSERT INTO post_revisions (post_id, title, content, image_path, category_ids, editor_id, created)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.Exec(stmt, postID, old.Title, old.Content, old.ImagePath, formatIDs(old.CategoryIDs), editorID, time.Now().UTC())
+	return err
+}
+
+// setCategories заменяет категории поста; несуществующие id пропускаются
+func setCategories(tx *sql.Tx, postID int, categoryIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM post_categories WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	stmt := `INSERT INTO post_categories (post_id, category_id) SELECT ?, id FROM categories WHERE id = ?`
+	for _, id := range uniqueIDs(categoryIDs) {
+		if _, err := tx.Exec(stmt, postID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachCategories одним запросом заполняет Categories у списка постов
+func (m *PostModel) attachCategories(posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
 	}
 
-	return posts, nil
+	byID := make(map[int]*Post, len(posts))
+	args := make([]any, 0, len(posts))
+	for _, p := range posts {
+		p.Categories = []*Category{}
+		byID[p.ID] = p
+		args = append(args, p.ID)
+	}
+
+	stmt := `SELECT pc.post_id, c.id, c.name FROM post_categories pc JOIN categories c ON c.id = pc.category_id
+	WHERE pc.post_id IN (` + placeholders(len(args)) + `) ORDER BY c.name`
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		c := &Category{}
+		if err := rows.Scan(&postID, &c.ID, &c.Name); err != nil {
+			return err
+		}
+		if p, ok := byID[postID]; ok {
+			p.Categories = append(p.Categories, c)
+		}
+	}
+	return rows.Err()
 }
-func (m *PostModel) UserPosts(userId int) ([]*Post, error) {
-	stmt := `SELECT id, title, content, image_path,  category, author, author_id,  created FROM posts WHERE author_id = ?`
 
-	rows, err := m.DB.Query(stmt, userId)
+// Revisions возвращает прежние версии поста, самые свежие первыми
+func (m *PostModel) Revisions(postID int) ([]*PostRevision, error) {
+	stmt := `SELECT ` + postRevisionColumns + ` FROM post_revisions r LEFT JOIN users u ON u.id = r.editor_id
+	WHERE r.post_id = ? ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, postID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []*Post
-
+	var revisions []*PostRevision
 	for rows.Next() {
-		p := &Post{}
-		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.ImagePath, &p.Category, &p.Author, &p.AuthorID, &p.Created)
+		rev, err := scanPostRevision(rows)
 		if err != nil {
 			return nil, err
 		}
-		posts = append(posts, p)
+		revisions = append(revisions, rev)
 	}
-
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-
-	return posts, nil
+	return revisions, m.resolveRevisionCategories(revisions...)
 }
-func (m *PostModel) UpdatePost(title, content, imagePath, category, author string, author_id, id int) error {
-	// Категория и автор могут быть заданы по умолчанию
-	// defaultCategory := "Uncategorized"
-	// defaultAuthor := "Anonymous"
-	stmt := `UPDATE posts SET title = ?, content = ?, image_path = ?, category = ?, author = ?, author_id = ? WHERE id = ?`
 
-	_, err := m.DB.Exec(stmt, title, content, imagePath, category, author, author_id, id)
+// resolveRevisionCategories подставляет текущие названия категорий в ревизии
+func (m *PostModel) resolveRevisionCategories(revisions ...*PostRevision) error {
+	categories, err := (&CategoryModel{DB: m.DB}).GetAll()
 	if err != nil {
 		return err
 	}
+	byID := make(map[int]*Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	for _, rev := range revisions {
+		rev.Categories = []*Category{}
+		for _, id := range rev.CategoryIDs {
+			if c, ok := byID[id]; ok {
+				rev.Categories = append(rev.Categories, c)
+			}
+		}
+	}
 	return nil
 }
+
+func (m *PostModel) GetRevision(id int) (*PostRevision, error) {
+	stmt := `SELECT ` + postRevisionColumns + ` FROM post_revisions r LEFT JOIN users u ON u.id = r.editor_id
+	WHERE r.id = ?`
+
+	rev, err := scanPostRevision(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return rev, m.resolveRevisionCategories(rev)
+}
+
+// RestoreRevision возвращает пост к сохранённой версии; текущая при этом сама становится ревизией,
+// так что откат тоже можно отменить
+func (m *PostModel) RestoreRevision(revisionID, editorID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rev := &PostRevision{}
+	var categoryIDs string
+	stmt := `SELECT post_id, title, content, image_path, category_ids FROM post_revisions WHERE id = ?`
+	err = tx.QueryRow(stmt, revisionID).Scan(&rev.PostID, &rev.Title, &rev.Content, &rev.ImagePath, &categoryIDs)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	rev.CategoryIDs = parseIDs(categoryIDs)
+
+	if err := saveRevision(tx, rev.PostID, editorID, rev.Title, rev.Content, rev.ImagePath, rev.CategoryIDs); err != nil {
+		return err
+	}
+
+	stmt = `UPDATE posts SET title = ?, content = ?, image_path = ? WHERE id = ?`
+	_, err = tx.Exec(stmt, rev.Title, rev.Content, rev.ImagePath, rev.PostID)
+	if err != nil {
+		return err
+	}
+	// Удалённые с тех пор категории просто пропадут
+	if err := setCategories(tx, rev.PostID, rev.CategoryIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (m *PostModel) DeletePost(id int) (string, error) {
 	stmt1 := `SELECT image_path FROM posts WHERE id = ?`
 	stmt2 := `DELETE FROM posts WHERE id = ?`
@@ -155,30 +424,6 @@ func (m *PostModel) DeletePost(id int) (string, error) {
 	return imagePath, nil
 }
 
-func (m *PostModel) SortByCategory(category string) ([]*Post, error) {
-	stmt := `SELECT id, title, content, image_path, category, created, author, author_id FROM posts WHERE category = ? AND status = "approved"`
-	rows, err := m.DB.Query(stmt, category)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var posts []*Post
-	for rows.Next() {
-		post := &Post{}
-		err = rows.Scan(&post.ID, &post.Title, &post.Content, &post.ImagePath, &post.Category, &post.Created, &post.Author, &post.AuthorID)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return posts, nil
-}
-
 func (m *PostModel) GetPendingPosts() ([]*Post, error) {
 	stmt := `SELECT id, title, content, author, created 
              FROM posts 
@@ -205,10 +450,57 @@ func (m *PostModel) GetPendingPosts() ([]*Post, error) {
 		return nil, err
 	}
 
-	return posts, nil
+	return posts, m.attachCategories(posts)
 }
 
 func (m *PostModel) ApprovePost(postID int) error {
 	_, err := m.DB.Exec("UPDATE posts SET status = 'approved' WHERE id = ?", postID)
 	return err
 }
+
+// Count возвращает число одобренных постов для метаданных пагинации
+func (m *PostModel) Count(filter CategoryFilter) (int, error) {
+	cond, args := filter.where()
+	var count int
+	stmt := `SELECT COUNT(*) FROM posts WHERE status = 'approved' AND ` + cond
+	err := m.DB.QueryRow(stmt, args...).Scan(&count)
+	return count, err
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// uniqueIDs убирает повторы и нули, сохраняя порядок
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var out []int
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// formatIDs и parseIDs переводят список id в строку "1,4" и обратно
+func formatIDs(ids []int) string {
+	ids = uniqueIDs(ids)
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseIDs(s string) []int {
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -2,207 +2,361 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
-type ReactionModel struct {
-	DB *sql.DB
+// Цели реакций
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// Реакции, на которых держатся счётчики likes/dislikes и старые маршруты /like и /dislike
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+var ErrInvalidReaction = errors.New("models: invalid reaction")
+
+// ReactionKind — вид реакции и эмодзи, которым он показывается
+type ReactionKind struct {
+	Name  string `json:"name"`
+	Emoji string `json:"emoji"`
 }
 
-func (m *ReactionModel) isLiked(postID, userID int) (bool, error) {
-	var exists bool
-	stmt := `SELECT EXISTS(SELECT 1 FROM post_likes WHERE post_id = ? AND user_id = ?)`
-	err := m.DB.QueryRow(stmt, postID, userID).Scan(&exists)
-	return exists, err
+// DefaultReactionKinds используются, если набор не задан флагом -reactions
+var DefaultReactionKinds = []ReactionKind{
+	{ReactionLike, "👍"},
+	{ReactionDislike, "👎"},
+	{"love", "❤️"},
+	{"laugh", "😂"},
+	{"wow", "😮"},
+	{"sad", "😢"},
 }
 
-func (m *ReactionModel) isDisliked(postID, userID int) (bool, error) {
-	var exists bool
-	stmt := `SELECT EXISTS(SELECT 1 FROM post_dislikes WHERE post_id = ? AND user_id = ?)`
-	err := m.DB.QueryRow(stmt, postID, userID).Scan(&exists)
-	return exists, err
+// ParseReactionKinds разбирает набор вида "like:👍,dislike:👎,love:❤️".
+// like и dislike обязательны: на них держатся счётчики и старые маршруты.
+func ParseReactionKinds(s string) ([]ReactionKind, error) {
+	var kinds []ReactionKind
+	seen := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		name, emoji, ok := strings.Cut(strings.TrimSpace(item), ":")
+		name, emoji = strings.TrimSpace(name), strings.TrimSpace(emoji)
+		if !ok || emoji == "" || !validKindName(name) {
+			return nil, fmt.Errorf("invalid reaction %q (want name:emoji)", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate reaction %q", name)
+		}
+		seen[name] = true
+		kinds = append(kinds, ReactionKind{Name: name, Emoji: emoji})
+	}
+	if !seen[ReactionLike] || !seen[ReactionDislike] {
+		return nil, errors.New("reactions must include like and dislike")
+	}
+	return kinds, nil
 }
 
-func (m *ReactionModel) LikePost(postID, userID int) error {
-	liked, err := m.isLiked(postID, userID)
-	if err != nil {
-		return err
+func validKindName(name string) bool {
+	if name == "" || len(name) > 20 {
+		return false
 	}
-	disliked, err := m.isDisliked(postID, userID)
-	if err != nil {
-		return err
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
 	}
+	return true
+}
+
+// ReactionCount — сколько реакций одного вида у поста или комментария
+type ReactionCount struct {
+	ReactionKind
+	Count int  `json:"count"`
+	Mine  bool `json:"mine"` // реакция текущего пользователя
+}
 
-	if liked {
-		return m.RemoveLikePost(postID, userID)
+// Reactor — кто и как отреагировал
+type Reactor struct {
+	UserID  int       `json:"user_id"`
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	Emoji   string    `json:"emoji"`
+	Created time.Time `json:"created"`
+}
+
+// ReactionModel хранит реакции в таблице reactions; счётчики likes/dislikes у постов и комментариев
+// обновляют триггеры в той же транзакции, поэтому параллельные клики их не портят
+type ReactionModel struct {
+	DB    *sql.DB
+	Kinds []ReactionKind // пусто — DefaultReactionKinds
+}
+
+// AllKinds возвращает настроенный набор реакций в порядке вывода
+func (m *ReactionModel) AllKinds() []ReactionKind {
+	if len(m.Kinds) == 0 {
+		return DefaultReactionKinds
 	}
+	return m.Kinds
+}
 
-	if disliked {
-		if err := m.RemoveDislikePost(postID, userID); err != nil {
-			return err
+// Kind ищет вид реакции по имени
+func (m *ReactionModel) Kind(name string) (ReactionKind, bool) {
+	for _, k := range m.AllKinds() {
+		if k.Name == name {
+			return k, true
 		}
 	}
+	return ReactionKind{}, false
+}
+
+func (m *ReactionModel) valid(targetType, kind string) bool {
+	_, ok := m.Kind(kind)
+	return ok && (targetType == TargetPost || targetType == TargetComment)
+}
+
+// Toggle ставит реакцию kind; если она уже стоит — снимает, если стоит другая — заменяет.
+// Возвращает реакцию пользователя после операции ("" — реакции нет).
+func (m *ReactionModel) Toggle(targetType string, targetID, userID int, kind string) (string, error) {
+	if !m.valid(targetType, kind) {
+		return "", ErrInvalidReaction
+	}
 
-	stmt := `INSERT INTO post_likes (post_id, user_id) VALUES (?, ?)`
-	_, err = m.DB.Exec(stmt, postID, userID)
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer tx.Rollback()
 
-	stmt2 := `UPDATE posts SET likes = likes + 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, postID)
-	return err
-}
-
-func (m *ReactionModel) DislikePost(postID, userID int) error {
-	liked, err := m.isLiked(postID, userID)
+	// Начинаем с записи, а не с чтения: транзакция сразу берёт блокировку на запись,
+	// и второй одновременный клик ждёт её завершения, а не проверяет устаревшее состояние
+	stmt := `DELETE FROM reactions WHERE target_type = ? AND target_id = ? AND user_id = ? AND kind = ?`
+	result, err := tx.Exec(stmt, targetType, targetID, userID, kind)
 	if err != nil {
-		return err
+		return "", err
 	}
-	disliked, err := m.isDisliked(postID, userID)
+	removed, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return "", err
+	}
+	if removed > 0 {
+		return "", tx.Commit()
 	}
 
-	if disliked {
-		return m.RemoveDislikePost(postID, userID)
+	if err := upsertReaction(tx, targetType, targetID, userID, kind); err != nil {
+		return "", err
 	}
+	return kind, tx.Commit()
+}
 
-	if liked {
-		if err := m.RemoveLikePost(postID, userID); err != nil {
-			return err
-		}
+// Set ставит реакцию kind независимо от предыдущей
+func (m *ReactionModel) Set(targetType string, targetID, userID int, kind string) error {
+	if !m.valid(targetType, kind) {
+		return ErrInvalidReaction
 	}
 
-	stmt := `INSERT INTO post_dislikes (post_id, user_id) VALUES (?, ?)`
-	_, err = m.DB.Exec(stmt, postID, userID)
+	tx, err := m.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	stmt2 := `UPDATE posts SET dislikes = dislikes + 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, postID)
-	return err
+	if err := upsertReaction(tx, targetType, targetID, userID, kind); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (m *ReactionModel) RemoveLikePost(postID, userID int) error {
-	stmt := `DELETE FROM post_likes WHERE post_id = ? AND user_id = ?`
-	_, err := m.DB.Exec(stmt, postID, userID)
-	if err != nil {
+// upsertReaction ставит реакцию внутри транзакции; у target_id нет внешнего ключа,
+// поэтому существование поста или комментария проверяется здесь
+func upsertReaction(tx *sql.Tx, targetType string, targetID, userID int, kind string) error {
+	table := "posts"
+	if targetType == TargetComment {
+		table = "comments"
+	}
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = ?)`, targetID).Scan(&exists); err != nil {
 		return err
 	}
+	if !exists {
+		return ErrNoRecord
+	}
 
-	stmt2 := `UPDATE posts SET likes = likes - 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, postID)
+	stmt := `INSERT INTO reactions (target_type, target_id, user_id, kind) VALUES (?, ?, ?, ?)
+	ON CONFLICT (target_type, target_id, user_id) DO UPDATE SET kind = excluded.kind, created = CURRENT_TIMESTAMP
+	WHERE kind <> excluded.kind`
+	_, err := tx.Exec(stmt, targetType, targetID, userID, kind)
 	return err
 }
 
-func (m *ReactionModel) RemoveDislikePost(postID, userID int) error {
-	stmt := `DELETE FROM post_dislikes WHERE post_id = ? AND user_id = ?`
-	_, err := m.DB.Exec(stmt, postID, userID)
-	if err != nil {
-		return err
+// Remove снимает реакцию kind, если она стоит; чужую реакцию другого вида не трогает
+func (m *ReactionModel) Remove(targetType string, targetID, userID int, kind string) error {
+	if !m.valid(targetType, kind) {
+		return ErrInvalidReaction
 	}
-
-	stmt2 := `UPDATE posts SET dislikes = dislikes - 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, postID)
+	stmt := `DELETE FROM reactions WHERE target_type = ? AND target_id = ? AND user_id = ? AND kind = ?`
+	_, err := m.DB.Exec(stmt, targetType, targetID, userID, kind)
 	return err
 }
 
-func (m *ReactionModel) isCommentLiked(commentID, userID int) (bool, error) {
-	var exists bool
-	stmt := `SELECT EXISTS(SELECT 1 FROM comment_likes WHERE comment_id = ? AND user_id = ?)`
-	err := m.DB.QueryRow(stmt, commentID, userID).Scan(&exists)
-	return exists, err
+// Get возвращает реакцию пользователя на цель или "", если её нет
+func (m *ReactionModel) Get(targetType string, targetID, userID int) (string, error) {
+	var kind string
+	stmt := `SELECT kind FROM reactions WHERE target_type = ? AND target_id = ? AND user_id = ?`
+	err := m.DB.QueryRow(stmt, targetType, targetID, userID).Scan(&kind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return kind, err
 }
 
-func (m *ReactionModel) isCommentDisliked(commentID, userID int) (bool, error) {
-	var exists bool
-	stmt := `SELECT EXISTS(SELECT 1 FROM comment_dislikes WHERE comment_id = ? AND user_id = ?)`
-	err := m.DB.QueryRow(stmt, commentID, userID).Scan(&exists)
-	return exists, err
-}
+// Summaries возвращает счётчики всех настроенных видов реакций для каждой цели из ids;
+// Mine отмечает реакцию пользователя userID (0 — гость)
+func (m *ReactionModel) Summaries(targetType string, ids []int, userID int) (map[int][]ReactionCount, error) {
+	ids = uniqueIDs(ids)
+	summaries := make(map[int][]ReactionCount, len(ids))
+	if len(ids) == 0 {
+		return summaries, nil
+	}
 
-func (m *ReactionModel) LikeComment(commentID, userID int) error {
-	liked, err := m.isCommentLiked(commentID, userID)
-	if err != nil {
-		return err
+	args := []any{userID, targetType}
+	for _, id := range ids {
+		args = append(args, id)
 	}
-	disliked, err := m.isCommentDisliked(commentID, userID)
+	stmt := `SELECT target_id, kind, COUNT(*), MAX(user_id = ?) FROM reactions
+	WHERE target_type = ? AND target_id IN (` + placeholders(len(ids)) + `)
+	GROUP BY target_id, kind`
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	if liked {
-		return m.RemoveLikeComment(commentID, userID)
+	type key struct {
+		id   int
+		kind string
 	}
-
-	if disliked {
-		if err := m.RemoveDislikeComment(commentID, userID); err != nil {
-			return err
+	found := map[key]ReactionCount{}
+	for rows.Next() {
+		var id int
+		var c ReactionCount
+		if err := rows.Scan(&id, &c.Name, &c.Count, &c.Mine); err != nil {
+			return nil, err
 		}
+		found[key{id, c.Name}] = c
 	}
-
-	stmt := `INSERT INTO comment_likes (comment_id, user_id) VALUES (?, ?)`
-	_, err = m.DB.Exec(stmt, commentID, userID)
-	if err != nil {
-		return err
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	stmt2 := `UPDATE comments SET likes = likes + 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, commentID)
-	return err
+	// Виды, убранные из настроек, не показываются, хотя строки в базе остаются
+	for _, id := range ids {
+		counts := make([]ReactionCount, 0, len(m.AllKinds()))
+		for _, kind := range m.AllKinds() {
+			c := found[key{id, kind.Name}]
+			c.ReactionKind = kind
+			counts = append(counts, c)
+		}
+		summaries[id] = counts
+	}
+	return summaries, nil
 }
 
-func (m *ReactionModel) DislikeComment(commentID, userID int) error {
-	liked, err := m.isCommentLiked(commentID, userID)
-	if err != nil {
-		return err
-	}
-	disliked, err := m.isCommentDisliked(commentID, userID)
+// Reactors возвращает, кто отреагировал на цель, новые реакции первыми
+func (m *ReactionModel) Reactors(targetType string, targetID int) ([]*Reactor, error) {
+	stmt := `SELECT r.user_id, u.name, r.kind, r.created FROM reactions r JOIN users u ON u.id = r.user_id
+	WHERE r.target_type = ? AND r.target_id = ? ORDER BY r.created DESC, u.name`
+	rows, err := m.DB.Query(stmt, targetType, targetID)
 	if err != nil {
-		return err
-	}
-
-	if disliked {
-		return m.RemoveDislikeComment(commentID, userID)
+		return nil, err
 	}
+	defer rows.Close()
 
-	if liked {
-		if err := m.RemoveLikeComment(commentID, userID); err != nil {
-			return err
+	var reactors []*Reactor
+	for rows.Next() {
+		r := &Reactor{}
+		if err := rows.Scan(&r.UserID, &r.Name, &r.Kind, &r.Created); err != nil {
+			return nil, err
 		}
+		kind, ok := m.Kind(r.Kind)
+		if !ok {
+			continue
+		}
+		r.Emoji = kind.Emoji
+		reactors = append(reactors, r)
 	}
+	return reactors, rows.Err()
+}
 
-	stmt := `INSERT INTO comment_dislikes (comment_id, user_id) VALUES (?, ?)`
-	_, err = m.DB.Exec(stmt, commentID, userID)
+// RecomputeCounters пересчитывает likes/dislikes всех постов и комментариев по таблице reactions
+// и возвращает, сколько строк пришлось исправить
+func (m *ReactionModel) RecomputeCounters() (int64, error) {
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var fixed int64
+	for _, target := range []struct{ table, targetType string }{
+		{"posts", TargetPost},
+		{"comments", TargetComment},
+	} {
+		count := func(kind string) string {
+			return `(SELECT COUNT(*) FROM reactions WHERE target_type = '` + target.targetType +
+				`' AND target_id = ` + target.table + `.id AND kind = '` + kind + `')`
+		}
+		stmt := `UPDATE ` + target.table + ` SET likes = ` + count(ReactionLike) + `, dislikes = ` + count(ReactionDislike) + `
+		WHERE likes <> ` + count(ReactionLike) + ` OR dislikes <> ` + count(ReactionDislike)
+		result, err := tx.Exec(stmt)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		fixed += n
 	}
+	return fixed, tx.Commit()
+}
 
-	stmt2 := `UPDATE comments SET dislikes = dislikes + 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, commentID)
+func (m *ReactionModel) LikePost(postID, userID int) error {
+	_, err := m.Toggle(TargetPost, postID, userID, ReactionLike)
 	return err
 }
-func (m *ReactionModel) RemoveLikeComment(commentID, userID int) error {
-	stmt := `DELETE FROM comment_likes WHERE comment_id = ? AND user_id = ?`
-	_, err := m.DB.Exec(stmt, commentID, userID)
-	if err != nil {
-		return err
-	}
 
-	stmt2 := `UPDATE comments SET likes = likes - 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, commentID)
+func (m *ReactionModel) DislikePost(postID, userID int) error {
+	_, err := m.Toggle(TargetPost, postID, userID, ReactionDislike)
 	return err
 }
 
-func (m *ReactionModel) RemoveDislikeComment(commentID, userID int) error {
-	stmt := `DELETE FROM comment_dislikes WHERE comment_id = ? AND user_id = ?`
-	_, err := m.DB.Exec(stmt, commentID, userID)
-	if err != nil {
-		return err
-	}
+func (m *ReactionModel) RemoveLikePost(postID, userID int) error {
+	return m.Remove(TargetPost, postID, userID, ReactionLike)
+}
 
-	stmt2 := `UPDATE comments SET dislikes = dislikes - 1 WHERE id = ?`
-	_, err = m.DB.Exec(stmt2, commentID)
+func (m *ReactionModel) RemoveDislikePost(postID, userID int) error {
+	return m.Remove(TargetPost, postID, userID, ReactionDislike)
+}
+
+func (m *ReactionModel) LikeComment(commentID, userID int) error {
+	_, err := m.Toggle(TargetComment, commentID, userID, ReactionLike)
+	return err
+}
+
+func (m *ReactionModel) DislikeComment(commentID, userID int) error {
+	_, err := m.Toggle(TargetComment, commentID, userID, ReactionDislike)
 	return err
 }
+
+func (m *ReactionModel) RemoveLikeComment(commentID, userID int) error {
+	return m.Remove(TargetComment, commentID, userID, ReactionLike)
+}
+
+func (m *ReactionModel) RemoveDislikeComment(commentID, userID int) error {
+	return m.Remove(TargetComment, commentID, userID, ReactionDislike)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestParseReactionKinds(t *testing.T) {
+	kinds, err := ParseReactionKinds("like:👍, dislike:👎 ,party:🎉")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kinds) != 3 || kinds[2] != (ReactionKind{Name: "party", Emoji: "🎉"}) {
+		t.Errorf("Unexpected kinds %+v", kinds)
+	}
+
+	for _, bad := range []string{
+		"",
+		"like:👍",                       // нет dislike
+		"like:👍,dislike:👎,like:❤️",     // повтор
+		"like:👍,dislike:👎,Big Smile:😀", // недопустимое имя
+		"like:👍,dislike",               // нет эмодзи
+	} {
+		if _, err := ParseReactionKinds(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
@@ -24,7 +24,7 @@ type ReportModel struct {
 }
 
 func (m *ReportModel) Get(id int) (*Report, error) {
-	stmt := `SELECT id, post_id, reporter_id, reason, created_at, admin_id, answer FROM reports`
+	stmt := `SELECT id, post_id, reporter_id, reason, created_at, admin_id, answer FROM reports WHERE id = ?`
 
 	row := m.DB.QueryRow(stmt, id)
 
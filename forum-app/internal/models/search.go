@@ -0,0 +1,239 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// Маркеры совпадений в сниппетах; в HTML их заменяет шаблонная функция highlight.
+// Управляющие символы не встречаются в обычном тексте, поэтому их можно безопасно экранировать вместе с ним.
+const (
+	SnippetOpen  = "\x02"
+	SnippetClose = "\x03"
+)
+
+const searchCommentColumns = `c.id, c.post_id, IFNULL(c.parent_id, 0), c.content, c.likes, c.dislikes, c.user_id, c.author, c.created, c.edited`
+
+// SearchQuery — разобранная строка поиска
+type SearchQuery struct {
+	Match    string // выражение для FTS MATCH; пустое, если в запросе только фильтры
+	Author   string
+	Category string
+	// IncludePending показывает и неодобренные посты (для модераторов)
+	IncludePending bool
+}
+
+// ParseSearchQuery разбирает запрос вида `"точная фраза" gol* author:alice category:News`.
+// Слова объединяются через AND, звёздочка в конце слова ищет по префиксу,
+// значения фильтров можно брать в кавычки: category:"Some name".
+func ParseSearchQuery(q string) SearchQuery {
+	var query SearchQuery
+	var terms []string
+
+	for _, token := range splitSearchTokens(q) {
+		lower := strings.ToLower(token)
+		switch {
+		case strings.HasPrefix(lower, "author:"):
+			query.Author = strings.Trim(token[len("author:"):], `"`)
+		case strings.HasPrefix(lower, "category:"):
+			query.Category = strings.Trim(token[len("category:"):], `"`)
+		case strings.HasPrefix(token, `"`):
+			if phrase := strings.Join(searchWords(token), " "); phrase != "" {
+				terms = append(terms, `"`+phrase+`"`)
+			}
+		default:
+			words := searchWords(token)
+			// Звёздочка относится только к последнему слову: foo-ba* → foo ba*
+			if len(words) > 0 && strings.HasSuffix(token, "*") {
+				words[len(words)-1] += "*"
+			}
+			terms = append(terms, words...)
+		}
+	}
+
+	query.Match = strings.Join(terms, " ")
+	return query
+}
+
+// Empty сообщает, что искать нечего
+func (q SearchQuery) Empty() bool {
+	return q.Match == "" && q.Author == "" && q.Category == ""
+}
+
+// splitSearchTokens режет строку по пробелам, не разрывая фрагменты в кавычках
+func splitSearchTokens(q string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// searchWords оставляет только буквы и цифры в нижнем регистре, чтобы пользовательский ввод
+// не ломал синтаксис MATCH (в том числе операторы AND, OR, NOT, NEAR)
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+type PostHit struct {
+	Post    *Post
+	Title   string // заголовок с маркерами совпадений
+	Snippet string
+	Rank    float64 // -bm25(): чем больше, тем выше совпадение
+}
+
+type CommentHit struct {
+	Comment   *Comment
+	PostTitle string
+	Snippet   string
+	Rank      float64
+}
+
+type SearchModel struct {
+	DB *sql.DB
+}
+
+// filters возвращает условия на пост p (статус, автор, категория), общие для поиска постов и комментариев
+func (q SearchQuery) filters(authorColumn string) (string, []any) {
+	var conds []string
+	var args []any
+
+	if !q.IncludePending {
+		conds = append(conds, `p.status = 'approved'`)
+	}
+	if q.Author != "" {
+		conds = append(conds, authorColumn+` = ? COLLATE NOCASE`)
+		args = append(args, q.Author)
+	}
+	if q.Category != "" {
+		conds = append(conds, `p.id IN (SELECT pc.post_id FROM post_categories pc JOIN categories c ON c.id = pc.category_id
+		WHERE c.name = ? COLLATE NOCASE)`)
+		args = append(args, q.Category)
+	}
+	if len(conds) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Posts ищет посты и возвращает не больше limit результатов, лучшие первыми.
+// Без текста в запросе результаты отфильтрованы только по автору и категории и идут от новых к старым.
+func (m *SearchModel) Posts(q SearchQuery, limit int) ([]*PostHit, error) {
+	if q.Empty() {
+		return nil, nil
+	}
+	cond, args := q.filters("p.author")
+
+	var stmt string
+	if q.Match != "" {
+		// bm25() тем меньше, чем лучше совпадение; совпадение в заголовке весит вдвое больше, чем в тексте
+		stmt = `SELECT p.id, p.title, p.content, p.image_path, p.likes, p.dislikes, p.author, p.author_id, p.created, p.status,
+		highlight(posts_fts, 0, '` + SnippetOpen + `', '` + SnippetClose + `'),
+		snippet(posts_fts, 1, '` + SnippetOpen + `', '` + SnippetClose + `', '…', 24),
+		bm25(posts_fts, 2.0, 1.0) AS score
+		FROM posts_fts JOIN posts p ON p.id = posts_fts.rowid
+		WHERE posts_fts MATCH ? AND ` + cond + ` ORDER BY score, p.id DESC LIMIT ?`
+		args = append([]any{q.Match}, args...)
+	} else {
+		stmt = `SELECT p.id, p.title, p.content, p.image_path, p.likes, p.dislikes, p.author, p.author_id, p.created, p.status,
+		p.title, substr(p.content, 1, 200), 0.0
+		FROM posts p
+		WHERE ` + cond + ` ORDER BY p.created DESC, p.id DESC LIMIT ?`
+	}
+
+	rows, err := m.DB.Query(stmt, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*PostHit
+	for rows.Next() {
+		p := &Post{}
+		hit := &PostHit{Post: p}
+		var score float64
+		err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.ImagePath, &p.Likes, &p.Dislikes, &p.Author, &p.AuthorID, &p.Created, &p.Status,
+			&hit.Title, &hit.Snippet, &score)
+		if err != nil {
+			return nil, err
+		}
+		hit.Rank = -score
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, len(hits))
+	for i, hit := range hits {
+		posts[i] = hit.Post
+	}
+	return hits, (&PostModel{DB: m.DB}).attachCategories(posts)
+}
+
+// Comments ищет комментарии к видимым постам; author: относится к автору комментария
+func (m *SearchModel) Comments(q SearchQuery, limit int) ([]*CommentHit, error) {
+	if q.Match == "" && q.Author == "" {
+		return nil, nil
+	}
+	cond, args := q.filters("c.author")
+
+	var stmt string
+	if q.Match != "" {
+		stmt = `SELECT ` + searchCommentColumns + `, p.title,
+		snippet(comments_fts, 0, '` + SnippetOpen + `', '` + SnippetClose + `', '…', 24),
+		bm25(comments_fts) AS score
+		FROM comments_fts JOIN comments c ON c.id = comments_fts.rowid JOIN posts p ON p.id = c.post_id
+		WHERE comments_fts MATCH ? AND ` + cond + ` ORDER BY score, c.id DESC LIMIT ?`
+		args = append([]any{q.Match}, args...)
+	} else {
+		stmt = `SELECT ` + searchCommentColumns + `, p.title, substr(c.content, 1, 200), 0.0
+		FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.deleted IS NULL AND ` + cond + ` ORDER BY c.created DESC, c.id DESC LIMIT ?`
+	}
+
+	rows, err := m.DB.Query(stmt, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*CommentHit
+	for rows.Next() {
+		c := &Comment{}
+		hit := &CommentHit{Comment: c}
+		var edited sql.NullTime
+		var score float64
+		err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.Content, &c.Likes, &c.Dislikes, &c.UserID, &c.Author, &c.Created, &edited,
+			&hit.PostTitle, &hit.Snippet, &score)
+		if err != nil {
+			return nil, err
+		}
+		if edited.Valid {
+			c.Edited = &edited.Time
+		}
+		hit.Rank = -score
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
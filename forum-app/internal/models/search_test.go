@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SearchQuery
+	}{
+		{`golang`, SearchQuery{Match: `golang`}},
+		{`"Hello  World" gol*`, SearchQuery{Match: `"hello world" gol*`}},
+		{`author:alice category:"Some Name" sql`, SearchQuery{Match: `sql`, Author: "alice", Category: "Some Name"}},
+		{`Author:Bob`, SearchQuery{Author: "Bob"}},
+		{`NOT a OR (b) -c`, SearchQuery{Match: `not a or b c`}},
+		{`foo-ba*`, SearchQuery{Match: `foo ba*`}},
+		{`""  *`, SearchQuery{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := ParseSearchQuery(tt.in); got != tt.want {
+				t.Errorf("ParseSearchQuery(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
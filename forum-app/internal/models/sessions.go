@@ -0,0 +1,248 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Session struct {
+	ID        int
+	Token     string
+	UserID    int
+	Expiry    time.Time
+	Created   time.Time
+	LastSeen  time.Time
+	UserAgent string
+	IP        string
+}
+
+// SessionStore хранилище сессий; SessionModel для SQLite, MemorySessionStore для тестов
+type SessionStore interface {
+	Create(s *Session) error
+	Get(token string) (*Session, error)
+	ListByUser(userID int) ([]*Session, error)
+	Touch(token string, lastSeen time.Time) error
+	Delete(token string) error
+	DeleteByID(userID, id int) error
+	DeleteByUser(userID int) error
+	DeleteExpired() (int, error)
+}
+
+type SessionModel struct {
+	DB *sql.DB
+}
+
+func (m *SessionModel) Create(s *Session) error {
+	stmt := `INSERT INTO sessions (token, user_id, expiry, created, last_seen, user_agent, ip)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := m.DB.Exec(stmt, s.Token, s.UserID, s.Expiry.UTC(), s.Created.UTC(), s.LastSeen.UTC(), s.UserAgent, s.IP)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	s.ID = int(id)
+	return nil
+}
+
+// Get возвращает активную сессию; просроченные считаются отсутствующими
+func (m *SessionModel) Get(token string) (*Session, error) {
+	stmt := `SELECT id, token, user_id, expiry, created, last_seen, user_agent, ip FROM sessions
+	WHERE token = ? AND expiry > ?`
+
+	s := &Session{}
+	err := m.DB.QueryRow(stmt, token, time.Now().UTC()).Scan(&s.ID, &s.Token, &s.UserID, &s.Expiry, &s.Created, &s.LastSeen, &s.UserAgent, &s.IP)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListByUser возвращает все активные сессии пользователя, последние использованные первыми
+func (m *SessionModel) ListByUser(userID int) ([]*Session, error) {
+	stmt := `SELECT id, token, user_id, expiry, created, last_seen, user_agent, ip FROM sessions
+	WHERE user_id = ? AND expiry > ?
+	ORDER BY last_seen DESC`
+
+	rows, err := m.DB.Query(stmt, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s := &Session{}
+		err := rows.Scan(&s.ID, &s.Token, &s.UserID, &s.Expiry, &s.Created, &s.LastSeen, &s.UserAgent, &s.IP)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (m *SessionModel) Touch(token string, lastSeen time.Time) error {
+	stmt := `UPDATE sessions SET last_seen = ? WHERE token = ?`
+	_, err := m.DB.Exec(stmt, lastSeen.UTC(), token)
+	return err
+}
+
+func (m *SessionModel) Delete(token string) error {
+	stmt := `DELETE FROM sessions WHERE token = ?`
+	_, err := m.DB.Exec(stmt, token)
+	return err
+}
+
+// DeleteByID удаляет сессию только если она принадлежит пользователю
+func (m *SessionModel) DeleteByID(userID, id int) error {
+	stmt := `DELETE FROM sessions WHERE id = ? AND user_id = ?`
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SessionModel) DeleteByUser(userID int) error {
+	stmt := `DELETE FROM sessions WHERE user_id = ?`
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
+
+func (m *SessionModel) DeleteExpired() (int, error) {
+	stmt := `DELETE FROM sessions WHERE expiry <= ?`
+	result, err := m.DB.Exec(stmt, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// MemorySessionStore хранит сессии в памяти процесса; используется в тестах
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	nextID   int
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]Session)}
+}
+
+func (m *MemorySessionStore) Create(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.ID == 0 {
+		m.nextID++
+		s.ID = m.nextID
+	}
+	m.sessions[s.Token] = *s
+	return nil
+}
+
+func (m *MemorySessionStore) Get(token string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[token]
+	if !ok || !s.Expiry.After(time.Now()) {
+		return nil, ErrNoRecord
+	}
+	return &s, nil
+}
+
+func (m *MemorySessionStore) ListByUser(userID int) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var sessions []*Session
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.Expiry.After(now) {
+			sessions = append(sessions, &s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeen.After(sessions[j].LastSeen) })
+	return sessions, nil
+}
+
+func (m *MemorySessionStore) Touch(token string, lastSeen time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[token]; ok {
+		s.LastSeen = lastSeen
+		m.sessions[token] = s
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, token)
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteByID(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, s := range m.sessions {
+		if s.ID == id && s.UserID == userID {
+			delete(m.sessions, token)
+			return nil
+		}
+	}
+	return ErrNoRecord
+}
+
+func (m *MemorySessionStore) DeleteByUser(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, token)
+		}
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteExpired() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	for token, s := range m.sessions {
+		if !s.Expiry.After(now) {
+			delete(m.sessions, token)
+			count++
+		}
+	}
+	return count, nil
+}
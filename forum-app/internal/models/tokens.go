@@ -0,0 +1,161 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Области действия токенов; каждая следующая включает предыдущие
+const (
+	ScopeRead       = "read"
+	ScopeWrite      = "write"
+	ScopeModeration = "moderation"
+)
+
+var scopeLevels = map[string]int{
+	ScopeRead:       1,
+	ScopeWrite:      2,
+	ScopeModeration: 3,
+}
+
+// tokenPrefix помогает узнать токен форума в логах и сканерах секретов
+const tokenPrefix = "frm_"
+
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scope    string
+	Created  time.Time
+	LastUsed time.Time // нулевое значение — ни разу не использовался
+	Expiry   time.Time // нулевое значение — бессрочный
+}
+
+type APITokenModel struct {
+	DB *sql.DB
+}
+
+// ValidScope проверяет, что scope — одна из известных областей
+func ValidScope(scope string) bool {
+	_, ok := scopeLevels[scope]
+	return ok
+}
+
+// ScopeAllows сообщает, покрывает ли область have требуемую область want
+func ScopeAllows(have, want string) bool {
+	return scopeLevels[have] >= scopeLevels[want] && scopeLevels[want] > 0
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Insert создаёт токен и возвращает его в открытом виде; в базе хранится только хеш
+func (m *APITokenModel) Insert(userID int, name, scope string, expiry time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(b)
+
+	var exp sql.NullTime
+	if !expiry.IsZero() {
+		exp = sql.NullTime{Time: expiry.UTC(), Valid: true}
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scope, created, expiry) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := m.DB.Exec(stmt, userID, name, hashToken(token), scope, time.Now().UTC(), exp)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Authenticate находит действующий токен по открытому значению
+func (m *APITokenModel) Authenticate(token string) (*APIToken, error) {
+	stmt := `SELECT id, user_id, name, scope, created, last_used, expiry FROM api_tokens
+	WHERE token_hash = ? AND (expiry IS NULL OR expiry > ?)`
+
+	t, err := scanAPIToken(m.DB.QueryRow(stmt, hashToken(token), time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *APITokenModel) ListByUser(userID int) ([]*APIToken, error) {
+	stmt := `SELECT id, user_id, name, scope, created, last_used, expiry FROM api_tokens
+	WHERE user_id = ? ORDER BY created DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (m *APITokenModel) TouchLastUsed(id int, lastUsed time.Time) error {
+	stmt := `UPDATE api_tokens SET last_used = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, lastUsed.UTC(), id)
+	return err
+}
+
+// Delete отзывает токен, только если он принадлежит пользователю
+func (m *APITokenModel) Delete(userID, id int) error {
+	stmt := `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteByUser отзывает все токены пользователя
+func (m *APITokenModel) DeleteByUser(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM api_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	t := &APIToken{}
+	var lastUsed, expiry sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed, &expiry)
+	if err != nil {
+		return nil, err
+	}
+	t.LastUsed = lastUsed.Time
+	t.Expiry = expiry.Time
+	return t, nil
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Сколько кодов восстановления выдаётся за раз
+const RecoveryCodeCount = 10
+
+var (
+	ErrInvalidChallenge  = errors.New("models: invalid or expired login challenge")
+	ErrTwoFactorEnabled  = errors.New("models: two-factor authentication is already enabled")
+	ErrTwoFactorDisabled = errors.New("models: two-factor authentication is not enabled")
+)
+
+// TwoFactor — состояние 2FA пользователя. Secret без Enabled — начатая, но не подтверждённая настройка.
+type TwoFactor struct {
+	Secret        string
+	Enabled       bool
+	LastStep      int64
+	RecoveryCodes int // сколько неиспользованных кодов восстановления осталось
+}
+
+// TwoFactorModel хранит секреты TOTP, коды восстановления и незавершённые входы
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+// Get возвращает состояние 2FA пользователя
+func (m *TwoFactorModel) Get(userID int) (*TwoFactor, error) {
+	stmt := `SELECT totp_secret, totp_enabled, totp_last_step,
+	(SELECT COUNT(*) FROM recovery_codes WHERE user_id = users.id AND used IS NULL)
+	FROM users WHERE id = ?`
+	tf := &TwoFactor{}
+	err := m.DB.QueryRow(stmt, userID).Scan(&tf.Secret, &tf.Enabled, &tf.LastStep, &tf.RecoveryCodes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	return tf, err
+}
+
+// Begin сохраняет новый секрет до подтверждения кодом; повторный вызов заменяет секрет
+func (m *TwoFactorModel) Begin(userID int, secret string) error {
+	result, err := m.DB.Exec(`UPDATE users SET totp_secret = ? WHERE id = ? AND totp_enabled = 0`, secret, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// Enable включает 2FA после того, как пользователь ввёл верный код шага step,
+// и возвращает новые коды восстановления в открытом виде — показать их можно только сейчас
+func (m *TwoFactorModel) Enable(userID int, step int64) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_enabled = 1, totp_last_step = ? WHERE id = ? AND totp_enabled = 0 AND totp_secret != ''`
+	result, err := tx.Exec(stmt, step, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrTwoFactorEnabled
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// Disable выключает 2FA и удаляет секрет и коды восстановления
+func (m *TwoFactorModel) Disable(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_secret = '', totp_enabled = 0, totp_last_step = 0 WHERE id = ?`
+	if _, err := tx.Exec(stmt, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep принимает код шага step, только если он новее последнего принятого: один код нельзя ввести дважды
+func (m *TwoFactorModel) UseStep(userID int, step int64) (bool, error) {
+	stmt := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_enabled = 1 AND totp_last_step < ?`
+	result, err := m.DB.Exec(stmt, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RegenerateRecoveryCodes заменяет все коды восстановления новыми
+func (m *TwoFactorModel) RegenerateRecoveryCodes(userID int) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	if err := tx.QueryRow(`SELECT totp_enabled FROM users WHERE id = ?`, userID).Scan(&enabled); err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorDisabled
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// UseRecoveryCode гасит код восстановления; false — код неверный или уже использован
+func (m *TwoFactorModel) UseRecoveryCode(userID int, code string) (bool, error) {
+	stmt := `UPDATE recovery_codes SET used = CURRENT_TIMESTAMP
+	WHERE id = (SELECT id FROM recovery_codes WHERE user_id = ? AND code_hash = ? AND used IS NULL LIMIT 1)`
+	result, err := m.DB.Exec(stmt, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// normalizeRecoveryCode убирает то, что пользователь мог добавить при вводе: пробелы, дефисы, регистр
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		// 16 символов base32 (80 бит) группами по 4: abcd-efgh-ijkl-mnop
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hashToken(raw)); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// CreateChallenge начинает второй шаг входа для пользователя, уже введшего пароль.
+// Возвращает токен для cookie; в базе хранится только его хеш.
+func (m *TwoFactorModel) CreateChallenge(userID int, ttl time.Duration, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if _, err := m.DB.Exec(`DELETE FROM login_challenges WHERE expiry <= ?`, sqliteTime(now)); err != nil {
+		return "", err
+	}
+	stmt := `INSERT INTO login_challenges (token_hash, user_id, expiry) VALUES (?, ?, ?)`
+	if _, err := m.DB.Exec(stmt, hashToken(token), userID, sqliteTime(now.Add(ttl))); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Challenge возвращает пользователя незавершённого входа или ErrInvalidChallenge
+func (m *TwoFactorModel) Challenge(token string, now time.Time) (int, error) {
+	var userID int
+	stmt := `SELECT user_id FROM login_challenges WHERE token_hash = ? AND expiry > ?`
+	err := m.DB.QueryRow(stmt, hashToken(token), sqliteTime(now)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidChallenge
+	}
+	return userID, err
+}
+
+// FailChallenge засчитывает неверный код; после maxAttempts попыток вход нужно начинать заново с пароля
+func (m *TwoFactorModel) FailChallenge(token string, maxAttempts int) (int, error) {
+	var attempts int
+	stmt := `UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = ? RETURNING attempts`
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidChallenge
+	} else if err != nil {
+		return 0, err
+	}
+	if attempts >= maxAttempts {
+		return attempts, m.DeleteChallenge(token)
+	}
+	return attempts, nil
+}
+
+// DeleteChallenge завершает второй шаг входа
+func (m *TwoFactorModel) DeleteChallenge(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM login_challenges WHERE token_hash = ?`, hashToken(token))
+	return err
+}
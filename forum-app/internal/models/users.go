@@ -20,6 +20,7 @@ type User struct {
 	ProviderID     string
 	Created        time.Time
 	Role           string
+	EmailVerified  bool
 }
 
 type UserModel struct {
@@ -90,11 +91,11 @@ func hashPassword(password string, salt string) (string, error) {
 }
 
 func (m *UserModel) Get(id int) (*User, error) {
-	stmt := `SELECT id, name, email, hashed_password, created, role FROM users WHERE id = ?`
+	stmt := `SELECT id, name, email, hashed_password, created, role, email_verified FROM users WHERE id = ?`
 	row := m.DB.QueryRow(stmt, id)
 
 	u := &User{}
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Role)
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Role, &u.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -154,11 +155,11 @@ func (m *UserModel) GetOrCreateOAuthUser(email, name, provider, provider_id stri
 		return userID, nil // Пользователь существует
 	}
 
-	// Если пользователя нет, создаем нового
+	// Если пользователя нет, создаем нового; адрес уже подтвердил провайдер
 	result, err := m.DB.Exec(`
         INSERT INTO users
-            (name, email, provider, provider_id, role, created, hashed_password) 
-        VALUES (?, ?, ?, ?, 'user', DATETIME('now'), 'google')
+            (name, email, provider, provider_id, role, created, hashed_password, email_verified) 
+        VALUES (?, ?, ?, ?, 'user', DATETIME('now'), 'google', 1)
     `, name, email, provider, provider_id)
 
 	if err != nil {
@@ -208,12 +209,12 @@ func (m *UserModel) GetPendingModerators() ([]*User, error) {
 }
 
 func (m *UserModel) GetByEmail(email string) (*User, error) {
-	stmt := `SELECT id, name, email, hashed_password, created, role 
+	stmt := `SELECT id, name, email, hashed_password, created, role, email_verified
              FROM users WHERE email = ?`
 	row := m.DB.QueryRow(stmt, email)
 
 	u := &User{}
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Role)
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.HashedPassword, &u.Created, &u.Role, &u.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -222,3 +223,31 @@ func (m *UserModel) GetByEmail(email string) (*User, error) {
 	}
 	return u, nil
 }
+
+// IDsByName возвращает id пользователей с указанными именами без учёта регистра;
+// имена не уникальны, поэтому одному имени может соответствовать несколько id
+func (m *UserModel) IDsByName(names []string) ([]int, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	stmt := `SELECT id FROM users WHERE name COLLATE NOCASE IN (` + placeholders(len(names)) + `) ORDER BY id`
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
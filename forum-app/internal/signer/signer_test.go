@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type claims struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+func TestSignAndVerify(t *testing.T) {
+	s := New([]byte("test-key"))
+	now := time.Now()
+
+	token, err := s.Sign("verify", claims{UserID: 7, Email: "a@example.com"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got claims
+	if err := s.Verify("verify", token, &got, now); err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != 7 || got.Email != "a@example.com" {
+		t.Errorf("Unexpected claims %+v", got)
+	}
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		purpose string
+		token   string
+		now     time.Time
+		want    error
+	}{
+		{"expired", s, "verify", token, now.Add(time.Hour), ErrExpired},
+		{"other purpose", s, "reset", token, now, ErrInvalid},
+		{"other key", New([]byte("other-key")), "verify", token, now, ErrInvalid},
+		{"tampered", s, "verify", "x" + token, now, ErrInvalid},
+		{"no signature", s, "verify", "abc", now, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c claims
+			if err := tt.signer.Verify(tt.purpose, tt.token, &c, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMAC(t *testing.T) {
+	s := New([]byte("test-key"))
+	mac := s.MAC("csrf", "session-1")
+	if mac != s.MAC("csrf", "session-1") {
+		t.Error("Expected the same MAC for the same input")
+	}
+	for _, other := range []string{s.MAC("csrf", "session-2"), s.MAC("other", "session-1"), New([]byte("other-key")).MAC("csrf", "session-1")} {
+		if other == mac {
+			t.Error("Expected a different MAC for a different input or key")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Векторы из RFC 6238 (SHA1), последние 6 цифр 8-значных кодов
+func TestCodeRFC6238(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := Code(secret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("At %d: expected %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+		t.Fatalf("Expected a base32 secret, got %q", secret)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	code, _ := Code(secret, Step(now))
+	if step, ok := Validate(secret, code, now); !ok || step != Step(now) {
+		t.Errorf("Expected the current code to be valid")
+	}
+	if _, ok := Validate(secret, code, now.Add(Period*time.Second)); !ok {
+		t.Errorf("Expected the previous step to be accepted")
+	}
+	if _, ok := Validate(secret, code, now.Add(3*Period*time.Second)); ok {
+		t.Errorf("Expected an old code to be rejected")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Errorf("Expected a short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Forum", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Forum:alice@example.com?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+}
@@ -1,25 +1,56 @@
-document.addEventListener('DOMContentLoaded', function() {
-    const setupEventSource = () => {
-        const eventSource = new EventSource('/notifications/stream');
-        
-        eventSource.onmessage = function(e) {
-            fetch('/notifications')
-                .then(response => response.text())
-                .then(html => {
-                    const parser = new DOMParser();
-                    const doc = parser.parseFromString(html, 'text/html');
-                    const newContent = doc.getElementById('notifications-container').innerHTML;
-                    document.getElementById('notifications-container').innerHTML = newContent;
-                });
-        };
-        
-        eventSource.onerror = function(err) {
-            console.error('EventSource failed:', err);
-            eventSource.close();
-            // Attempt reconnect after 5 seconds
-            setTimeout(setupEventSource, 5000);
-        };
+// Живые уведомления: /notifications/stream присылает события notification и unread.
+// EventSource сам переподключается и передаёт Last-Event-ID, сервер досылает пропущенное.
+(function() {
+    var texts = {
+        post_like: 'liked your post',
+        post_dislike: 'disliked your post',
+        post_reaction: 'reacted to your post',
+        comment_reaction: 'reacted to your comment',
+        comment: 'commented on your post',
+        reply: 'replied to your comment',
+        comment_like: 'liked your comment',
+        comment_dislike: 'disliked your comment',
+        mention: 'mentioned you in a comment',
+        post_approved: 'approved your post',
+        report_answered: 'answered your report'
     };
 
-    setupEventSource();
-});
\ No newline at end of file
+    function setUnread(count) {
+        var badge = document.getElementById('unread-badge');
+        if (!badge) {
+            return;
+        }
+        badge.textContent = count;
+        badge.hidden = count === 0;
+    }
+
+    function prepend(n) {
+        var list = document.getElementById('notification-list');
+        if (!list || !texts[n.type]) {
+            return;
+        }
+        var empty = list.querySelector('.notification-empty');
+        if (empty) {
+            empty.remove();
+        }
+
+        var item = document.createElement('div');
+        item.className = 'notification unread';
+        var link = document.createElement('a');
+        link.href = '/notifications/open?id=' + n.id;
+        link.textContent = n.actor_name + ' ' + texts[n.type];
+        var time = document.createElement('span');
+        time.className = 'text-muted';
+        time.textContent = new Date(n.created).toLocaleString();
+        item.append(link, ' ', time);
+        list.prepend(item);
+    }
+
+    var source = new EventSource('/notifications/stream');
+    source.addEventListener('unread', function(e) {
+        setUnread(JSON.parse(e.data).count);
+    });
+    source.addEventListener('notification', function(e) {
+        prepend(JSON.parse(e.data));
+    });
+})();
\ No newline at end of file